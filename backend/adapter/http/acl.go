@@ -249,6 +249,32 @@ func (a *HTTPAdapter) UpdatePolicyRule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (a *HTTPAdapter) PreviewDeleteACLPolicy(w http.ResponseWriter, r *http.Request) {
+	b64name := chi.URLParam(r, "b64name")
+	name, err := base64.StdEncoding.DecodeString(b64name)
+	if err != nil {
+		errorResponse(w, &StatusError{
+			Process: "decoding policy name",
+			Status:  http.StatusBadRequest,
+			Err:     errIdEmpty,
+		})
+		return
+	}
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	resp, err := a.aclService.PreviewDeletePolicy(ctx, string(name))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, resp)
+}
+
+// DeleteACLPolicy deletes a policy.
+//
+// Query parameters:
+//   - confirm=1: required if the policy is still used by tokens or roles
+//   - replacement=<name>: policy attached to those tokens and roles instead
 func (a *HTTPAdapter) DeleteACLPolicy(w http.ResponseWriter, r *http.Request) {
 	b64name := chi.URLParam(r, "b64name")
 	name, err := base64.StdEncoding.DecodeString(b64name)
@@ -260,10 +286,15 @@ func (a *HTTPAdapter) DeleteACLPolicy(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	query := r.URL.Query()
+	req := DeletePolicyRequest{
+		Confirm:     query.Get("confirm") == "1",
+		Replacement: query.Get("replacement"),
+	}
 	utoken := r.Header.Get(ConseeTokenHeaderKey)
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
 	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
-	err = a.aclService.DeletePolicy(ctx, string(name))
+	err = a.aclService.DeletePolicy(ctx, string(name), &req)
 	if err != nil {
 		errorResponse(w, NewStatusError(err))
 		return
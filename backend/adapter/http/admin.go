@@ -5,13 +5,19 @@ package httpadapter
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
 	"path/filepath"
-	"strconv"
+	"strings"
 	"time"
 
 	. "github.com/FlyingOnion/consee/backend/common"
 	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/service"
 )
 
 // checkAdminToken checks if the token provided has admin permission.
@@ -35,50 +41,230 @@ func (a *HTTPAdapter) checkAdminToken(next http.Handler) http.HandlerFunc {
 	})
 }
 
+// Import imports the zip, json or encrypted .consee file in the multipart "file" field.
+// The upload is spooled into a temporary file first, and files larger than the max import size are rejected.
+// Encrypted archives require the "passphrase" field, or 428 is responded.
+// Conflicts are resolved by "on_conflict=skip|replace|rename|newer" and "rename_suffix" in query,
+// and the "overrides" field, a json list of ImportOverride, chooses the policy item by item.
+// Native formats (yaml, hcl, properties, env and json objects) are imported into "root",
+// and keys of flat formats are split into folders by "separator".
+// With "template=1" in query, values are rendered as templates with variables of the "vars" field
+// (yaml or json) over those of the vars file named by "vars_file" in query.
+// Use "dryrun=1" in query or form to check conflicts and unresolved references only,
+// and "progress=1" to stream progress as json lines before the result.
 func (a *HTTPAdapter) Import(w http.ResponseWriter, r *http.Request) {
-	v := r.FormValue("dryrun")
-	dryrun := v == "1"
-	file, header, err := r.FormFile("file")
+	// uploads could be large, the default read timeout of the server is not enough
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	query := r.URL.Query()
+	withProgress := query.Get("progress") == "1"
+
+	upload, err := a.spoolImportFile(w, r)
 	if err != nil {
-		errorResponse(w, StatusError{
-			Status:  http.StatusBadRequest,
-			Process: "parsing file",
-			Err:     errInvalidFile,
-		})
+		errorResponse(w, err)
 		return
 	}
-	fileExt := filepath.Ext(header.Filename)
-	switch fileExt {
-	case ".zip":
-	case ".json":
-	default:
-		errorResponse(w, StatusError{
-			Status:  http.StatusBadRequest,
-			Process: "parsing file",
-			Err:     errInvalidFileFormat,
-		})
+	defer os.Remove(upload.file.Name())
+	defer upload.file.Close()
+	dryrun := query.Get("dryrun") == "1" || upload.fields["dryrun"] == "1"
+	fi, err := upload.file.Stat()
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "reading file", Status: http.StatusInternalServerError})
 		return
 	}
-	b := make([]byte, header.Size)
-	file.Read(b)
-	file.Close()
 
 	utoken := r.Header.Get(ConseeTokenHeaderKey)
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
 	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
 
-	resp, err := a.a2.Import(ctx, &ImportRequest{
-		Format:      fileExt[1:],
-		Dryrun:      dryrun,
-		FileContent: b,
-	})
+	req := &ImportRequest{
+		Format:       upload.format,
+		Dryrun:       dryrun,
+		OnConflict:   OnConflictPolicy(query.Get("on_conflict")),
+		RenameSuffix: query.Get("rename_suffix"),
+		Root:         query.Get("root"),
+		Separator:    query.Get("separator"),
+		File:         upload.file,
+		Size:         fi.Size(),
+		Passphrase:   upload.fields["passphrase"],
+		Template:     query.Get("template") == "1",
+		VarsFile:     query.Get("vars_file"),
+	}
+	if v := upload.fields["overrides"]; v != "" {
+		if err = json.Unmarshal([]byte(v), &req.Overrides); err != nil {
+			errorResponse(w, &StatusError{Err: err, Process: "decoding overrides", Status: http.StatusBadRequest})
+			return
+		}
+	}
+	if v := upload.fields["vars"]; v != "" {
+		if req.Vars, err = service.ParseTemplateVars([]byte(v)); err != nil {
+			errorResponse(w, err)
+			return
+		}
+	}
+	if !withProgress {
+		resp, err := a.a2.Import(ctx, req)
+		if err != nil {
+			errorResponse(w, err)
+			return
+		}
+		response(w, resp)
+		return
+	}
+
+	// once the first line is written, errors could only be sent as lines
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	req.Progress = func(p ImportProgress) {
+		enc.Encode(importLine{Progress: &p})
+		rc.Flush()
+	}
+	resp, err := a.a2.Import(ctx, req)
 	if err != nil {
-		errorResponse(w, err)
+		enc.Encode(importLine{Error: err.Error()})
 		return
 	}
-	response(w, resp)
+	enc.Encode(importLine{Result: resp})
 }
 
+// importLine is a line of the streamed import response. Exactly one field is set.
+type importLine struct {
+	Progress *ImportProgress `json:"progress,omitempty"`
+	Result   *ImportResponse `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// importUpload is the parsed multipart form of an import.
+type importUpload struct {
+	// file is a temporary file, which should be closed and removed after use
+	file   *os.File
+	format string
+	// fields are the other fields in importFormFields
+	fields map[string]string
+}
+
+// importFormats maps extensions of uploaded files to import formats.
+var importFormats = map[string]string{
+	".zip":        "zip",
+	".json":       "json",
+	encrypt.Ext:   "consee",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".hcl":        "hcl",
+	".properties": "properties",
+	".env":        "env",
+}
+
+// importFormFields are small fields of the import form and their max sizes.
+var importFormFields = map[string]int64{
+	"dryrun":     1 << 4,
+	"passphrase": 4 << 10,
+	"overrides":  4 << 20,
+	"vars":       1 << 20,
+	// the json request of diff and promote with an uploaded export
+	"request": 4 << 20,
+}
+
+// spoolImportFile copies the multipart "file" field into a temporary file,
+// and reads the other fields of importFormFields.
+func (a *HTTPAdapter) spoolImportFile(w http.ResponseWriter, r *http.Request) (*importUpload, error) {
+	invalidFile := &StatusError{Err: errInvalidFile, Process: "parsing file", Status: http.StatusBadRequest}
+	tooLarge := &StatusError{Err: errFileTooLarge, Process: "parsing file", Status: http.StatusRequestEntityTooLarge}
+	if a.maxImportSize > 0 {
+		// leave some room for the other fields and boundaries
+		r.Body = http.MaxBytesReader(w, r.Body, a.maxImportSize+1<<20)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, invalidFile
+	}
+	upload := &importUpload{fields: map[string]string{}}
+	fail := func(err error) (*importUpload, error) {
+		if upload.file != nil {
+			upload.file.Close()
+			os.Remove(upload.file.Name())
+		}
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			if upload.file == nil {
+				return nil, invalidFile
+			}
+			return upload, nil
+		}
+		if err != nil {
+			if isTooLarge(err) {
+				return fail(tooLarge)
+			}
+			return fail(invalidFile)
+		}
+		if max, ok := importFormFields[part.FormName()]; ok {
+			b, err := io.ReadAll(io.LimitReader(part, max))
+			part.Close()
+			if err != nil {
+				return fail(invalidFile)
+			}
+			upload.fields[part.FormName()] = string(b)
+			continue
+		}
+		switch part.FormName() {
+		case "file":
+			if upload.file != nil {
+				part.Close()
+				return fail(invalidFile)
+			}
+		default:
+			part.Close()
+			continue
+		}
+
+		ext := filepath.Ext(part.FileName())
+		format, ok := importFormats[ext]
+		if !ok {
+			part.Close()
+			return fail(&StatusError{Err: errInvalidFileFormat, Process: "parsing file", Status: http.StatusBadRequest})
+		}
+		upload.format = format
+		if ext == encrypt.Ext {
+			// e.g. "consee-export.yaml.consee" keeps the format of the plain archive
+			if inner, ok := importFormats[filepath.Ext(strings.TrimSuffix(part.FileName(), ext))]; ok {
+				upload.format = inner
+			}
+		}
+		upload.file, err = os.CreateTemp("", "consee-import-*"+ext)
+		if err != nil {
+			part.Close()
+			return fail(&StatusError{Err: err, Process: "creating temporary file", Status: http.StatusInternalServerError})
+		}
+		var src io.Reader = part
+		if a.maxImportSize > 0 {
+			src = io.LimitReader(part, a.maxImportSize+1)
+		}
+		n, err := io.Copy(upload.file, src)
+		part.Close()
+		if err == nil && a.maxImportSize > 0 && n > a.maxImportSize {
+			err = errFileTooLarge
+		}
+		if err != nil {
+			if isTooLarge(err) {
+				return fail(tooLarge)
+			}
+			return fail(&StatusError{Err: err, Process: "reading file", Status: http.StatusBadRequest})
+		}
+	}
+}
+
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr) || errors.Is(err, errFileTooLarge)
+}
+
+// Export streams the exported file to the client.
+// Errors after the first byte is written are logged, and the response is aborted
+// so that the client never takes a truncated export as a complete one.
 func (a *HTTPAdapter) Export(w http.ResponseWriter, r *http.Request) {
 	var req ExportRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -90,15 +276,65 @@ func (a *HTTPAdapter) Export(w http.ResponseWriter, r *http.Request) {
 	utoken := r.Header.Get(ConseeTokenHeaderKey)
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
 
-	b, err := a.a2.Export(ctx, &req)
+	// a large export may take longer than the default write timeout of the server
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	ew := &exportWriter{w: w, format: req.Format, encrypted: req.Passphrase != ""}
+	err = a.a2.Export(ctx, &req, ew)
+	if err == nil && !ew.written {
+		// make sure headers are sent even if nothing is exported
+		ew.Write(nil)
+	}
+	if err != nil {
+		if !ew.written {
+			errorResponse(w, err)
+			return
+		}
+		slog.Error("failed to stream export", "error", err)
+		// the connection is reset instead of ending the chunked response
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportWriter sets headers on the first write,
+// so that errors before it could still be responded with a status.
+// Content-Length is unknown, so the response is chunked.
+type exportWriter struct {
+	w         http.ResponseWriter
+	format    string
+	encrypted bool
+	written   bool
+}
+
+func (ew *exportWriter) Write(p []byte) (int, error) {
+	if !ew.written {
+		ew.written = true
+		contentType, ext := "application/zip", "."+ew.format
+		switch ew.format {
+		case "json":
+			contentType = "application/json"
+		case "json-tree":
+			contentType, ext = "application/json", ".json"
+		case "yaml":
+			contentType = "application/yaml"
+		case "hcl", "properties", "env":
+			contentType = "text/plain; charset=utf-8"
+		}
+		if ew.encrypted {
+			contentType, ext = "application/octet-stream", ext+encrypt.Ext
+		}
+		now := time.Now().Format("20060102-150405")
+		ew.w.Header().Set("Content-Type", contentType)
+		ew.w.Header().Set("Content-Disposition", "attachment; filename=consee-export-"+now+ext)
+		ew.w.WriteHeader(http.StatusOK)
+	}
+	return ew.w.Write(p)
+}
+
+func (a *HTTPAdapter) ListAuditRecords(w http.ResponseWriter, r *http.Request) {
+	records, err := a.adminService.ListAuditRecords(r.Context())
 	if err != nil {
 		errorResponse(w, err)
 		return
 	}
-	now := time.Now().Format("20060102-150405")
-	// 设置响应头
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", "attachment; filename=consee-export-"+now+".zip")
-	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
-	w.Write(b)
+	response(w, records)
 }
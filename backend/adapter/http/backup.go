@@ -0,0 +1,62 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *HTTPAdapter) ListBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := a.backupService.ListBackups(r.Context())
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, backups)
+}
+
+// CreateBackup runs a backup immediately with the token of the admin user.
+func (a *HTTPAdapter) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	backup, err := a.backupService.Backup(ctx)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, backup)
+}
+
+// RestoreBackup imports a backup the same way as uploading it.
+// Query parameters: "dryrun=1", "on_conflict=skip|replace|rename|newer", "rename_suffix".
+// Form fields: "private_key", the base64 encoded private key to restore encrypted backups,
+// and "overrides", a json list of ImportOverride.
+func (a *HTTPAdapter) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+	req := &RestoreBackupRequest{
+		Dryrun:       r.URL.Query().Get("dryrun") == "1",
+		OnConflict:   OnConflictPolicy(r.URL.Query().Get("on_conflict")),
+		RenameSuffix: r.URL.Query().Get("rename_suffix"),
+		PrivateKey:   r.PostFormValue("private_key"),
+	}
+	if v := r.PostFormValue("overrides"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Overrides); err != nil {
+			errorResponse(w, &StatusError{Err: err, Process: "decoding overrides", Status: http.StatusBadRequest})
+			return
+		}
+	}
+	resp, err := a.backupService.RestoreBackup(ctx, chi.URLParam(r, "name"), req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, resp)
+}
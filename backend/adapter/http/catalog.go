@@ -0,0 +1,162 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/go-chi/chi/v5"
+)
+
+const ConseeIndexHeaderKey = "G-Consee-Index"
+
+// catalogContext builds query options from the token and the query string.
+//
+// Supported query parameters:
+//   - dc: datacenter
+//   - filter: go-bexpr filter expression
+//   - near: node name to sort by round trip time ("_agent" for the agent itself)
+//   - node-meta: "key:value", could be repeated
+//   - stale: allow any server to serve the read
+//   - index, wait: blocking query; wait is a duration like "30s"
+func catalogContext(r *http.Request) (context.Context, error) {
+	query := r.URL.Query()
+	q := &consul.QueryOptions{
+		Token:      r.Header.Get(ConseeTokenHeaderKey),
+		Datacenter: query.Get("dc"),
+		Filter:     query.Get("filter"),
+		Near:       query.Get("near"),
+		AllowStale: query.Has("stale"),
+	}
+	for _, meta := range query["node-meta"] {
+		k, v, ok := strings.Cut(meta, ":")
+		if !ok {
+			return nil, &StatusError{Err: errInvalidQuery, Process: "parsing node-meta", Status: http.StatusBadRequest}
+		}
+		if q.NodeMeta == nil {
+			q.NodeMeta = map[string]string{}
+		}
+		q.NodeMeta[k] = v
+	}
+	if index := query.Get("index"); index != "" {
+		i, err := strconv.ParseUint(index, 10, 64)
+		if err != nil {
+			return nil, &StatusError{Err: err, Process: "parsing index", Status: http.StatusBadRequest}
+		}
+		q.WaitIndex = i
+	}
+	if wait := query.Get("wait"); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil {
+			return nil, &StatusError{Err: err, Process: "parsing wait", Status: http.StatusBadRequest}
+		}
+		q.WaitTime = d
+	}
+	return consul.ContextWithQueryOptions(r.Context(), q), nil
+}
+
+// indexedResponse writes the consul index in header before the data,
+// so that the client could use it in its next blocking query.
+func indexedResponse(w http.ResponseWriter, data any, index uint64) {
+	w.Header().Set(ConseeIndexHeaderKey, strconv.FormatUint(index, 10))
+	response(w, data)
+}
+
+func (a *HTTPAdapter) ListDatacenters(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	dcs, err := a.catalogService.ListDatacenters(ctx)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, dcs)
+}
+
+func (a *HTTPAdapter) ListServices(w http.ResponseWriter, r *http.Request) {
+	ctx, err := catalogContext(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	services, index, err := a.catalogService.ListServices(ctx)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	indexedResponse(w, services, index)
+}
+
+// ListServiceInstances lists instances of a service.
+// Besides catalogContext parameters, it also accepts "tag" (could be repeated), "passing"
+// and "merge-central-config".
+func (a *HTTPAdapter) ListServiceInstances(w http.ResponseWriter, r *http.Request) {
+	ctx, err := catalogContext(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	query := r.URL.Query()
+	options := ListServiceInstancesOptions{
+		Tags:        query["tag"],
+		PassingOnly: query.Get("passing") == "1",
+
+		MergeCentralConfig: query.Has("merge-central-config"),
+	}
+	instances, index, err := a.catalogService.ListServiceInstances(ctx, chi.URLParam(r, "name"), options)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	indexedResponse(w, instances, index)
+}
+
+func (a *HTTPAdapter) ListNodes(w http.ResponseWriter, r *http.Request) {
+	ctx, err := catalogContext(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	nodes, index, err := a.catalogService.ListNodes(ctx)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	indexedResponse(w, nodes, index)
+}
+
+func (a *HTTPAdapter) ReadNode(w http.ResponseWriter, r *http.Request) {
+	ctx, err := catalogContext(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	node, index, err := a.catalogService.ReadNode(ctx, chi.URLParam(r, "name"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	indexedResponse(w, node, index)
+}
+
+// ListChecks lists checks in the state given by query parameter "state" (default "any").
+func (a *HTTPAdapter) ListChecks(w http.ResponseWriter, r *http.Request) {
+	ctx, err := catalogContext(r)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	checks, index, err := a.catalogService.ListChecks(ctx, r.URL.Query().Get("state"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	indexedResponse(w, checks, index)
+}
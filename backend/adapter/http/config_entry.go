@@ -0,0 +1,80 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *HTTPAdapter) ListConfigEntries(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	entries, err := a.configEntryService.ListConfigEntries(ctx, chi.URLParam(r, "kind"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, entries)
+}
+
+func (a *HTTPAdapter) ReadConfigEntry(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	entry, err := a.configEntryService.ReadConfigEntry(ctx, chi.URLParam(r, "kind"), chi.URLParam(r, "name"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, entry)
+}
+
+func (a *HTTPAdapter) WriteConfigEntry(w http.ResponseWriter, r *http.Request) {
+	var req WriteConfigEntryRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithWriteOptions(r.Context(), &consul.WriteOptions{Token: utoken})
+	err = a.configEntryService.WriteConfigEntry(ctx, &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+}
+
+func (a *HTTPAdapter) DeleteConfigEntry(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithWriteOptions(r.Context(), &consul.WriteOptions{Token: utoken})
+	err := a.configEntryService.DeleteConfigEntry(ctx, chi.URLParam(r, "kind"), chi.URLParam(r, "name"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+}
+
+// DiffConfigEntry compares the current entry with the proposed one in body.
+// Nothing is written.
+func (a *HTTPAdapter) DiffConfigEntry(w http.ResponseWriter, r *http.Request) {
+	var req DiffConfigEntryRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	diff, err := a.configEntryService.DiffConfigEntry(ctx, chi.URLParam(r, "kind"), chi.URLParam(r, "name"), &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, diff)
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"net/http"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// RotateEncryption re-encrypts values under "prefix" in query with the current master key.
+func (a *HTTPAdapter) RotateEncryption(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+	result, err := a.encryptionService.Rotate(ctx, r.URL.Query().Get("prefix"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, result)
+}
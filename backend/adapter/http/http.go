@@ -90,15 +90,87 @@ type HTTPAdapter struct {
 	kvService    service.KVService
 	aclService   service.ACLService
 	adminService service.AdminService
+
+	// optional services; routes are registered only if they are set
+	catalogService   service.CatalogService
+	intentionService service.IntentionService
+
+	configEntryService service.ConfigEntryService
+	snapshotService    service.SnapshotService
+	backupService      service.BackupService
+	syncService        service.SyncService
+	promoteService     service.PromoteService
+	schemaService      service.SchemaService
+	lockService        service.LockService
+	secretService      service.SecretService
+	encryptionService  service.EncryptionService
+
+	// maxImportSize is the max size of import files in bytes, 0 means no limit
+	maxImportSize int64
+}
+
+type AdapterOption func(*HTTPAdapter)
+
+func WithCatalogService(s service.CatalogService) AdapterOption {
+	return func(a *HTTPAdapter) { a.catalogService = s }
+}
+
+func WithIntentionService(s service.IntentionService) AdapterOption {
+	return func(a *HTTPAdapter) { a.intentionService = s }
+}
+
+func WithConfigEntryService(s service.ConfigEntryService) AdapterOption {
+	return func(a *HTTPAdapter) { a.configEntryService = s }
+}
+
+func WithSnapshotService(s service.SnapshotService) AdapterOption {
+	return func(a *HTTPAdapter) { a.snapshotService = s }
+}
+
+func WithBackupService(s service.BackupService) AdapterOption {
+	return func(a *HTTPAdapter) { a.backupService = s }
+}
+
+func WithSyncService(s service.SyncService) AdapterOption {
+	return func(a *HTTPAdapter) { a.syncService = s }
 }
 
-func NewAdapter(a2 service.All, kvService service.KVService, aclService service.ACLService, adminService service.AdminService) *HTTPAdapter {
-	return &HTTPAdapter{
+func WithPromoteService(s service.PromoteService) AdapterOption {
+	return func(a *HTTPAdapter) { a.promoteService = s }
+}
+
+func WithSchemaService(s service.SchemaService) AdapterOption {
+	return func(a *HTTPAdapter) { a.schemaService = s }
+}
+
+func WithLockService(s service.LockService) AdapterOption {
+	return func(a *HTTPAdapter) { a.lockService = s }
+}
+
+func WithSecretService(s service.SecretService) AdapterOption {
+	return func(a *HTTPAdapter) { a.secretService = s }
+}
+
+func WithEncryptionService(s service.EncryptionService) AdapterOption {
+	return func(a *HTTPAdapter) { a.encryptionService = s }
+}
+
+// WithMaxImportSize limits the size of import files in bytes, 0 means no limit.
+func WithMaxImportSize(size int64) AdapterOption {
+	return func(a *HTTPAdapter) { a.maxImportSize = size }
+}
+
+func NewAdapter(a2 service.All, kvService service.KVService, aclService service.ACLService, adminService service.AdminService, options ...AdapterOption) *HTTPAdapter {
+	a := &HTTPAdapter{
 		a2:           a2,
 		kvService:    kvService,
 		aclService:   aclService,
 		adminService: adminService,
 	}
+	for _, op := range options {
+		op(a)
+	}
+	return a
 }
 
 // func (a *HTTPAdapter) KVHandler() http.Handler {
@@ -153,19 +225,99 @@ func (a *HTTPAdapter) chiHandler() *chi.Mux {
 				sub.Use(a.CheckUserToken, a.CheckAdminToken)
 				sub.Post("/export", a.Export)
 				sub.Post("/import", a.Import)
+				sub.Get("/audit", a.ListAuditRecords)
+				if a.snapshotService != nil {
+					sub.Get("/snapshot", a.SaveSnapshot)
+					sub.Put("/snapshot", a.RestoreSnapshot)
+				}
+				if a.backupService != nil {
+					sub.Get("/backups", a.ListBackups)
+					sub.Post("/backups", a.CreateBackup)
+					sub.Post("/backups/{name}/restore", a.RestoreBackup)
+				}
+				if a.syncService != nil {
+					sub.Post("/sync/commit", a.SyncCommit)
+					sub.Post("/sync/apply", a.SyncApply)
+				}
+				if a.schemaService != nil {
+					sub.Get("/schemas", a.ListSchemas)
+					sub.Put("/schemas", a.WriteSchema)
+					sub.Delete("/schemas", a.DeleteSchema)
+				}
+				if a.lockService != nil {
+					sub.Delete("/locks", a.ForceReleaseLock)
+				}
+				if a.secretService != nil {
+					sub.Get("/secrets", a.ListSecrets)
+					sub.Put("/secrets", a.WriteSecret)
+					sub.Delete("/secrets", a.DeleteSecret)
+				}
+				if a.encryptionService != nil {
+					sub.Post("/encryption/rotate", a.RotateEncryption)
+				}
 			})
 			rApiV0.Route("/kv", func(kv chi.Router) {
 				kv.Use(a.CheckUserToken)
 				kv.Get("/keys", a.ListKeys)
+				kv.Get("/search", a.SearchKV)
 				kv.Get("/value/{b64key}", a.GetKV)
+				kv.Get("/raw/{b64key}", a.GetRawKV)
+				kv.Get("/lint/{b64key}", a.LintKV)
 				kv.Get("/valuetype/{b64key}", a.GetValueType)
 				kv.Put("/valuetype/{b64key}", a.UpdateValueType)
 				kv.Post("/value", a.CreateKV)
 				kv.Put("/value/{b64key}", a.UpdateKV)
 				kv.Put("/value-type/{b64key}", a.UpdateKVValueType)
 				kv.Delete("/value/{b64key}", a.DeleteKV)
+				kv.Get("/delete-preview/{b64key}", a.DeletePreviewKV)
+				kv.Post("/move", a.MoveKV)
+				kv.Post("/copy", a.CopyKV)
 				kv.Put("/batch", a.checkAdminToken(http.HandlerFunc(a.BatchUpdateKV)))
+				if a.promoteService != nil {
+					kv.Post("/diff", a.DiffKV)
+					kv.Post("/promote", a.PromoteKV)
+				}
+				if a.lockService != nil {
+					kv.Post("/checkout", a.CheckoutKV)
+					kv.Put("/checkout/{session}", a.RenewCheckout)
+					kv.Delete("/checkout/{session}", a.ReleaseCheckout)
+					kv.Get("/locks", a.ListLocks)
+				}
+				if a.secretService != nil {
+					kv.Post("/reveal/{b64key}", a.RevealKV)
+				}
 			})
+			if a.catalogService != nil {
+				rApiV0.Route("/catalog", func(catalog chi.Router) {
+					catalog.Use(a.CheckUserToken)
+					catalog.Get("/datacenters", a.ListDatacenters)
+					catalog.Get("/services", a.ListServices)
+					catalog.Get("/service/{name}", a.ListServiceInstances)
+					catalog.Get("/nodes", a.ListNodes)
+					catalog.Get("/node/{name}", a.ReadNode)
+					catalog.Get("/checks", a.ListChecks)
+				})
+			}
+			if a.intentionService != nil {
+				rApiV0.Route("/intentions", func(intention chi.Router) {
+					intention.Use(a.CheckUserToken)
+					intention.Get("/", a.ListIntentions)
+					intention.Get("/exact", a.ReadIntention)
+					intention.Put("/exact", a.UpsertIntention)
+					intention.Delete("/exact", a.DeleteIntention)
+					intention.Get("/check", a.CheckIntention)
+				})
+			}
+			if a.configEntryService != nil {
+				rApiV0.Route("/config", func(config chi.Router) {
+					config.Use(a.CheckUserToken)
+					config.Put("/", a.WriteConfigEntry)
+					config.Get("/{kind}", a.ListConfigEntries)
+					config.Get("/{kind}/{name}", a.ReadConfigEntry)
+					config.Delete("/{kind}/{name}", a.DeleteConfigEntry)
+					config.Post("/{kind}/{name}/diff", a.DiffConfigEntry)
+				})
+			}
 			rApiV0.Route("/acl", func(acl chi.Router) {
 				acl.Post("/token-request", a.ApplyToken)
 				acl.Post("/hcl-rule", a.ParseRule)
@@ -183,6 +335,7 @@ func (a *HTTPAdapter) chiHandler() *chi.Mux {
 					sub.Get("/policy/{b64name}", a.ReadACLPolicy)
 					sub.Put("/policy/{b64name}", a.UpdatePolicyRule)
 					sub.Delete("/policy/{b64name}", a.DeleteACLPolicy)
+					sub.Get("/policy-delete-preview/{b64name}", a.PreviewDeleteACLPolicy)
 
 					sub.Get("/roles", a.ListACLRoles)
 					sub.Post("/role", a.CreateACLRole)
@@ -0,0 +1,85 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// ListIntentions lists all intentions ordered by precedence.
+// If "by" (source or destination) and "name" are given in query,
+// only intentions that apply to the service are listed.
+func (a *HTTPAdapter) ListIntentions(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	var (
+		intentions []Intention
+		err        error
+	)
+	if by := r.URL.Query().Get("by"); by != "" {
+		intentions, err = a.intentionService.MatchIntentions(ctx, by, r.URL.Query().Get("name"))
+	} else {
+		intentions, err = a.intentionService.ListIntentions(ctx)
+	}
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, intentions)
+}
+
+func (a *HTTPAdapter) ReadIntention(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	intention, err := a.intentionService.ReadIntention(ctx, r.URL.Query().Get("source"), r.URL.Query().Get("destination"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, intention)
+}
+
+func (a *HTTPAdapter) UpsertIntention(w http.ResponseWriter, r *http.Request) {
+	var req Intention
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithWriteOptions(r.Context(), &consul.WriteOptions{Token: utoken})
+	err = a.intentionService.UpsertIntention(ctx, &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *HTTPAdapter) DeleteIntention(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithWriteOptions(r.Context(), &consul.WriteOptions{Token: utoken})
+	err := a.intentionService.DeleteIntention(ctx, r.URL.Query().Get("source"), r.URL.Query().Get("destination"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckIntention responds with true if the source is allowed to connect to the destination, otherwise false.
+func (a *HTTPAdapter) CheckIntention(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	allowed, err := a.intentionService.CheckIntention(ctx, r.URL.Query().Get("source"), r.URL.Query().Get("destination"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, allowed)
+}
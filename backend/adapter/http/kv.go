@@ -4,53 +4,156 @@
 package httpadapter
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
 
 	. "github.com/FlyingOnion/consee/backend/common"
 	"github.com/FlyingOnion/consee/backend/consul"
 	"github.com/go-chi/chi/v5"
 )
 
+// ListKeys lists all keys, or keys with the "flags" in query.
+// With "violations=1" in query, it responds with KVKeyList, which also lists keys violating their schemas.
 func (a *HTTPAdapter) ListKeys(w http.ResponseWriter, r *http.Request) {
 	utoken := r.Header.Get(ConseeTokenHeaderKey)
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
-	keys, err := a.kvService.ListKeys(ctx)
+	var keys []string
+	var err error
+	if v := r.URL.Query().Get("flags"); v != "" {
+		flags, perr := strconv.ParseUint(v, 10, 64)
+		if perr != nil {
+			errorResponse(w, &StatusError{Err: perr, Process: "parsing flags", Status: http.StatusBadRequest})
+			return
+		}
+		keys, err = a.kvService.ListKeysByFlags(ctx, flags)
+	} else {
+		keys, err = a.kvService.ListKeys(ctx)
+	}
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	if r.URL.Query().Get("violations") != "1" {
+		response(w, keys)
+		return
+	}
+	list := KVKeyList{Keys: keys, Violations: []KVSchemaViolation{}}
+	if a.schemaService != nil {
+		violations, err := a.schemaService.Violations(ctx, "")
+		if err != nil {
+			errorResponse(w, err)
+			return
+		}
+		listed := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			listed[key] = true
+		}
+		for _, v := range violations {
+			if listed[v.Key] {
+				list.Violations = append(list.Violations, v)
+			}
+		}
+	}
+	response(w, list)
+}
+
+// SearchKV matches keys and values under "prefix" with "q" in query.
+// "mode" is key or value (case-insensitive substring, value by default), or regex for both.
+// "limit" is the max number of matched keys.
+// Values of secrets are searched only with "reveal=1" in query, which requires an admin token.
+func (a *HTTPAdapter) SearchKV(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	query := r.URL.Query()
+	req := &KVSearchRequest{
+		Query:         query.Get("q"),
+		Prefix:        query.Get("prefix"),
+		Mode:          query.Get("mode"),
+		RevealSecrets: query.Get("reveal") == "1",
+	}
+	if err := a.checkReveal(r, req.RevealSecrets); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			errorResponse(w, &StatusError{Err: err, Process: "parsing limit", Status: http.StatusBadRequest})
+			return
+		}
+		req.Limit = limit
+	}
+	result, err := a.kvService.Search(ctx, req)
 	if err != nil {
 		errorResponse(w, err)
 		return
 	}
-	response(w, keys)
+	response(w, result)
 }
 
 func (a *HTTPAdapter) GetKV(w http.ResponseWriter, r *http.Request) {
+	kv, ok := a.getKV(w, r)
+	if !ok {
+		return
+	}
+	if a.isSecret(r, kv.Key) {
+		kv.Mask()
+	}
+	kv.EncodeValue()
+	response(w, kv)
+}
+
+// GetRawKV downloads the value as it is, which is useful for binary values. Secrets could not be downloaded.
+func (a *HTTPAdapter) GetRawKV(w http.ResponseWriter, r *http.Request) {
+	kv, ok := a.getKV(w, r)
+	if !ok {
+		return
+	}
+	if a.isSecret(r, kv.Key) {
+		errorResponse(w, &StatusError{Err: errors.New("the value is a secret, reveal it instead"), Process: "downloading value", Status: http.StatusForbidden})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(kv.Key)}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(kv.Value)))
+	io.WriteString(w, kv.Value)
+}
+
+// getKV reads the key, or its history version "v" in query.
+// The error response is written if ok is false.
+func (a *HTTPAdapter) getKV(w http.ResponseWriter, r *http.Request) (kv *GetValueResponse, ok bool) {
 	utoken := r.Header.Get(ConseeTokenHeaderKey)
 	b64key := chi.URLParam(r, "b64key")
 
 	k, err := base64.StdEncoding.DecodeString(b64key)
 	if err != nil {
 		errorResponse(w, &StatusError{Err: err, Process: "decoding b64key", Status: http.StatusBadRequest})
-		return
+		return nil, false
 	}
 	// for history reading, we should check if the user has access to this kv
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
-	kv, err := a.kvService.Get(ctx, string(k))
+	kv, err = a.kvService.Get(ctx, string(k))
 	if err != nil {
 		errorResponse(w, err)
-		return
+		return nil, false
 	}
 	v := r.URL.Query().Get("v")
 	if v != "" {
 		hv, err := a.adminService.GetKVHistoryValue(ctx, b64key, v)
 		if err != nil {
 			errorResponse(w, err)
-			return
+			return nil, false
 		}
 		kv.Value = hv
 	}
-	response(w, kv)
+	return kv, true
 }
 
 func (a *HTTPAdapter) GetValueType(w http.ResponseWriter, r *http.Request) {
@@ -127,9 +230,14 @@ func (a *HTTPAdapter) UpdateKV(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
 		return
 	}
+	// the mask of a secret is not written back over its value
+	if req.Value == MaskedValue && a.isSecret(r, string(k)) {
+		errorResponse(w, &StatusError{Err: errors.New("the value is masked, reveal it before editing"), Process: "updating value", Status: http.StatusBadRequest})
+		return
+	}
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
 	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
-	err = a.kvService.Update(ctx, string(k), req.Value)
+	err = a.kvService.Update(ctx, string(k), &req)
 	if err != nil {
 		errorResponse(w, err)
 		return
@@ -137,10 +245,76 @@ func (a *HTTPAdapter) UpdateKV(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// MoveKV moves a key or folder. Set "dryrun" in body to list affected keys only.
+func (a *HTTPAdapter) MoveKV(w http.ResponseWriter, r *http.Request) {
+	a.transferKV(w, r, a.kvService.Move)
+}
+
+// CopyKV copies a key or folder. Set "dryrun" in body to list affected keys only.
+func (a *HTTPAdapter) CopyKV(w http.ResponseWriter, r *http.Request) {
+	a.transferKV(w, r, a.kvService.Copy)
+}
+
+func (a *HTTPAdapter) transferKV(w http.ResponseWriter, r *http.Request, transfer func(context.Context, *MoveKVRequest) (*KVMoveResult, error)) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	var req MoveKVRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+	result, err := transfer(ctx, &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, result)
+}
+
+// LintKV checks the syntax of the value by its value type.
+func (a *HTTPAdapter) LintKV(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	b64key := chi.URLParam(r, "b64key")
+
+	k, err := base64.StdEncoding.DecodeString(b64key)
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding b64key", Status: http.StatusBadRequest})
+		return
+	}
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	result, err := a.kvService.Lint(ctx, string(k))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, result)
+}
+
 func (a *HTTPAdapter) UpdateKVValueType(w http.ResponseWriter, r *http.Request) {
 	// utoken := r.Header.Get(ConseeTokenHeaderKey)
 }
 
+// DeletePreviewKV lists the keys which would be deleted with the key or folder.
+// Deleting a folder requires the previewed count as "count" in query.
+func (a *HTTPAdapter) DeletePreviewKV(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	b64key := chi.URLParam(r, "b64key")
+
+	k, err := base64.StdEncoding.DecodeString(b64key)
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding b64key", Status: http.StatusBadRequest})
+		return
+	}
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	preview, err := a.kvService.DeletePreview(ctx, string(k))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, preview)
+}
+
 func (a *HTTPAdapter) DeleteKV(w http.ResponseWriter, r *http.Request) {
 	utoken := r.Header.Get(ConseeTokenHeaderKey)
 	b64key := chi.URLParam(r, "b64key")
@@ -153,7 +327,9 @@ func (a *HTTPAdapter) DeleteKV(w http.ResponseWriter, r *http.Request) {
 
 	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
 	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
-	err = a.kvService.Delete(ctx, string(k))
+	// count is required for folders, see DeletePreviewKV
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	err = a.kvService.Delete(ctx, string(k), count)
 	if err != nil {
 		errorResponse(w, err)
 		return
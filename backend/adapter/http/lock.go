@@ -0,0 +1,71 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/go-chi/chi/v5"
+)
+
+func lockContext(r *http.Request) context.Context {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	return consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+}
+
+// CheckoutKV locks a key or folder for editing. Renew the returned session before its TTL expires.
+func (a *HTTPAdapter) CheckoutKV(w http.ResponseWriter, r *http.Request) {
+	var req CheckoutKVRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	lock, err := a.lockService.Checkout(lockContext(r), &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, lock)
+}
+
+func (a *HTTPAdapter) RenewCheckout(w http.ResponseWriter, r *http.Request) {
+	if err := a.lockService.Renew(lockContext(r), chi.URLParam(r, "session")); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReleaseCheckout releases the lock of "key" in query held by the session.
+func (a *HTTPAdapter) ReleaseCheckout(w http.ResponseWriter, r *http.Request) {
+	if err := a.lockService.Release(lockContext(r), r.URL.Query().Get("key"), chi.URLParam(r, "session")); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListLocks lists locked keys under "prefix" in query.
+func (a *HTTPAdapter) ListLocks(w http.ResponseWriter, r *http.Request) {
+	locks, err := a.lockService.ListLocks(lockContext(r), r.URL.Query().Get("prefix"))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, locks)
+}
+
+// ForceReleaseLock destroys the session holding the lock of "key" in query.
+func (a *HTTPAdapter) ForceReleaseLock(w http.ResponseWriter, r *http.Request) {
+	if err := a.lockService.ForceRelease(lockContext(r), r.URL.Query().Get("key")); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"os"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// decodePromoteRequest decodes the json body into v, or the multipart form with an uploaded export as the source,
+// whose "request" field is the json request. The returned function removes the upload.
+func (a *HTTPAdapter) decodePromoteRequest(w http.ResponseWriter, r *http.Request, v any, diff *KVDiffRequest) (func(), error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			return nil, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest}
+		}
+		return func() {}, nil
+	}
+	upload, err := a.spoolImportFile(w, r)
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		upload.file.Close()
+		os.Remove(upload.file.Name())
+	}
+	if err = json.Unmarshal([]byte(upload.fields["request"]), v); err != nil {
+		cleanup()
+		return nil, &StatusError{Err: err, Process: "decoding request", Status: http.StatusBadRequest}
+	}
+	fi, err := upload.file.Stat()
+	if err != nil {
+		cleanup()
+		return nil, &StatusError{Err: err, Process: "reading file", Status: http.StatusInternalServerError}
+	}
+	diff.Archive = &ImportRequest{
+		Format:     upload.format,
+		File:       upload.file,
+		Size:       fi.Size(),
+		Passphrase: upload.fields["passphrase"],
+	}
+	return cleanup, nil
+}
+
+// DiffKV compares keys under a prefix in two datacenters, or in an uploaded export and a datacenter.
+// The body is a json KVDiffRequest, or a multipart form of the export in "file" and the request in "request".
+// Lines of secrets are masked unless "reveal_secrets" is set, which requires an admin token.
+func (a *HTTPAdapter) DiffKV(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	var req KVDiffRequest
+	cleanup, err := a.decodePromoteRequest(w, r, &req, &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	defer cleanup()
+	if err := a.checkReveal(r, req.RevealSecrets); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	diff, err := a.promoteService.Diff(ctx, &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, diff)
+}
+
+// PromoteKV applies the selected changes of a diff to the target datacenter in a transaction.
+// The body is the same as DiffKV, with the selected items in "changes".
+func (a *HTTPAdapter) PromoteKV(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+	var req PromoteKVRequest
+	cleanup, err := a.decodePromoteRequest(w, r, &req, &req.KVDiffRequest)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	defer cleanup()
+	if err := a.checkReveal(r, req.RevealSecrets); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	diff, err := a.promoteService.Promote(ctx, &req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, diff)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+func (a *HTTPAdapter) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas, err := a.schemaService.ListSchemas(r.Context())
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, schemas)
+}
+
+// WriteSchema attaches the schema in body to its prefix, replacing the existing one.
+func (a *HTTPAdapter) WriteSchema(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	var req KVSchema
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	if err := a.schemaService.WriteSchema(ctx, &req); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSchema detaches the schema of "prefix" in query.
+func (a *HTTPAdapter) DeleteSchema(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	if err := a.schemaService.DeleteSchema(ctx, r.URL.Query().Get("prefix")); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *HTTPAdapter) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	secrets, err := a.secretService.ListSecrets(r.Context())
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, secrets)
+}
+
+// WriteSecret marks "prefix" in query, a key or a folder, as a secret.
+func (a *HTTPAdapter) WriteSecret(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	if err := a.secretService.WriteSecret(ctx, r.URL.Query().Get("prefix")); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSecret unmarks "prefix" in query.
+func (a *HTTPAdapter) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	if err := a.secretService.DeleteSecret(ctx, r.URL.Query().Get("prefix")); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevealKV returns the plain value of a secret. Every reveal is recorded in the audit log.
+func (a *HTTPAdapter) RevealKV(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	k, err := base64.StdEncoding.DecodeString(chi.URLParam(r, "b64key"))
+	if err != nil {
+		errorResponse(w, &StatusError{Err: err, Process: "decoding b64key", Status: http.StatusBadRequest})
+		return
+	}
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	kv, err := a.secretService.Reveal(ctx, string(k))
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	kv.EncodeValue()
+	response(w, kv)
+}
+
+// isSecret reports whether the value of key should be masked.
+func (a *HTTPAdapter) isSecret(r *http.Request, key string) bool {
+	return a.secretService != nil && a.secretService.IsSecret(r.Context(), key)
+}
+
+// checkReveal checks that the caller is an admin if secrets are asked for.
+func (a *HTTPAdapter) checkReveal(r *http.Request, reveal bool) error {
+	if !reveal {
+		return nil
+	}
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	return a.aclService.CheckAdmin(consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken}))
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// SaveSnapshot streams a consul snapshot to the client.
+// Use "stale" in query to allow any server to take the snapshot.
+func (a *HTTPAdapter) SaveSnapshot(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{
+		Token:      utoken,
+		AllowStale: r.URL.Query().Has("stale"),
+	})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+	rc, err := a.snapshotService.SaveSnapshot(ctx)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	defer rc.Close()
+
+	now := time.Now().Format("20060102-150405")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=consul-"+now+".snap")
+	if _, err = io.Copy(w, rc); err != nil {
+		// headers have been sent, nothing could be done but logging
+		slog.Error("failed to stream snapshot", "error", err)
+	}
+}
+
+// RestoreSnapshot restores consul from the snapshot in the multipart "file" field.
+// "confirm=1" is required in query.
+func (a *HTTPAdapter) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	// snapshots could be large, the default read timeout of the server is not enough
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	confirm := r.URL.Query().Get("confirm") == "1"
+	mr, err := r.MultipartReader()
+	if err != nil {
+		errorResponse(w, &StatusError{Err: errInvalidFile, Process: "parsing file", Status: http.StatusBadRequest})
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			// io.EOF means there is no file field
+			errorResponse(w, &StatusError{Err: errInvalidFile, Process: "parsing file", Status: http.StatusBadRequest})
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		utoken := r.Header.Get(ConseeTokenHeaderKey)
+		ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+		ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+		// the part is streamed to consul without being buffered
+		err = a.snapshotService.RestoreSnapshot(ctx, part, confirm)
+		part.Close()
+		if err != nil {
+			errorResponse(w, err)
+		}
+		return
+	}
+}
@@ -18,6 +18,8 @@ var (
 	errIdEmpty           = errors.New("id is empty")
 	errInvalidFile       = errors.New("invalid file")
 	errInvalidFileFormat = errors.New("invalid file format")
+	errFileTooLarge      = errors.New("file is too large")
+	errInvalidQuery      = errors.New("invalid query parameter")
 )
 
 func unknownError() *StatusError {
@@ -65,6 +67,10 @@ func NewStatusError(err error) *StatusError {
 			return &StatusError{Err: err, Status: http.StatusBadRequest}
 		case service.DomainErrorCodePermissionDenied:
 			return &StatusError{Err: err, Status: http.StatusForbidden}
+		case service.DomainErrorCodeConflict:
+			return &StatusError{Err: err, Status: http.StatusConflict}
+		case service.DomainErrorCodePassphraseRequired:
+			return &StatusError{Err: err, Status: http.StatusPreconditionRequired}
 		case service.DomainErrorCodeInternalError:
 			return &StatusError{Err: err, Status: http.StatusInternalServerError}
 		}
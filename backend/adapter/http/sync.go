@@ -0,0 +1,50 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package httpadapter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// SyncCommit mirrors the synced prefix into the git working copy immediately.
+func (a *HTTPAdapter) SyncCommit(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	result, err := a.syncService.Commit(ctx)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, result)
+}
+
+// SyncApply applies the git working copy to consul. Use "dryrun=1" in query to preview changes.
+// The body could be the confirmed preview, so that only the previewed changes are applied.
+func (a *HTTPAdapter) SyncApply(w http.ResponseWriter, r *http.Request) {
+	utoken := r.Header.Get(ConseeTokenHeaderKey)
+	ctx := consul.ContextWithQueryOptions(r.Context(), &consul.QueryOptions{Token: utoken})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{Token: utoken})
+	req := &ApplySyncRequest{Dryrun: r.URL.Query().Get("dryrun") == "1"}
+	var preview SyncResult
+	err := json.NewDecoder(r.Body).Decode(&preview)
+	switch {
+	case err == nil:
+		req.Preview = &preview
+	case !errors.Is(err, io.EOF):
+		errorResponse(w, &StatusError{Err: err, Process: "decoding body", Status: http.StatusBadRequest})
+		return
+	}
+	result, err := a.syncService.Apply(ctx, req)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	response(w, result)
+}
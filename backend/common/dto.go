@@ -4,24 +4,73 @@
 package common
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
 	"github.com/FlyingOnion/consee/backend/buffer"
 )
 
+// KVEncodingBase64 is the encoding of values which are not valid UTF-8, e.g. certificates or gzip blobs,
+// since JSON strings could only carry UTF-8.
+const KVEncodingBase64 = "base64"
+
 type KeyValue struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
+	// Encoding is "base64" if Value is base64-encoded, or "" for a plain value.
+	Encoding    string `json:"encoding,omitempty"`
+	CreateIndex uint64 `json:"create_index,omitempty"`
+	ModifyIndex uint64 `json:"modify_index,omitempty"`
+	LockIndex   uint64 `json:"lock_index,omitempty"`
+	// Flags are opaque to consul, and used by other tools, e.g. consul-template.
+	Flags uint64 `json:"flags"`
+	// Session is the session holding the lock of the key, if any.
+	Session string `json:"session,omitempty"`
+	// Masked is true if the key is a secret and Value is MaskedValue.
+	Masked bool `json:"masked,omitempty"`
+	// Encrypted is true if the value is stored encrypted.
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
+// MaskedValue replaces values of secrets, which are revealed on demand.
+const MaskedValue = "********"
+
 type GetValueResponse KeyValue
 
+// Mask replaces the value with MaskedValue.
+func (kv *GetValueResponse) Mask() {
+	kv.Value, kv.Encoding, kv.Masked = MaskedValue, "", true
+}
+
+// EncodeValue encodes the value with base64 if it is not valid UTF-8.
+func (kv *GetValueResponse) EncodeValue() {
+	if kv.Encoding == "" && !utf8.ValidString(kv.Value) {
+		kv.Value = base64.StdEncoding.EncodeToString([]byte(kv.Value))
+		kv.Encoding = KVEncodingBase64
+	}
+}
+
 type CreateKeyValueRequest struct {
 	Key       string `json:"key"`
 	Value     string `json:"value"`
 	ValueType string `json:"value_type"`
+	// Encoding is "base64" for binary values, whose value type is binary by default.
+	Encoding string `json:"encoding,omitempty"`
+	// Format rewrites the value in the canonical style of its value type before writing.
+	Format bool   `json:"format,omitempty"`
+	Flags  uint64 `json:"flags,omitempty"`
 }
 
 type UpdateValueRequest struct {
-	Value string `json:"value"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"`
+	Format   bool   `json:"format,omitempty"`
+	// Session writes the value with the lock held by the session and ends its checkout, see CheckoutKVRequest.
+	Session string `json:"session,omitempty"`
+	// Flags replaces the flags of the key. They are kept if nil.
+	Flags *uint64 `json:"flags,omitempty"`
 }
 
 type BatchUpdateRequest struct {
@@ -35,6 +84,10 @@ type ImportResponseItem struct {
 	Kind  string `json:"kind"`
 	Param string `json:"param"`
 	Cause string `json:"cause,omitempty"`
+	// Resolution is the policy applied to a conflict.
+	Resolution OnConflictPolicy `json:"resolution,omitempty"`
+	// RenamedTo is the name the item is imported under with the rename policy.
+	RenamedTo string `json:"renamed_to,omitempty"`
 }
 
 type ImportResponse struct {
@@ -46,34 +99,92 @@ type ImportResponse struct {
 type OnConflictPolicy string
 
 const (
-	OnConflictPolicySkip    = "skip"
-	OnConflictPolicyReplace = "replace"
+	// OnConflictPolicySkip keeps the existing item. It's the default policy.
+	OnConflictPolicySkip OnConflictPolicy = "skip"
+	// OnConflictPolicyReplace overwrites the existing item.
+	OnConflictPolicyReplace OnConflictPolicy = "replace"
+	// OnConflictPolicyRename imports the item under its name with a suffix.
+	OnConflictPolicyRename OnConflictPolicy = "rename"
+	// OnConflictPolicyNewer overwrites the existing item only if the ModifyIndex recorded
+	// in the archive is larger, so it's meaningful for archives from the same cluster only.
+	// Items without a recorded ModifyIndex are skipped.
+	OnConflictPolicyNewer OnConflictPolicy = "newer"
+
+	DefaultRenameSuffix = "-imported"
 )
 
+// ImportOverride sets the policy of a single item, identified by Kind and Param of the dryrun response.
+type ImportOverride struct {
+	Kind       string           `json:"kind"`
+	Param      string           `json:"param"`
+	OnConflict OnConflictPolicy `json:"on_conflict"`
+}
+
 type ImportRequest struct {
-	Format      string
-	Dryrun      bool
-	OnConflict  OnConflictPolicy
-	FileContent []byte
+	Format     string
+	Dryrun     bool
+	OnConflict OnConflictPolicy
+	// Overrides take precedence over OnConflict for the items they match.
+	Overrides []ImportOverride
+	// RenameSuffix is used by the rename policy, DefaultRenameSuffix if it's empty.
+	RenameSuffix string
+	// Root is the folder to import into in native formats.
+	Root string
+	// Separator splits keys of flat formats into folders. See ExportRequest.Separator.
+	Separator string
+	// File is the uploaded archive of Size bytes, usually backed by a temporary file.
+	File io.ReaderAt
+	Size int64
+	// Progress is called before each item is imported. It could be nil.
+	Progress func(ImportProgress)
+	// Passphrase opens encrypted archives. For archives encrypted to a public key,
+	// it should be the base64 encoded private key.
+	Passphrase string
+	// Template renders values as templates before they are imported.
+	// Unresolved variables and keys are reported as errors of kind "template".
+	Template bool
+	// Vars are variables of templates, taking precedence over those of VarsFile.
+	Vars map[string]any
+	// VarsFile is the name of a vars file (e.g. "prod") in the configured vars directory.
+	VarsFile string
+}
+
+type ImportProgress struct {
+	Kind    string `json:"kind"`
+	Param   string `json:"param"`
+	Current int    `json:"current"`
+	Total   int    `json:"total"`
 }
 
 // export
 
 type DryrunMetadata struct {
-	Keys     []string  `json:"keys"`
-	Tokens   []ACLLink `json:"tokens"`
-	Policies []string  `json:"policies"`
+	Keys          []string          `json:"keys"`
+	Tokens        []ACLLink         `json:"tokens"`
+	Policies      []string          `json:"policies"`
+	Intentions    []Intention       `json:"intentions"`
+	ConfigEntries []ConfigEntryLink `json:"config_entries"`
+
+	// Archived values, flags, policy rules and rendered config entries, if they are known.
+	// Items identical to the existing ones are not conflicts, since the import skips them.
+	Values              map[string]string `json:"-"`
+	Flags               map[string]uint64 `json:"-"`
+	PolicyRules         map[string]string `json:"-"`
+	ConfigEntryContents map[string]string `json:"-"`
 }
 
 type CompatibleKVMetaList []*CompatibleKVMeta
 
 func (l CompatibleKVMetaList) DryrunMetadata() *DryrunMetadata {
 	keys := make([]string, len(l))
+	values := make(map[string]string, len(l))
 	for i, kv := range l {
 		keys[i] = kv.Key
+		values[kv.Key] = string(kv.Value)
 	}
 	return &DryrunMetadata{
-		Keys: keys,
+		Keys:   keys,
+		Values: values,
 	}
 }
 
@@ -83,17 +194,244 @@ type CompatibleKVMeta struct {
 	Value []byte `json:"value"`
 }
 
+// UnmarshalJSON also accepts values without base64 padding, which were exported by older versions.
+func (m *CompatibleKVMeta) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Key   string `json:"key"`
+		Flags uint64 `json:"flags"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	value, err := base64.StdEncoding.DecodeString(raw.Value)
+	if err != nil {
+		if value, err = base64.RawStdEncoding.DecodeString(raw.Value); err != nil {
+			return err
+		}
+	}
+	m.Key, m.Flags, m.Value = raw.Key, raw.Flags, value
+	return nil
+}
+
+const (
+	KVSearchModeKey   = "key"
+	KVSearchModeValue = "value"
+	KVSearchModeRegex = "regex"
+)
+
+type KVSearchRequest struct {
+	Query  string
+	Prefix string
+	// Mode is key or value to match the query as a case-insensitive substring,
+	// or regex to match keys and values with the query as a regular expression.
+	Mode string
+	// Limit is the max number of matched keys.
+	Limit int
+	// RevealSecrets searches values of secrets, which are skipped by default.
+	RevealSecrets bool
+}
+
+// KVSearchHighlight is a match at [Start, End) bytes of the text.
+type KVSearchHighlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// KVSearchSnippet is a part of a line of the value around matches.
+type KVSearchSnippet struct {
+	// Line is 1-based.
+	Line       int                 `json:"line"`
+	Text       string              `json:"text"`
+	Highlights []KVSearchHighlight `json:"highlights"`
+}
+
+type KVSearchMatch struct {
+	Key           string              `json:"key"`
+	KeyHighlights []KVSearchHighlight `json:"key_highlights,omitempty"`
+	Snippets      []KVSearchSnippet   `json:"snippets,omitempty"`
+}
+
+type KVSearchResponse struct {
+	Matches []KVSearchMatch `json:"matches"`
+	// Scanned is the number of keys scanned.
+	Scanned int `json:"scanned"`
+	// Truncated is true if the scan or the matches are capped, so there may be more matches.
+	Truncated bool `json:"truncated"`
+}
+
+// KVRotateResult is the result of re-encrypting values under Prefix with the master key of KeyID.
+type KVRotateResult struct {
+	Prefix  string          `json:"prefix"`
+	KeyID   string          `json:"key_id"`
+	Rotated int             `json:"rotated"`
+	Errors  []KVRotateError `json:"errors"`
+}
+
+type KVRotateError struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// KVLintError is a syntax error of a value. Line and Column are 1-based, 0 if unknown.
+type KVLintError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+type KVLintResult struct {
+	Key       string `json:"key"`
+	ValueType string `json:"value_type"`
+	// Checked is false if the value type has no parser, so the value is not checked.
+	Checked bool          `json:"checked"`
+	Errors  []KVLintError `json:"errors"`
+	// Formatted is the value in the canonical style, empty if the value type has no formatter or the value is invalid.
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// CheckoutKVRequest locks a key, or the existing keys under a folder, for editing with a session.
+// The checkout ends on a save of any key it locked, on release, or when the session is not renewed within TTL.
+type CheckoutKVRequest struct {
+	Key string `json:"key"`
+	// TTL is a duration from 10s to 24h, 5m by default.
+	TTL string `json:"ttl"`
+}
+
+// KVLock is a key locked by a session.
+type KVLock struct {
+	Key       string `json:"key"`
+	Session   string `json:"session"`
+	LockIndex uint64 `json:"lock_index"`
+	// Holder is the name of the session, which is the token name for checkouts of consee.
+	Holder string `json:"holder"`
+	Node   string `json:"node"`
+	TTL    string `json:"ttl,omitempty"`
+	// Checkout is true if the lock is a checkout of consee, otherwise it's held by other applications, like leader elections.
+	Checkout bool `json:"checkout"`
+}
+
+// KVDeletePreview lists the keys deleted with Key, including itself.
+// Count should be echoed back to delete a folder.
+type KVDeletePreview struct {
+	Key   string   `json:"key"`
+	Keys  []string `json:"keys"`
+	Count int      `json:"count"`
+}
+
+// MoveKVRequest moves or copies Source to Destination. Source is a key, or a folder if it ends with "/",
+// in which case Destination should be a folder as well, and keys under Source are moved or copied under it.
+type MoveKVRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Dryrun      bool   `json:"dryrun"`
+}
+
+type KVMoveItem struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Exists is true if the destination key already exists, which fails the move or copy.
+	// Existing folders are merged.
+	Exists bool `json:"exists,omitempty"`
+}
+
+type KVMoveResult struct {
+	Dryrun bool         `json:"dryrun"`
+	Items  []KVMoveItem `json:"items"`
+}
+
+// KVSchema is a JSON Schema attached to a key or prefix.
+// JSON and YAML values of keys under Prefix are validated against it, and the longest prefix wins.
+type KVSchema struct {
+	Prefix string `json:"prefix"`
+	Schema string `json:"schema"`
+}
+
+// KVSchemaViolation is a key whose current value violates its schema.
+type KVSchemaViolation struct {
+	Key string `json:"key"`
+	// Prefix is the prefix the schema is attached to.
+	Prefix string   `json:"prefix"`
+	Errors []string `json:"errors"`
+}
+
+// KVKeyList is the key list with keys violating their schemas.
+type KVKeyList struct {
+	Keys       []string            `json:"keys"`
+	Violations []KVSchemaViolation `json:"violations"`
+}
+
+const (
+	KVDiffAdded   = "added"
+	KVDiffRemoved = "removed"
+	KVDiffChanged = "changed"
+)
+
+// KVDiffRequest compares keys under Prefix in the source with those in the target.
+type KVDiffRequest struct {
+	Prefix string `json:"prefix"`
+	// Source and Target are datacenters, "" means the datacenter of the agent.
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Archive is an uploaded export used as the source instead of a datacenter.
+	Archive *ImportRequest `json:"-"`
+	// RevealSecrets shows lines of secrets, which are masked by default.
+	RevealSecrets bool `json:"reveal_secrets,omitempty"`
+}
+
+// KVDiffItem is a key that differs between the source and the target.
+// Change is added (only in the source), removed (only in the target) or changed.
+type KVDiffItem struct {
+	Key    string     `json:"key"`
+	Change string     `json:"change"`
+	Lines  []DiffLine `json:"lines,omitempty"`
+	// TargetIndex is the ModifyIndex of the key in the target, 0 if the key is added.
+	TargetIndex uint64 `json:"target_index"`
+	// SourceIndex is the ModifyIndex of the key in the source datacenter,
+	// 0 if the key is removed or the source is an uploaded export.
+	SourceIndex uint64 `json:"source_index"`
+	// Masked is true if the key is a secret and Lines are left out.
+	Masked bool `json:"masked,omitempty"`
+}
+
+type KVDiff struct {
+	Source string       `json:"source"`
+	Target string       `json:"target"`
+	Prefix string       `json:"prefix"`
+	Items  []KVDiffItem `json:"items"`
+}
+
+type PromoteKVRequest struct {
+	KVDiffRequest
+	// Changes are the selected items of the diff. They are applied to the target in a transaction,
+	// which fails if any key in the target is modified since TargetIndex.
+	// Changes whose key is modified in the source since SourceIndex are rejected before that.
+	Changes []KVDiffItem `json:"changes"`
+}
+
 // zip export
 type ExportedKVMeta struct {
 	Name            string   `json:"name"`
 	ValueType       string   `json:"value_type"`
 	HistoryVersions []string `json:"history_versions"`
+	// Flags is nil in archives exported without flags, and flags of existing keys are kept on import.
+	Flags *uint64 `json:"flags,omitempty"`
 }
 
 type ExportMetadata struct {
-	Keys     []ExportedKVMeta `json:"keys" yaml:"keys"`
-	Tokens   []ACLLink        `json:"tokens" yaml:"tokens"`
-	Policies []string         `json:"policies" yaml:"policies"`
+	Keys          []ExportedKVMeta  `json:"keys" yaml:"keys"`
+	Tokens        []ACLLink         `json:"tokens" yaml:"tokens"`
+	Policies      []string          `json:"policies" yaml:"policies"`
+	Intentions    []Intention       `json:"intentions,omitempty" yaml:"intentions,omitempty"`
+	ConfigEntries []ConfigEntryLink `json:"config_entries,omitempty" yaml:"config_entries,omitempty"`
+	// ModifyIndexes records ModifyIndex of kv, policies and tokens at export time,
+	// keyed by ImportItemKey. It's used by the newer policy.
+	ModifyIndexes map[string]uint64 `json:"modify_indexes,omitempty" yaml:"modify_indexes,omitempty"`
+}
+
+// ImportItemKey identifies an item of an archive by its kind and param in import responses.
+func ImportItemKey(kind, param string) string {
+	return kind + ":" + param
 }
 
 func (m *ExportMetadata) DryrunMetadata() *DryrunMetadata {
@@ -102,16 +440,40 @@ func (m *ExportMetadata) DryrunMetadata() *DryrunMetadata {
 		keys[i] = kv.Name
 	}
 	return &DryrunMetadata{
-		Keys:     keys,
-		Tokens:   m.Tokens,
-		Policies: m.Policies,
+		Keys:          keys,
+		Tokens:        m.Tokens,
+		Policies:      m.Policies,
+		Intentions:    m.Intentions,
+		ConfigEntries: m.ConfigEntries,
 	}
 }
 
+// ExportRequest specifies what to export.
+//
+// Exported keys are the union of Keys and keys under Prefixes,
+// or all keys if All is set. Internal keys of consee are never exported.
 type ExportRequest struct {
-	Keys   []string `json:"keys"`
-	Format string   `json:"format"`
-	ACL    bool     `json:"acl"`
+	Keys       []string `json:"keys"`
+	Prefixes   []string `json:"prefixes"`
+	All        bool     `json:"all"`
+	Format     string   `json:"format"`
+	ACL        bool     `json:"acl"`
+	Intentions bool     `json:"intentions"`
+	// ConfigEntries exports config entries of all kinds.
+	// service-intentions entries are skipped if Intentions is also set.
+	ConfigEntries bool `json:"config_entries"`
+	// Passphrase encrypts the archive into a .consee container if it's not empty.
+	Passphrase string `json:"passphrase,omitempty"`
+	// RevealSecrets exports secrets, which are left out by default.
+	RevealSecrets bool `json:"reveal_secrets,omitempty"`
+
+	// Root is trimmed from keys in native formats (yaml, json-tree, hcl, properties and env).
+	// Keys under Root are exported if no key is selected otherwise.
+	Root string `json:"root,omitempty"`
+	// Separator joins folders in flat formats, "." for properties and "__" for env by default.
+	Separator string `json:"separator,omitempty"`
+	// Typed writes booleans and numbers as such in tree formats, instead of strings.
+	Typed bool `json:"typed,omitempty"`
 }
 
 // type KVMeta struct {
@@ -227,12 +589,13 @@ type ACLLink struct {
 }
 
 type ReadTokenResponse struct {
-	AccessorID string         `json:"accessor_id"`
-	SecretID   string         `json:"secret_id"`
-	Policies   []ACLLink      `json:"policies"`
-	Roles      []ACLLink      `json:"roles"`
-	Name       string         `json:"name"`
-	Metadata   *TokenMetadata `json:"metadata"`
+	AccessorID  string         `json:"accessor_id"`
+	SecretID    string         `json:"secret_id"`
+	Policies    []ACLLink      `json:"policies"`
+	Roles       []ACLLink      `json:"roles"`
+	Name        string         `json:"name"`
+	Metadata    *TokenMetadata `json:"metadata"`
+	ModifyIndex uint64         `json:"modify_index,omitempty"`
 }
 
 type CreateTokenRequest struct {
@@ -303,6 +666,8 @@ type ParsedRule struct {
 	Match  string `json:"match"`
 	Param  string `json:"param"`
 	Access string `json:"access"`
+	// Intentions is only available for service rules.
+	Intentions string `json:"intentions,omitempty"`
 }
 
 func (r ParsedRule) MarshalJSON() ([]byte, error) {
@@ -315,6 +680,9 @@ func (r ParsedRule) MarshalJSON() ([]byte, error) {
 		b.WriteString(`","match":"`).WriteJsonSafeString(r.Match).
 			WriteString(`","param":"`).WriteJsonSafeString(r.Param)
 	}
+	if r.Type == "service" && r.Intentions != "" {
+		b.WriteString(`","intentions":"`).WriteJsonSafeString(r.Intentions)
+	}
 	b.WriteString(`"}`)
 	return b.Bytes(), nil
 }
@@ -326,6 +694,25 @@ type ReadPolicyResponse struct {
 	ParsedRules []ParsedRule `json:"parsed_rules"`
 	Rules       string       `json:"rules"`
 	Tokens      []ACLLink    `json:"tokens"`
+	ModifyIndex uint64       `json:"modify_index,omitempty"`
+}
+
+// PolicyDeletePreview lists everything that still references a policy.
+// Consul silently strips a deleted policy from its tokens and roles,
+// so the caller should review it before confirming the deletion.
+type PolicyDeletePreview struct {
+	ID     string    `json:"id"`
+	Name   string    `json:"name"`
+	Tokens []ACLLink `json:"tokens"`
+	Roles  []ACLLink `json:"roles"`
+}
+
+type DeletePolicyRequest struct {
+	// Confirm must be true if the policy is still used by any token or role.
+	Confirm bool
+	// Replacement is the name of the policy attached to every detached token and role.
+	// Policy is detached only if it's empty.
+	Replacement string
 }
 
 type ListRolesOptions struct {
@@ -383,6 +770,244 @@ type TokenApplicationReviewResult struct {
 	Reviewer   string `json:"reviewer"`
 }
 
+// BackupFile is a scheduled backup archive produced by the zip export.
+type BackupFile struct {
+	Name      string   `json:"name"`
+	CreatedAt string   `json:"created_at"`
+	Size      int64    `json:"size"`
+	SHA256    string   `json:"sha256"`
+	Prefixes  []string `json:"prefixes"`
+	Keys      int      `json:"keys"`
+	ACL       bool     `json:"acl"`
+	// Encrypted is true if the backup is encrypted to the configured public key.
+	Encrypted bool `json:"encrypted"`
+}
+
+// BackupManifest is stored as manifest.json beside the backups.
+type BackupManifest struct {
+	Backups []BackupFile `json:"backups"`
+}
+
+type RestoreBackupRequest struct {
+	Dryrun       bool
+	OnConflict   OnConflictPolicy
+	Overrides    []ImportOverride
+	RenameSuffix string
+	// PrivateKey is the base64 encoded private key to restore encrypted backups.
+	PrivateKey string
+}
+
+const (
+	SyncActionCreate = "create"
+	SyncActionUpdate = "update"
+	SyncActionDelete = "delete"
+)
+
+// SyncChange is a change of a key made by sync, in consul or in the git working copy.
+type SyncChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	// ModifyIndex is the index of the key in consul when changes are applied to consul, 0 if it doesn't exist.
+	ModifyIndex uint64 `json:"modify_index,omitempty"`
+	// Lines is the diff of updated values when changes are applied to consul.
+	Lines []DiffLine `json:"lines,omitempty"`
+	// Error is why the change is not made.
+	Error string `json:"error,omitempty"`
+}
+
+type SyncResult struct {
+	// Commit is the hash of the commit written or applied, "" if nothing is committed.
+	Commit  string       `json:"commit"`
+	Dryrun  bool         `json:"dryrun"`
+	Changes []SyncChange `json:"changes"`
+}
+
+type ApplySyncRequest struct {
+	Dryrun bool
+	// Preview is the result of a dryrun confirmed by the user. If it's not nil,
+	// only changes in the preview are applied, with its commit and indexes.
+	Preview *SyncResult
+}
+
+// AuditRecord records a sensitive operation, e.g. restoring a snapshot.
+type AuditRecord struct {
+	// Time is a "yyyy-MM-dd hh:mm:ss" timestamp
+	Time   string `json:"time"`
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// catalog
+
+type HealthCheckInfo struct {
+	Node        string `json:"node"`
+	CheckID     string `json:"check_id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Notes       string `json:"notes,omitempty"`
+	Output      string `json:"output,omitempty"`
+	ServiceID   string `json:"service_id,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+// ServiceSummary is a service with the number of its checks in each state.
+type ServiceSummary struct {
+	Name        string   `json:"name"`
+	Tags        []string `json:"tags"`
+	Passing     int      `json:"passing"`
+	Warning     int      `json:"warning"`
+	Critical    int      `json:"critical"`
+	Maintenance int      `json:"maintenance"`
+}
+
+type ServiceInstance struct {
+	Node        string            `json:"node"`
+	NodeAddress string            `json:"node_address"`
+	Datacenter  string            `json:"datacenter"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Kind        string            `json:"kind,omitempty"`
+	Address     string            `json:"address"`
+	Port        int               `json:"port"`
+	Tags        []string          `json:"tags"`
+	Meta        map[string]string `json:"meta"`
+	// Status is the worst status of all checks of the instance and its node
+	Status string            `json:"status"`
+	Checks []HealthCheckInfo `json:"checks"`
+	// Proxy is the proxy configuration of a connect proxy instance.
+	// It's merged with proxy-defaults and service-defaults if MergeCentralConfig is set.
+	Proxy json.RawMessage `json:"proxy,omitempty"`
+}
+
+type NodeSummary struct {
+	Name       string            `json:"name"`
+	ID         string            `json:"id"`
+	Address    string            `json:"address"`
+	Datacenter string            `json:"datacenter"`
+	Meta       map[string]string `json:"meta"`
+	// Status is the worst status of node-level checks
+	Status string `json:"status"`
+}
+
+type NodeService struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Kind    string            `json:"kind,omitempty"`
+	Address string            `json:"address"`
+	Port    int               `json:"port"`
+	Tags    []string          `json:"tags"`
+	Meta    map[string]string `json:"meta"`
+	Status  string            `json:"status"`
+}
+
+type NodeDetail struct {
+	NodeSummary
+	TaggedAddresses map[string]string `json:"tagged_addresses"`
+	Services        []NodeService     `json:"services"`
+	Checks          []HealthCheckInfo `json:"checks"`
+}
+
+type ListServiceInstancesOptions struct {
+	Tags        []string
+	PassingOnly bool
+	// MergeCentralConfig merges proxy-defaults/global and service-defaults/:service
+	// config entries into the returned instances.
+	MergeCentralConfig bool
+}
+
+// intentions
+
+// Intention is a service-to-service authorization rule.
+// It has either an Action (L4) or a list of Permissions (L7).
+type Intention struct {
+	Source      string                `json:"source"`
+	SourcePeer  string                `json:"source_peer,omitempty"`
+	Destination string                `json:"destination"`
+	Action      string                `json:"action,omitempty"`
+	Permissions []IntentionPermission `json:"permissions,omitempty"`
+	Description string                `json:"description"`
+	Meta        map[string]string     `json:"meta,omitempty"`
+	// Precedence is computed by consul and ignored when writing.
+	// Intentions with higher precedence are applied first.
+	Precedence int `json:"precedence"`
+}
+
+type IntentionPermission struct {
+	Action string                   `json:"action"`
+	HTTP   *IntentionHTTPPermission `json:"http,omitempty"`
+}
+
+type IntentionHTTPPermission struct {
+	PathExact  string                `json:"path_exact,omitempty"`
+	PathPrefix string                `json:"path_prefix,omitempty"`
+	PathRegex  string                `json:"path_regex,omitempty"`
+	Header     []IntentionHTTPHeader `json:"header,omitempty"`
+	Methods    []string              `json:"methods,omitempty"`
+}
+
+type IntentionHTTPHeader struct {
+	Name    string `json:"name"`
+	Present bool   `json:"present,omitempty"`
+	Exact   string `json:"exact,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Suffix  string `json:"suffix,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+	Invert  bool   `json:"invert,omitempty"`
+}
+
+// IntentionName returns the readable name of the intention, e.g. "web -> db".
+func IntentionName(source, destination string) string {
+	return source + " -> " + destination
+}
+
+// config entries
+
+// ConfigEntry is a consul config entry in its JSON form,
+// e.g. {"Kind": "service-defaults", "Name": "web", "Protocol": "http"}.
+type ConfigEntry = map[string]any
+
+type ConfigEntryLink struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type WriteConfigEntryRequest struct {
+	Entry ConfigEntry `json:"entry"`
+	// Index is the ModifyIndex of the entry that the change is based on.
+	// 0 means the entry should not exist yet.
+	// The write fails with a conflict if the entry has been modified since.
+	Index uint64 `json:"index"`
+}
+
+type DiffConfigEntryRequest struct {
+	// Format of Content, should be one of "json", "yaml" and "hcl".
+	Format  string `json:"format"`
+	Content string `json:"content"`
+}
+
+type DiffLine struct {
+	// Op is one of " " (unchanged), "+" (inserted) and "-" (deleted).
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// ConfigEntryDiff compares the current config entry with a proposed one.
+// Both entries are rendered as indented JSON with sorted keys.
+type ConfigEntryDiff struct {
+	Current  string     `json:"current"`
+	Proposed string     `json:"proposed"`
+	Changed  bool       `json:"changed"`
+	Lines    []DiffLine `json:"lines"`
+	// Index is the ModifyIndex of the current entry (0 if it does not exist),
+	// which could be used to write the proposed entry.
+	Index uint64 `json:"index"`
+	// Error is set if the proposed entry is invalid.
+	Error string `json:"error,omitempty"`
+}
+
 type AuthenticateResult struct {
 	IsValid                int `json:"valid"`
 	IsAdmin                int `json:"admin"`
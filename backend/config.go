@@ -3,17 +3,113 @@
 
 package main
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/infra"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
 type ConsulConfig struct {
 	Address    string `yaml:"address"`
 	DataCenter string `yaml:"datacenter"`
 	Token      string `yaml:"admin_token"`
 }
 
+// SnapshotConfig configures scheduled consul snapshots.
+type SnapshotConfig struct {
+	// Interval between snapshots, e.g. "24h". Scheduled snapshots are disabled if it's empty.
+	Interval string `yaml:"interval"`
+	// Dir is the local directory to store snapshots.
+	Dir string `yaml:"dir"`
+	// Keep is the number of latest snapshots to keep, 0 means all.
+	Keep int `yaml:"keep"`
+	// Stale allows any server to take the snapshot, not only the leader.
+	Stale bool `yaml:"stale"`
+}
+
+// BackupConfig configures scheduled KV/ACL backups in the zip export format.
+type BackupConfig struct {
+	// Schedule is a cron expression, e.g. "0 3 * * *". Scheduled backups are disabled if it's empty.
+	Schedule string `yaml:"schedule"`
+	// Prefixes of keys to back up. All keys are backed up if it's empty.
+	Prefixes []string `yaml:"prefixes"`
+	// ACL includes tokens and policies in backups.
+	ACL bool `yaml:"acl"`
+	// Dir is the local directory to store backups and their manifest.
+	Dir string `yaml:"dir"`
+	// Keep is the number of latest backups to keep, 0 means all.
+	Keep int `yaml:"keep"`
+	// PublicKey encrypts backups to the base64 encoded X25519 public key if it's not empty.
+	// Use --gen-backup-key to generate a key pair.
+	PublicKey string `yaml:"public_key"`
+}
+
+// SyncConfig configures mirroring a KV prefix to and from a local git working copy.
+type SyncConfig struct {
+	// Dir is the git working copy. Sync is disabled if it's empty.
+	Dir string `yaml:"dir"`
+	// Remote is cloned into Dir if it doesn't exist, and pulled and pushed on every sync. Optional.
+	Remote string `yaml:"remote"`
+	// Prefix of keys to sync, e.g. "app/". All keys are synced if it's empty.
+	Prefix string `yaml:"prefix"`
+	// Mode is "push" (consul to git) or "pull" (git to consul).
+	Mode string `yaml:"mode"`
+	// Interval between syncs, e.g. "5m". Scheduled syncs are disabled if it's empty.
+	Interval string `yaml:"interval"`
+	// Watch commits on KV change in push mode.
+	Watch bool `yaml:"watch"`
+	// Prune deletes keys which are not in the working copy in pull mode.
+	Prune bool `yaml:"prune"`
+	// Secrets syncs values of secrets to and from git as they are. Secrets are skipped by default.
+	Secrets bool `yaml:"secrets"`
+}
+
+// TemplateConfig configures templates of imported values.
+type TemplateConfig struct {
+	// VarsDir is the directory of vars files, e.g. "dev.yaml" and "prod.yaml",
+	// selected by name on import.
+	VarsDir string `yaml:"vars_dir"`
+}
+
+// EncryptionConfig configures envelope encryption of KV values.
+type EncryptionConfig struct {
+	// Prefixes of keys whose values are encrypted. Encryption is disabled if it's empty.
+	Prefixes []string `yaml:"prefixes"`
+	// Keys are base64 encoded 32-byte master keys. The first one encrypts,
+	// and the others decrypt values encrypted before a rotation.
+	// Use --gen-value-key to generate a key.
+	Keys []string `yaml:"keys"`
+	// KeyFile has master keys in the same order, one per line. It's used if Keys is empty.
+	KeyFile string `yaml:"key_file"`
+}
+
+// MetadataConfig configures where consee metadata (value types, schemas, token names, audit records, etc.) is stored.
+type MetadataConfig struct {
+	// Backend is "consul" (KV under .consee-internal/ with the admin token) or "bolt" (a local database file).
+	// Use --migrate-metadata-from to copy existing metadata after changing it, and --migrate-metadata-purge to delete it from the old backend.
+	Backend string `yaml:"backend"`
+	// Path of the database file of the bolt backend.
+	Path string `yaml:"path"`
+}
+
 type Config struct {
-	Consul   ConsulConfig `yaml:"consul"`
-	LogLevel string       `yaml:"log_level"`
-	LogFile  string       `yaml:"log_file"`
-	Port     int          `yaml:"port"`
+	Consul   ConsulConfig   `yaml:"consul"`
+	LogLevel string         `yaml:"log_level"`
+	LogFile  string         `yaml:"log_file"`
+	Port     int            `yaml:"port"`
+	Snapshot SnapshotConfig `yaml:"snapshot"`
+	Backup   BackupConfig   `yaml:"backup"`
+	// MaxImportSize is the max size of import files in bytes, 0 means no limit.
+	MaxImportSize int64            `yaml:"max_import_size"`
+	Template      TemplateConfig   `yaml:"template"`
+	Sync          SyncConfig       `yaml:"sync"`
+	Encryption    EncryptionConfig `yaml:"encryption"`
+	Metadata      MetadataConfig   `yaml:"metadata"`
 }
 
 var config Config = Config{
@@ -21,4 +117,50 @@ var config Config = Config{
 	"info",
 	"",
 	3668,
+	SnapshotConfig{Dir: "snapshots", Keep: 7},
+	BackupConfig{Dir: "backups", Keep: 14},
+	256 << 20,
+	TemplateConfig{VarsDir: "vars"},
+	SyncConfig{Mode: "push"},
+	EncryptionConfig{},
+	MetadataConfig{Backend: "consul", Path: "data/consee.db"},
+}
+
+// keyring loads master keys, or returns nil if no key is configured.
+func (c EncryptionConfig) keyring() (*encrypt.Keyring, error) {
+	encoded := c.Keys
+	if len(encoded) == 0 && c.KeyFile != "" {
+		b, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				encoded = append(encoded, line)
+			}
+		}
+	}
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+	keys := make([][]byte, len(encoded))
+	for i, s := range encoded {
+		key, err := encrypt.ParseMasterKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return encrypt.NewKeyring(keys...)
+}
+
+// metadataStore opens the metadata store of backend. The closer is nil if nothing should be closed.
+func (c MetadataConfig) metadataStore(backend string, admin repo.AdminRepo) (repo.MetadataStore, io.Closer, error) {
+	switch backend {
+	case "consul":
+		return infra.NewConsulMetadata(admin), nil, nil
+	case "bolt":
+		return infra.NewBoltMetadata(c.Path)
+	}
+	return nil, nil, fmt.Errorf("unknown metadata backend %q", backend)
 }
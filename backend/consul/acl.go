@@ -195,6 +195,12 @@ func (a *ACL) RoleList(ctx context.Context, q *QueryOptions) (*Response[[]*ACLRo
 	return responseDirectly(a.c.httpClient, httpReq, decodeACLRoleList)
 }
 
+func (a *ACL) RoleListFiltered(ctx context.Context, q *QueryOptions, r ACLRoleFilterOptions) (*Response[[]*ACLRole], error) {
+	options := append(q.toRequestOptions(), r.toRequestOptions()...)
+	httpReq := a.c.newRequest(ctx, http.MethodGet, "/v1/acl/roles", options...)
+	return responseDirectly(a.c.httpClient, httpReq, decodeACLRoleList)
+}
+
 func (a *ACL) RoleRead(ctx context.Context, id string, q *QueryOptions) (*Response[*ACLRole], error) {
 	httpReq := a.c.newRequest(ctx, http.MethodGet, "/v1/acl/role/"+id, q.toRequestOptions()...)
 	return responseDirectly(a.c.httpClient, httpReq, decodeACLRole)
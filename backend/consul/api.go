@@ -304,3 +304,15 @@ func (o ACLTokenFilterOptions) toRequestOptions() []requestOption {
 	}
 	return options
 }
+
+type ACLRoleFilterOptions struct {
+	Policy string `json:",omitempty"`
+}
+
+func (o ACLRoleFilterOptions) toRequestOptions() []requestOption {
+	options := make([]requestOption, 0, 1)
+	if o.Policy != "" {
+		options = append(options, reqWithQuery("policy", o.Policy))
+	}
+	return options
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Node is a node registered in the catalog.
+type Node struct {
+	ID              string
+	Node            string
+	Address         string
+	Datacenter      string
+	TaggedAddresses map[string]string
+	Meta            map[string]string
+	CreateIndex     uint64
+	ModifyIndex     uint64
+	Partition       string `json:",omitempty"`
+	PeerName        string `json:",omitempty"`
+}
+
+type ServiceAddress struct {
+	Address string
+	Port    int
+}
+
+type AgentWeights struct {
+	Passing int
+	Warning int
+}
+
+// AgentService is a service instance registered on a node.
+type AgentService struct {
+	Kind              string `json:",omitempty"`
+	ID                string
+	Service           string
+	Tags              []string
+	Meta              map[string]string
+	Port              int
+	Address           string
+	TaggedAddresses   map[string]ServiceAddress `json:",omitempty"`
+	Weights           AgentWeights
+	EnableTagOverride bool
+	CreateIndex       uint64 `json:",omitempty"`
+	ModifyIndex       uint64 `json:",omitempty"`
+	// Proxy and Connect are kept as they are, since consee only displays them.
+	Proxy      json.RawMessage `json:",omitempty"`
+	Connect    json.RawMessage `json:",omitempty"`
+	PeerName   string          `json:",omitempty"`
+	Namespace  string          `json:",omitempty"`
+	Partition  string          `json:",omitempty"`
+	Datacenter string          `json:",omitempty"`
+}
+
+// CatalogService is a service instance returned by /v1/catalog/service/:service.
+type CatalogService struct {
+	ID                       string
+	Node                     string
+	Address                  string
+	Datacenter               string
+	TaggedAddresses          map[string]string
+	NodeMeta                 map[string]string
+	ServiceID                string
+	ServiceName              string
+	ServiceAddress           string
+	ServiceTaggedAddresses   map[string]ServiceAddress `json:",omitempty"`
+	ServiceTags              []string
+	ServiceMeta              map[string]string
+	ServicePort              int
+	ServiceWeights           AgentWeights
+	ServiceEnableTagOverride bool
+	ServiceProxy             json.RawMessage `json:",omitempty"`
+	CreateIndex              uint64
+	ModifyIndex              uint64
+	Namespace                string `json:",omitempty"`
+	Partition                string `json:",omitempty"`
+}
+
+// CatalogNode is a node with all its services.
+type CatalogNode struct {
+	Node     *Node
+	Services map[string]*AgentService
+}
+
+type Catalog struct {
+	c *Client
+}
+
+func (c *Client) Catalog() *Catalog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.catalog == nil {
+		c.catalog = &Catalog{c}
+	}
+	return c.catalog
+}
+
+func (c *Catalog) Datacenters(ctx context.Context, q *QueryOptions) (*Response[[]string], error) {
+	httpReq := c.c.newRequest(ctx, http.MethodGet, "/v1/catalog/datacenters", reqWithToken(q.Token))
+	return responseDirectly(c.c.httpClient, httpReq, decodeStringSlice)
+}
+
+// Services returns all service names with their tags.
+func (c *Catalog) Services(ctx context.Context, q *QueryOptions) (*Response[map[string][]string], error) {
+	httpReq := c.c.newRequest(ctx, http.MethodGet, "/v1/catalog/services", q.toRequestOptions()...)
+	return responseDirectly(c.c.httpClient, httpReq, decodeJSON[map[string][]string])
+}
+
+// Service returns instances of a service. Only instances having all tags are returned.
+func (c *Catalog) Service(ctx context.Context, service string, tags []string, q *QueryOptions) (*Response[[]*CatalogService], error) {
+	options := q.toRequestOptions()
+	for _, tag := range tags {
+		options = append(options, reqWithQuery("tag", tag))
+	}
+	httpReq := c.c.newRequest(ctx, http.MethodGet, "/v1/catalog/service/"+service, options...)
+	return responseDirectly(c.c.httpClient, httpReq, decodeJSON[[]*CatalogService])
+}
+
+func (c *Catalog) Nodes(ctx context.Context, q *QueryOptions) (*Response[[]*Node], error) {
+	httpReq := c.c.newRequest(ctx, http.MethodGet, "/v1/catalog/nodes", q.toRequestOptions()...)
+	return responseDirectly(c.c.httpClient, httpReq, decodeJSON[[]*Node])
+}
+
+// Node returns a node and services registered on it.
+// Body is nil if the node does not exist.
+func (c *Catalog) Node(ctx context.Context, node string, q *QueryOptions) (*Response[*CatalogNode], error) {
+	httpReq := c.c.newRequest(ctx, http.MethodGet, "/v1/catalog/node/"+node, q.toRequestOptions()...)
+	return responseDirectly(c.c.httpClient, httpReq, decodeJSON[*CatalogNode])
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServer responds body to every request, and records the last request.
+func testServer(t *testing.T, body string) (*Client, *http.Request) {
+	t.Helper()
+	last := &http.Request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = *r
+		w.Header().Set("X-Consul-Index", "43")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithAddress(strings.TrimPrefix(srv.URL, "http://"))), last
+}
+
+func TestQueryOptions(t *testing.T) {
+	ctx := context.Background()
+	c, last := testServer(t, `[{"Node": {"Node": "n1"}, "Service": {"ID": "web-1", "Service": "web"}, "Checks": []}]`)
+	q := &QueryOptions{
+		Datacenter: "dc2",
+		AllowStale: true,
+		WaitIndex:  42,
+		WaitTime:   5 * time.Second,
+		Token:      "secret",
+		Near:       "_agent",
+		NodeMeta:   map[string]string{"rack": "a"},
+		Filter:     `Service.Meta.version == "1"`,
+	}
+	resp, err := c.Health().Service(ctx, "web api", []string{"v1", "blue"}, true, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != http.StatusOK || resp.Err != nil || len(resp.Body) != 1 || resp.Body[0].Service.ID != "web-1" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Metadata == nil || resp.Metadata.LastIndex != 43 {
+		t.Errorf("metadata = %+v", resp.Metadata)
+	}
+	if last.URL.EscapedPath() != "/v1/health/service/web%20api" {
+		t.Errorf("path = %s", last.URL.EscapedPath())
+	}
+	query := last.URL.Query()
+	want := url.Values{
+		"dc":        {"dc2"},
+		"stale":     {""},
+		"index":     {"42"},
+		"wait":      {"5000ms"},
+		"near":      {"_agent"},
+		"node-meta": {"rack:a"},
+		"filter":    {`Service.Meta.version == "1"`},
+		"tag":       {"v1", "blue"},
+		"passing":   {"1"},
+	}
+	for key, values := range want {
+		if !slices.Equal(query[key], values) {
+			t.Errorf("%s = %q, want %q", key, query[key], values)
+		}
+	}
+	if last.Header.Get("X-Consul-Token") != "secret" {
+		t.Errorf("token = %q", last.Header.Get("X-Consul-Token"))
+	}
+
+	// options which are not set are not sent
+	if _, err = c.Catalog().Nodes(ctx, &QueryOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if last.URL.RawQuery != "" {
+		t.Errorf("query of empty options = %q", last.URL.RawQuery)
+	}
+	if _, err = c.Health().State(ctx, "critical", nil); err != nil {
+		t.Fatal(err)
+	}
+	if last.URL.Path != "/v1/health/state/critical" || last.URL.RawQuery != "" {
+		t.Errorf("request without options = %s", last.URL)
+	}
+}
@@ -23,9 +23,16 @@ type Client struct {
 	prefix     string
 	httpClient *http.Client
 
-	mu  sync.Mutex
-	kv  *KV
-	acl *ACL
+	mu         sync.Mutex
+	kv         *KV
+	acl        *ACL
+	catalog    *Catalog
+	health     *Health
+	intentions *Intentions
+
+	configEntries *ConfigEntries
+	snapshot      *Snapshot
+	session       *Session
 }
 
 func NewClient(options ...ClientOption) *Client {
@@ -60,6 +67,10 @@ type request struct {
 	header http.Header
 	body   []byte
 	query  url.Values
+
+	// bodyReader is used for large bodies that should be streamed, e.g. snapshots.
+	// It takes precedence over body.
+	bodyReader io.Reader
 }
 
 type requestOption func(*request)
@@ -73,6 +84,10 @@ func reqWithBody(v []byte) requestOption {
 	}
 }
 
+func reqWithBodyReader(r io.Reader) requestOption {
+	return func(req *request) { req.bodyReader = r }
+}
+
 func reqWithQuery(k, v string) requestOption {
 	return func(r *request) { r.query.Add(k, v) }
 }
@@ -229,7 +244,7 @@ func reqWithMergeCentralConfig(b bool) requestOption {
 	if !b {
 		return requestOptionNoop
 	}
-	return reqWithQuery("merge_central_config", "")
+	return reqWithQuery("merge-central-config", "")
 }
 
 func reqWithGlobal(b bool) requestOption {
@@ -272,6 +287,11 @@ func (c *Client) newRequest(ctx context.Context, method, path string, options ..
 			header.Set("Content-Type", "application/json")
 		}
 	}
+	if req.bodyReader != nil {
+		// the length is unknown, so the body is sent in chunks and cannot be replayed
+		body, getBody = io.NopCloser(req.bodyReader), nil
+		req.body = nil
+	}
 	httpReq := &http.Request{
 		Header:        header,
 		Body:          body,
@@ -357,6 +377,34 @@ func responseDirectly[T any](client *http.Client, httpReq *http.Request, decode
 	return resp, nil
 }
 
+// responseStream is like responseDirectly, but the body is not read if the status is 200.
+// Instead, it is returned as Body and the caller should close it.
+// Bodies of other statuses are read into RawBody as error messages.
+func responseStream(client *http.Client, httpReq *http.Request) (*Response[io.ReadCloser], error) {
+	t := time.Now()
+	httpResponse, err := client.Do(httpReq)
+	if err != nil {
+		if httpResponse != nil && httpResponse.Body != nil {
+			httpResponse.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp := &Response[io.ReadCloser]{
+		Duration: time.Since(t),
+		Status:   httpResponse.StatusCode,
+		Metadata: &Metadata{},
+	}
+	resp.Err = http2.ParseHeader(httpResponse.Header, resp.Metadata)
+	if httpResponse.StatusCode != http.StatusOK {
+		resp.RawBody, _ = io.ReadAll(httpResponse.Body)
+		httpResponse.Body.Close()
+		return resp, nil
+	}
+	resp.Body = httpResponse.Body
+	return resp, nil
+}
+
 // func newResponse[T any](httpResponse *http.Response, duration time.Duration, decode func([]byte) (T, error)) (*Response[T], error) {
 // 	resp := &Response[T]{
 // 		Duration: duration,
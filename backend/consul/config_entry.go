@@ -0,0 +1,97 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ServiceDefaults    = "service-defaults"
+	ProxyDefaults      = "proxy-defaults"
+	ServiceRouter      = "service-router"
+	ServiceSplitter    = "service-splitter"
+	ServiceResolver    = "service-resolver"
+	IngressGateway     = "ingress-gateway"
+	TerminatingGateway = "terminating-gateway"
+	ServiceIntentions  = "service-intentions"
+	MeshConfig         = "mesh"
+
+	ProxyConfigGlobal = "global"
+	MeshConfigMesh    = "mesh"
+)
+
+// ConfigEntry is a config entry in its JSON form.
+//
+// Fields of config entries vary a lot between kinds,
+// and consee only validates and displays them,
+// so they are not modeled as structs.
+type ConfigEntry map[string]any
+
+func (e ConfigEntry) Kind() string {
+	s, _ := e["Kind"].(string)
+	return s
+}
+
+func (e ConfigEntry) Name() string {
+	s, _ := e["Name"].(string)
+	return s
+}
+
+func (e ConfigEntry) ModifyIndex() uint64 {
+	f, _ := e["ModifyIndex"].(float64)
+	return uint64(f)
+}
+
+type ConfigEntries struct {
+	c *Client
+}
+
+func (c *Client) ConfigEntries() *ConfigEntries {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.configEntries == nil {
+		c.configEntries = &ConfigEntries{c}
+	}
+	return c.configEntries
+}
+
+func (ce *ConfigEntries) List(ctx context.Context, kind string, q *QueryOptions) (*Response[[]ConfigEntry], error) {
+	httpReq := ce.c.newRequest(ctx, http.MethodGet, "/v1/config/"+kind, q.toRequestOptions()...)
+	return responseDirectly(ce.c.httpClient, httpReq, decodeJSON[[]ConfigEntry])
+}
+
+func (ce *ConfigEntries) Get(ctx context.Context, kind, name string, q *QueryOptions) (*Response[ConfigEntry], error) {
+	httpReq := ce.c.newRequest(ctx, http.MethodGet, "/v1/config/"+kind+"/"+name, q.toRequestOptions()...)
+	return responseDirectly(ce.c.httpClient, httpReq, decodeJSON[ConfigEntry])
+}
+
+// Set creates or replaces the config entry unconditionally.
+func (ce *ConfigEntries) Set(ctx context.Context, entry ConfigEntry, w *WriteOptions) (*Response[bool], error) {
+	b, _ := json.Marshal(entry)
+	options := append(w.toRequestOptions(), reqWithBody(b))
+	httpReq := ce.c.newRequest(ctx, http.MethodPut, "/v1/config", options...)
+	return responseDirectly(ce.c.httpClient, httpReq, decodeTrue)
+}
+
+// CAS writes the config entry only if its ModifyIndex matches index.
+// An index of 0 means the entry should not exist yet.
+// Body of the response is false if the check fails.
+func (ce *ConfigEntries) CAS(ctx context.Context, entry ConfigEntry, index uint64, w *WriteOptions) (*Response[bool], error) {
+	b, _ := json.Marshal(entry)
+	options := append(w.toRequestOptions(),
+		reqWithQuery("cas", strconv.FormatUint(index, 10)),
+		reqWithBody(b),
+	)
+	httpReq := ce.c.newRequest(ctx, http.MethodPut, "/v1/config", options...)
+	return responseDirectly(ce.c.httpClient, httpReq, decodeTrue)
+}
+
+func (ce *ConfigEntries) Delete(ctx context.Context, kind, name string, w *WriteOptions) (*Response[bool], error) {
+	httpReq := ce.c.newRequest(ctx, http.MethodDelete, "/v1/config/"+kind+"/"+name, w.toRequestOptions()...)
+	return responseDirectly(ce.c.httpClient, httpReq, decodeTrue)
+}
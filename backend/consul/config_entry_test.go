@@ -0,0 +1,20 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConfigEntryPath(t *testing.T) {
+	c, last := testServer(t, `{"Kind": "service-defaults", "Name": "web api", "Protocol": "http"}`)
+	if _, err := c.ConfigEntries().Get(context.Background(), "service-defaults", "web api", nil); err != nil {
+		t.Fatal(err)
+	}
+	// names are escaped once
+	if got := last.URL.EscapedPath(); got != "/v1/config/service-defaults/web%20api" {
+		t.Errorf("path = %s", got)
+	}
+}
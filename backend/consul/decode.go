@@ -7,6 +7,13 @@ import "encoding/json"
 
 func decodeTrue(b []byte) (bool, error) { return string(b) == "true", nil }
 
+// decodeJSON decodes any json response body.
+func decodeJSON[T any](b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
 func decodeStringSlice(b []byte) ([]string, error) {
 	ss := []string{}
 	err := json.Unmarshal(b, &ss)
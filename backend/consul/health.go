@@ -0,0 +1,97 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	HealthAny      = "any"
+	HealthPassing  = "passing"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+	HealthMaint    = "maintenance"
+)
+
+// Checks registered by consul on the maintenance mode of a node or a service instance.
+// Their status is critical, and only the check id tells them apart.
+const (
+	NodeMaint          = "_node_maintenance"
+	ServiceMaintPrefix = "_service_maintenance:"
+)
+
+// HealthCheck is a check registered on a node or a service instance.
+type HealthCheck struct {
+	Node        string
+	CheckID     string
+	Name        string
+	Status      string
+	Notes       string
+	Output      string
+	ServiceID   string
+	ServiceName string
+	ServiceTags []string
+	Type        string
+	Namespace   string `json:",omitempty"`
+	Partition   string `json:",omitempty"`
+	PeerName    string `json:",omitempty"`
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// ServiceEntry is a service instance with its node and checks.
+type ServiceEntry struct {
+	Node    *Node
+	Service *AgentService
+	Checks  []*HealthCheck
+}
+
+type Health struct {
+	c *Client
+}
+
+func (c *Client) Health() *Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.health == nil {
+		c.health = &Health{c}
+	}
+	return c.health
+}
+
+// Node returns checks of a node, including checks of the services on it.
+func (h *Health) Node(ctx context.Context, node string, q *QueryOptions) (*Response[[]*HealthCheck], error) {
+	httpReq := h.c.newRequest(ctx, http.MethodGet, "/v1/health/node/"+node, q.toRequestOptions()...)
+	return responseDirectly(h.c.httpClient, httpReq, decodeJSON[[]*HealthCheck])
+}
+
+// Checks returns checks of a service.
+func (h *Health) Checks(ctx context.Context, service string, q *QueryOptions) (*Response[[]*HealthCheck], error) {
+	httpReq := h.c.newRequest(ctx, http.MethodGet, "/v1/health/checks/"+service, q.toRequestOptions()...)
+	return responseDirectly(h.c.httpClient, httpReq, decodeJSON[[]*HealthCheck])
+}
+
+// Service returns instances of a service with their nodes and checks.
+// Only instances having all tags are returned.
+// Instances with any non-passing check are omitted if passingOnly is true.
+func (h *Health) Service(ctx context.Context, service string, tags []string, passingOnly bool, q *QueryOptions) (*Response[[]*ServiceEntry], error) {
+	options := q.toRequestOptions()
+	for _, tag := range tags {
+		options = append(options, reqWithQuery("tag", tag))
+	}
+	if passingOnly {
+		options = append(options, reqWithQuery(HealthPassing, "1"))
+	}
+	httpReq := h.c.newRequest(ctx, http.MethodGet, "/v1/health/service/"+service, options...)
+	return responseDirectly(h.c.httpClient, httpReq, decodeJSON[[]*ServiceEntry])
+}
+
+// State returns checks in the given state. state should be one of
+// "any", "passing", "warning" and "critical".
+func (h *Health) State(ctx context.Context, state string, q *QueryOptions) (*Response[[]*HealthCheck], error) {
+	httpReq := h.c.newRequest(ctx, http.MethodGet, "/v1/health/state/"+state, q.toRequestOptions()...)
+	return responseDirectly(h.c.httpClient, httpReq, decodeJSON[[]*HealthCheck])
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	IntentionActionAllow = "allow"
+	IntentionActionDeny  = "deny"
+
+	IntentionMatchSource      = "source"
+	IntentionMatchDestination = "destination"
+)
+
+// Intention defines whether a source service is allowed to connect to a destination service.
+// An intention has either an Action (L4) or a list of Permissions (L7).
+type Intention struct {
+	// ID is only set for legacy intentions.
+	ID          string `json:",omitempty"`
+	Description string `json:",omitempty"`
+
+	SourcePeer           string `json:",omitempty"`
+	SourceSamenessGroup  string `json:",omitempty"`
+	SourceNS             string `json:",omitempty"`
+	SourceName           string
+	SourcePartition      string `json:",omitempty"`
+	DestinationNS        string `json:",omitempty"`
+	DestinationName      string
+	DestinationPartition string `json:",omitempty"`
+
+	// SourceType is the type of the source, only "consul" is supported for now.
+	SourceType string `json:",omitempty"`
+
+	Action      string                 `json:",omitempty"`
+	Permissions []*IntentionPermission `json:",omitempty"`
+
+	Meta map[string]string `json:",omitempty"`
+
+	// Precedence is the order that the intention will be applied.
+	// Higher is applied first. It's read-only and computed by consul.
+	Precedence int `json:",omitempty"`
+
+	CreateIndex uint64 `json:",omitempty"`
+	ModifyIndex uint64 `json:",omitempty"`
+}
+
+type IntentionPermission struct {
+	Action string
+	HTTP   *IntentionHTTPPermission `json:",omitempty"`
+}
+
+type IntentionHTTPPermission struct {
+	PathExact  string `json:",omitempty"`
+	PathPrefix string `json:",omitempty"`
+	PathRegex  string `json:",omitempty"`
+
+	Header []IntentionHTTPHeaderPermission `json:",omitempty"`
+
+	Methods []string `json:",omitempty"`
+}
+
+type IntentionHTTPHeaderPermission struct {
+	Name    string
+	Present bool   `json:",omitempty"`
+	Exact   string `json:",omitempty"`
+	Prefix  string `json:",omitempty"`
+	Suffix  string `json:",omitempty"`
+	Regex   string `json:",omitempty"`
+	Invert  bool   `json:",omitempty"`
+}
+
+type intentionCheckResponse struct {
+	Allowed bool
+}
+
+type Intentions struct {
+	c *Client
+}
+
+func (c *Client) Intentions() *Intentions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.intentions == nil {
+		c.intentions = &Intentions{c}
+	}
+	return c.intentions
+}
+
+func (i *Intentions) List(ctx context.Context, q *QueryOptions) (*Response[[]*Intention], error) {
+	httpReq := i.c.newRequest(ctx, http.MethodGet, "/v1/connect/intentions", q.toRequestOptions()...)
+	return responseDirectly(i.c.httpClient, httpReq, decodeJSON[[]*Intention])
+}
+
+// ReadExact reads the intention with exactly the given source and destination.
+func (i *Intentions) ReadExact(ctx context.Context, source, destination string, q *QueryOptions) (*Response[*Intention], error) {
+	options := append(q.toRequestOptions(),
+		reqWithQuery("source", source),
+		reqWithQuery("destination", destination),
+	)
+	httpReq := i.c.newRequest(ctx, http.MethodGet, "/v1/connect/intentions/exact", options...)
+	return responseDirectly(i.c.httpClient, httpReq, decodeJSON[*Intention])
+}
+
+// Upsert creates or replaces the intention of intention.SourceName and intention.DestinationName.
+func (i *Intentions) Upsert(ctx context.Context, intention *Intention, w *WriteOptions) (*Response[bool], error) {
+	b, _ := json.Marshal(intention)
+	options := append(w.toRequestOptions(),
+		reqWithQuery("source", intention.SourceName),
+		reqWithQuery("destination", intention.DestinationName),
+		reqWithBody(b),
+	)
+	httpReq := i.c.newRequest(ctx, http.MethodPut, "/v1/connect/intentions/exact", options...)
+	return responseDirectly(i.c.httpClient, httpReq, decodeTrue)
+}
+
+func (i *Intentions) DeleteExact(ctx context.Context, source, destination string, w *WriteOptions) (*Response[bool], error) {
+	options := append(w.toRequestOptions(),
+		reqWithQuery("source", source),
+		reqWithQuery("destination", destination),
+	)
+	httpReq := i.c.newRequest(ctx, http.MethodDelete, "/v1/connect/intentions/exact", options...)
+	return responseDirectly(i.c.httpClient, httpReq, decodeTrue)
+}
+
+// Check evaluates whether a connection from source to destination is allowed,
+// taking all matching intentions and the default policy into account.
+func (i *Intentions) Check(ctx context.Context, source, destination string, q *QueryOptions) (*Response[bool], error) {
+	options := append(q.toRequestOptions(),
+		reqWithQuery("source", source),
+		reqWithQuery("destination", destination),
+	)
+	httpReq := i.c.newRequest(ctx, http.MethodGet, "/v1/connect/intentions/check", options...)
+	return responseDirectly(i.c.httpClient, httpReq, func(b []byte) (bool, error) {
+		resp, err := decodeJSON[intentionCheckResponse](b)
+		return resp.Allowed, err
+	})
+}
+
+// Match returns intentions that match the given source or destination name,
+// ordered by precedence. by should be "source" or "destination".
+func (i *Intentions) Match(ctx context.Context, by, name string, q *QueryOptions) (*Response[[]*Intention], error) {
+	options := append(q.toRequestOptions(),
+		reqWithQuery("by", by),
+		reqWithQuery("name", name),
+	)
+	httpReq := i.c.newRequest(ctx, http.MethodGet, "/v1/connect/intentions/match", options...)
+	return responseDirectly(i.c.httpClient, httpReq, func(b []byte) ([]*Intention, error) {
+		m, err := decodeJSON[map[string][]*Intention](b)
+		return m[name], err
+	})
+}
@@ -5,9 +5,11 @@ package consul
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -190,6 +192,7 @@ func (kv *KV) Put(ctx context.Context, kvPair *KVPair, w *WriteOptions) (*Respon
 		return nil, fmt.Errorf("Invalid key. Key must not begin with a '/': %s", key)
 	}
 	options := append(w.toRequestOptions(), reqWithBody(kvPair.Value), reqWithContentType("application/octet-stream"))
+	options = withFlags(options, kvPair.Flags)
 	httpRequest := kv.c.newRequest(ctx, http.MethodPut, "/v1/kv/"+key, options...)
 	return responseDirectly(kv.c.httpClient, httpRequest, decodeTrue)
 	// t := time.Now()
@@ -206,6 +209,69 @@ func (kv *KV) Put(ctx context.Context, kvPair *KVPair, w *WriteOptions) (*Respon
 	// })
 }
 
+// CAS writes the pair only if its ModifyIndex matches the current one,
+// or if the key doesn't exist when ModifyIndex is 0. The body is false if the check fails.
+func (kv *KV) CAS(ctx context.Context, kvPair *KVPair, w *WriteOptions) (*Response[bool], error) {
+	key := kvPair.Key
+	if len(key) > 0 && key[0] == '/' {
+		return nil, fmt.Errorf("Invalid key. Key must not begin with a '/': %s", key)
+	}
+	options := append(w.toRequestOptions(),
+		reqWithQuery("cas", strconv.FormatUint(kvPair.ModifyIndex, 10)),
+		reqWithBody(kvPair.Value),
+		reqWithContentType("application/octet-stream"),
+	)
+	options = withFlags(options, kvPair.Flags)
+	httpRequest := kv.c.newRequest(ctx, http.MethodPut, "/v1/kv/"+key, options...)
+	return responseDirectly(kv.c.httpClient, httpRequest, decodeTrue)
+}
+
+// Acquire writes the pair and locks the key with pair.Session. The body is false if the key is locked by another session.
+// The pair is written with CAS if pair.ModifyIndex is not 0.
+func (kv *KV) Acquire(ctx context.Context, kvPair *KVPair, w *WriteOptions) (*Response[bool], error) {
+	return kv.putWithSession(ctx, "acquire", kvPair, w)
+}
+
+// Release writes the pair and unlocks the key held by pair.Session. The body is false if the key is not locked by the session.
+// The pair is written with CAS if pair.ModifyIndex is not 0.
+func (kv *KV) Release(ctx context.Context, kvPair *KVPair, w *WriteOptions) (*Response[bool], error) {
+	return kv.putWithSession(ctx, "release", kvPair, w)
+}
+
+func (kv *KV) putWithSession(ctx context.Context, op string, kvPair *KVPair, w *WriteOptions) (*Response[bool], error) {
+	key := kvPair.Key
+	if len(key) > 0 && key[0] == '/' {
+		return nil, fmt.Errorf("Invalid key. Key must not begin with a '/': %s", key)
+	}
+	options := append(w.toRequestOptions(),
+		reqWithQuery(op, kvPair.Session),
+		reqWithBody(kvPair.Value),
+		reqWithContentType("application/octet-stream"),
+	)
+	if kvPair.ModifyIndex != 0 {
+		options = append(options, reqWithQuery("cas", strconv.FormatUint(kvPair.ModifyIndex, 10)))
+	}
+	options = withFlags(options, kvPair.Flags)
+	httpRequest := kv.c.newRequest(ctx, http.MethodPut, "/v1/kv/"+key, options...)
+	return responseDirectly(kv.c.httpClient, httpRequest, decodeTrue)
+}
+
+// withFlags sets the flags of a write. Consul resets flags to 0 if they are not set,
+// so writes should carry the flags of the pair to keep them.
+func withFlags(options []requestOption, flags uint64) []requestOption {
+	if flags == 0 {
+		return options
+	}
+	return append(options, reqWithQuery("flags", strconv.FormatUint(flags, 10)))
+}
+
+// DeleteCAS deletes the key only if its ModifyIndex matches the one of the pair.
+func (kv *KV) DeleteCAS(ctx context.Context, kvPair *KVPair, w *WriteOptions) (*Response[bool], error) {
+	options := append(w.toRequestOptions(), reqWithQuery("cas", strconv.FormatUint(kvPair.ModifyIndex, 10)))
+	httpRequest := kv.c.newRequest(ctx, http.MethodDelete, "/v1/kv/"+strings.TrimPrefix(kvPair.Key, "/"), options...)
+	return responseDirectly(kv.c.httpClient, httpRequest, decodeTrue)
+}
+
 func (kv *KV) Delete(ctx context.Context, key string, w *WriteOptions) (*Response[bool], error) {
 	httpRequest := kv.c.newRequest(ctx, http.MethodDelete, "/v1/kv/"+strings.TrimPrefix(key, "/"), w.toRequestOptions()...)
 	return responseDirectly(kv.c.httpClient, httpRequest, decodeTrue)
@@ -249,3 +315,67 @@ func (kv *KV) WatchKeys(ctx context.Context, prefix string, q *QueryOptions, onR
 	}
 	slog.Info("watch stopped", "context_status", context.Cause(ctx))
 }
+
+// KVOp is the verb of a KV operation in a transaction.
+type KVOp string
+
+const (
+	KVSet        KVOp = "set"
+	KVCAS        KVOp = "cas"
+	KVDelete     KVOp = "delete"
+	KVDeleteCAS  KVOp = "delete-cas"
+	KVCheckIndex KVOp = "check-index"
+	// KVLock writes the key and locks it with Session, like Acquire.
+	KVLock KVOp = "lock"
+)
+
+// MaxTxnOps is the max number of operations in a transaction accepted by consul.
+const MaxTxnOps = 64
+
+type KVTxnOp struct {
+	Verb  KVOp
+	Key   string
+	Value []byte `json:",omitempty"`
+	Flags uint64 `json:",omitempty"`
+	// Index is the expected ModifyIndex of cas, delete-cas and check-index, 0 means the key should not exist.
+	Index uint64 `json:",omitempty"`
+	// Session is the session of lock.
+	Session string `json:",omitempty"`
+}
+
+type TxnError struct {
+	OpIndex int
+	What    string
+}
+
+type TxnResult struct {
+	KV *KVPair
+}
+
+type TxnResponse struct {
+	Results []TxnResult
+	Errors  []TxnError
+}
+
+// Txn runs KV operations in a transaction, either all of them are applied or none.
+// The status is 409 if the transaction is rolled back, and Errors of the body explain why.
+func (kv *KV) Txn(ctx context.Context, ops []*KVTxnOp, w *WriteOptions) (*Response[*TxnResponse], error) {
+	type txnOp struct {
+		KV *KVTxnOp
+	}
+	req := make([]txnOp, len(ops))
+	for i, op := range ops {
+		req[i] = txnOp{op}
+	}
+	b, _ := json.Marshal(req)
+	options := append(w.toRequestOptions(),
+		reqWithContentType("application/json"),
+		reqWithBody(b),
+	)
+	httpRequest := kv.c.newRequest(ctx, http.MethodPut, "/v1/txn", options...)
+	resp, err := responseDirectly(kv.c.httpClient, httpRequest, decodeJSON[*TxnResponse])
+	if err == nil && resp.Status == http.StatusConflict {
+		resp.Body, resp.Err = decodeJSON[*TxnResponse](resp.RawBody)
+	}
+	return resp, err
+}
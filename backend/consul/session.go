@@ -0,0 +1,99 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// SessionBehaviorRelease releases locks held by the session when it's invalidated.
+	SessionBehaviorRelease = "release"
+	// SessionBehaviorDelete deletes keys locked by the session when it's invalidated.
+	SessionBehaviorDelete = "delete"
+)
+
+// SessionEntry is a session, which holds locks on keys until it's destroyed or invalidated.
+type SessionEntry struct {
+	CreateIndex uint64 `json:",omitempty"`
+	ModifyIndex uint64 `json:",omitempty"`
+	ID          string `json:",omitempty"`
+	Name        string `json:",omitempty"`
+	// Node is the node the session is bound to, the node of the agent by default.
+	Node string `json:",omitempty"`
+	// LockDelay is a duration like "15s", during which released locks could not be acquired again.
+	LockDelay string `json:",omitempty"`
+	Behavior  string `json:",omitempty"`
+	// TTL is a duration like "30s". The session is invalidated if it's not renewed within TTL.
+	TTL           string   `json:",omitempty"`
+	NodeChecks    []string `json:",omitempty"`
+	ServiceChecks []struct {
+		ID        string
+		Namespace string `json:",omitempty"`
+	} `json:",omitempty"`
+	Namespace string `json:",omitempty"`
+	Partition string `json:",omitempty"`
+}
+
+type Session struct {
+	c *Client
+}
+
+func (c *Client) Session() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		c.session = &Session{c}
+	}
+	return c.session
+}
+
+// Create creates a session. Body of the response is the ID of the session.
+func (s *Session) Create(ctx context.Context, se *SessionEntry, w *WriteOptions) (*Response[string], error) {
+	b, _ := json.Marshal(se)
+	options := append(w.toRequestOptions(),
+		reqWithContentType("application/json"),
+		reqWithBody(b),
+	)
+	httpReq := s.c.newRequest(ctx, http.MethodPut, "/v1/session/create", options...)
+	return responseDirectly(s.c.httpClient, httpReq, func(b []byte) (string, error) {
+		var created struct{ ID string }
+		err := json.Unmarshal(b, &created)
+		return created.ID, err
+	})
+}
+
+// Renew resets the TTL of the session. The status is 404 if the session is already invalidated.
+func (s *Session) Renew(ctx context.Context, id string, w *WriteOptions) (*Response[*SessionEntry], error) {
+	httpReq := s.c.newRequest(ctx, http.MethodPut, "/v1/session/renew/"+id, w.toRequestOptions()...)
+	return responseDirectly(s.c.httpClient, httpReq, decodeSessionEntry)
+}
+
+// Destroy destroys the session, and locks held by it are released or deleted by its behavior.
+func (s *Session) Destroy(ctx context.Context, id string, w *WriteOptions) (*Response[bool], error) {
+	httpReq := s.c.newRequest(ctx, http.MethodPut, "/v1/session/destroy/"+id, w.toRequestOptions()...)
+	return responseDirectly(s.c.httpClient, httpReq, decodeTrue)
+}
+
+// Info reads the session. Body of the response is nil if the session doesn't exist.
+func (s *Session) Info(ctx context.Context, id string, q *QueryOptions) (*Response[*SessionEntry], error) {
+	httpReq := s.c.newRequest(ctx, http.MethodGet, "/v1/session/info/"+id, q.toRequestOptions()...)
+	return responseDirectly(s.c.httpClient, httpReq, decodeSessionEntry)
+}
+
+func (s *Session) List(ctx context.Context, q *QueryOptions) (*Response[[]*SessionEntry], error) {
+	httpReq := s.c.newRequest(ctx, http.MethodGet, "/v1/session/list", q.toRequestOptions()...)
+	return responseDirectly(s.c.httpClient, httpReq, decodeJSON[[]*SessionEntry])
+}
+
+// decodeSessionEntry decodes the first entry of the list, or nil if the list is empty.
+func decodeSessionEntry(b []byte) (*SessionEntry, error) {
+	entries := []*SessionEntry{}
+	if err := json.Unmarshal(b, &entries); err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package consul
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// Snapshot saves and restores the state of consul servers,
+// including KV entries, the service catalog, ACLs, sessions, etc.
+//
+// Snapshots could be large, so they are streamed instead of being read into memory.
+type Snapshot struct {
+	c *Client
+}
+
+func (c *Client) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.snapshot == nil {
+		c.snapshot = &Snapshot{c}
+	}
+	return c.snapshot
+}
+
+// Save requests a snapshot. Body of the response is the snapshot archive
+// if the status is 200, and the caller should close it.
+// Set q.AllowStale to let any server (not only the leader) take the snapshot.
+func (s *Snapshot) Save(ctx context.Context, q *QueryOptions) (*Response[io.ReadCloser], error) {
+	httpReq := s.c.newRequest(ctx, http.MethodGet, "/v1/snapshot", q.toRequestOptions()...)
+	return responseStream(s.c.httpClient, httpReq)
+}
+
+// Restore restores the servers from the snapshot read from r.
+// This is destructive, all current state is replaced.
+func (s *Snapshot) Restore(ctx context.Context, r io.Reader, w *WriteOptions) (*Response[bool], error) {
+	options := append(w.toRequestOptions(),
+		reqWithContentType("application/octet-stream"),
+		reqWithBodyReader(r),
+	)
+	httpReq := s.c.newRequest(ctx, http.MethodPut, "/v1/snapshot", options...)
+	return responseDirectly(s.c.httpClient, httpReq, decodeTrue)
+}
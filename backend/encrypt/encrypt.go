@@ -0,0 +1,391 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+// Package encrypt implements the encrypted .consee container used by exports and backups,
+// and envelope encryption of KV values, see Keyring.
+//
+// A container starts with a header:
+//
+//	magic "CONSEE" | version (1 byte) | mode (1 byte) | mode specific fields | nonce prefix (7 bytes)
+//
+// In passphrase mode, the fields are a 16-byte salt and log2 of the scrypt cost.
+// In recipient mode, the field is an ephemeral X25519 public key (32 bytes),
+// and the key is derived from the shared secret with the recipient's key by HKDF-SHA256.
+//
+// The payload is split into chunks of 64 KiB, each sealed with AES-256-GCM.
+// The nonce is the prefix, a big-endian chunk counter (4 bytes) and a byte
+// which is 1 for the last chunk, so that truncation and reordering are detected.
+// The header is authenticated as additional data of every chunk.
+package encrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// Ext is the file extension of containers.
+	Ext = ".consee"
+
+	ModePassphrase byte = 1
+	ModeRecipient  byte = 2
+
+	magic   = "CONSEE"
+	version = 1
+
+	saltSize   = 16
+	scryptLogN = 17
+	keySize    = 32
+	prefixSize = 7
+	chunkSize  = 64 << 10
+	tagSize    = 16
+
+	hkdfInfo = "consee archive"
+)
+
+var (
+	ErrInvalidFormat      = errors.New("not a consee encrypted archive")
+	ErrUnsupportedVersion = errors.New("unsupported archive version")
+	ErrPassphraseRequired = errors.New("passphrase is required")
+	ErrIdentityRequired   = errors.New("private key is required")
+	ErrDecrypt            = errors.New("wrong passphrase or key, or the archive is corrupted")
+)
+
+// IsEncrypted reports whether the content read by r starts with the container magic.
+func IsEncrypted(r io.ReaderAt) bool {
+	b := make([]byte, len(magic))
+	n, _ := r.ReadAt(b, 0)
+	return n == len(magic) && string(b) == magic
+}
+
+// Mode reads the header of the container and returns its mode.
+func Mode(r io.ReaderAt) (byte, error) {
+	b := make([]byte, len(magic)+2)
+	if n, _ := r.ReadAt(b, 0); n < len(b) || string(b[:len(magic)]) != magic {
+		return 0, ErrInvalidFormat
+	}
+	if b[len(magic)] != version {
+		return 0, ErrUnsupportedVersion
+	}
+	return b[len(magic)+1], nil
+}
+
+// GenerateKey generates an X25519 key pair for recipient mode.
+// Keys are encoded by EncodeKey.
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// EncodeKey encodes the raw bytes of a public or private key in base64.
+func EncodeKey(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPublicKey(b)
+}
+
+func ParsePrivateKey(s string) (*ecdh.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(b)
+}
+
+func passphraseKey(passphrase string, salt []byte, logN byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<logN, 8, 1, keySize)
+}
+
+func recipientKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := make([]byte, 0, len(ephemeral)+len(recipient))
+	salt = append(append(salt, ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, hkdfInfo, keySize)
+}
+
+// NewPassphraseWriter returns a writer that encrypts everything written to it with passphrase.
+// The caller must close the writer to write the last chunk; w is not closed.
+func NewPassphraseWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := passphraseKey(passphrase, salt, scryptLogN)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte{}, magic...)
+	header = append(header, version, ModePassphrase)
+	header = append(header, salt...)
+	header = append(header, scryptLogN)
+	return newWriter(w, key, header)
+}
+
+// NewRecipientWriter returns a writer that encrypts everything written to it
+// so that only the owner of the private key of recipient could decrypt it.
+// The caller must close the writer to write the last chunk; w is not closed.
+func NewRecipientWriter(w io.Writer, recipient *ecdh.PublicKey) (io.WriteCloser, error) {
+	ephemeral, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	key, err := recipientKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte{}, magic...)
+	header = append(header, version, ModeRecipient)
+	header = append(header, ephemeral.PublicKey().Bytes()...)
+	return newWriter(w, key, header)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte
+	sealed  []byte
+	err     error
+}
+
+func newWriter(w io.Writer, key, header []byte) (*writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce[:prefixSize]); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce[:prefixSize]...)
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return &writer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		nonce:  nonce,
+		buf:    make([]byte, 0, chunkSize),
+		sealed: make([]byte, 0, chunkSize+tagSize),
+	}, nil
+}
+
+func (w *writer) seal(last bool) error {
+	if w.counter == 1<<32-1 {
+		return errors.New("archive is too large")
+	}
+	binary.BigEndian.PutUint32(w.nonce[prefixSize:], w.counter)
+	if last {
+		w.nonce[len(w.nonce)-1] = 1
+	}
+	w.counter++
+	w.sealed = w.aead.Seal(w.sealed[:0], w.nonce, w.buf, w.header)
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.sealed)
+	return err
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is sealed only when more data comes,
+		// since the last chunk must be sealed by Close
+		if len(w.buf) == chunkSize {
+			if w.err = w.seal(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		m := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close seals the last chunk, which may be empty.
+func (w *writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.seal(true)
+	if w.err == nil {
+		w.err = errors.New("write to closed archive")
+		return nil
+	}
+	return w.err
+}
+
+// Keys provides secrets to open containers. Only the one matching the mode of the container is used.
+type Keys struct {
+	Passphrase string
+	Identity   *ecdh.PrivateKey
+}
+
+// NewReader returns a reader of the plain content of the container read from r.
+// Errors of authentication are reported as ErrDecrypt.
+func NewReader(r io.Reader, keys Keys) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, chunkSize+tagSize)
+	header := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, ErrInvalidFormat
+	}
+	if header[len(magic)] != version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	var key []byte
+	switch header[len(magic)+1] {
+	case ModePassphrase:
+		fields := make([]byte, saltSize+1)
+		if _, err := io.ReadFull(br, fields); err != nil {
+			return nil, ErrInvalidFormat
+		}
+		header = append(header, fields...)
+		if keys.Passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		// the cost is read before anything is authenticated, so it's capped by what writers use,
+		// otherwise a crafted header could make scrypt allocate gigabytes
+		logN := fields[saltSize]
+		if logN < 10 || logN > scryptLogN {
+			return nil, ErrInvalidFormat
+		}
+		var err error
+		if key, err = passphraseKey(keys.Passphrase, fields[:saltSize], logN); err != nil {
+			return nil, err
+		}
+	case ModeRecipient:
+		ephemeral := make([]byte, 32)
+		if _, err := io.ReadFull(br, ephemeral); err != nil {
+			return nil, ErrInvalidFormat
+		}
+		header = append(header, ephemeral...)
+		if keys.Identity == nil {
+			return nil, ErrIdentityRequired
+		}
+		pub, err := ecdh.X25519().NewPublicKey(ephemeral)
+		if err != nil {
+			return nil, ErrInvalidFormat
+		}
+		shared, err := keys.Identity.ECDH(pub)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		if key, err = recipientKey(shared, ephemeral, keys.Identity.PublicKey().Bytes()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidFormat
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(br, nonce[:prefixSize]); err != nil {
+		return nil, ErrInvalidFormat
+	}
+	header = append(header, nonce[:prefixSize]...)
+	return &reader{
+		r:      br,
+		aead:   aead,
+		header: header,
+		nonce:  nonce,
+		sealed: make([]byte, chunkSize+tagSize),
+	}, nil
+}
+
+type reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint32
+	sealed  []byte
+	plain   []byte
+	done    bool
+	err     error
+}
+
+func (r *reader) open() error {
+	n, err := io.ReadFull(r.r, r.sealed)
+	last := false
+	switch err {
+	case nil:
+		// a full chunk is the last one only if nothing follows
+		if _, err := r.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		// the last chunk is missing, the archive is truncated
+		return ErrDecrypt
+	default:
+		return err
+	}
+	binary.BigEndian.PutUint32(r.nonce[prefixSize:], r.counter)
+	if last {
+		r.nonce[len(r.nonce)-1] = 1
+	}
+	r.counter++
+	r.plain, err = r.aead.Open(r.sealed[:0], r.nonce, r.sealed[:n], r.header)
+	if err != nil {
+		return ErrDecrypt
+	}
+	r.done = last
+	return nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.open()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func sealArchive(t *testing.T, newWriter func(io.Writer) (io.WriteCloser, error), plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// odd sized writes cross chunk boundaries
+	for p := plain; len(p) > 0; {
+		n := min(len(p), 10000)
+		if _, err = w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openArchive(archive []byte, keys Keys) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(archive), keys)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRecipient(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := GenerateKey()
+	newWriter := func(w io.Writer) (io.WriteCloser, error) { return NewRecipientWriter(w, key.PublicKey()) }
+	headerSize := len(magic) + 2 + 32 + prefixSize
+
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 2 * chunkSize, 3*chunkSize + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		archive := sealArchive(t, newWriter, plain)
+		if !IsEncrypted(bytes.NewReader(archive)) {
+			t.Fatalf("size %d: archive should be recognized", size)
+		}
+		if mode, err := Mode(bytes.NewReader(archive)); err != nil || mode != ModeRecipient {
+			t.Fatalf("size %d: mode = %d, %v", size, mode, err)
+		}
+		got, err := openArchive(archive, Keys{Identity: key})
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: round trip failed: %v", size, err)
+		}
+		nchunks := size/chunkSize + 1
+		if size > 0 && size%chunkSize == 0 {
+			// a full last chunk is not followed by an empty one
+			nchunks--
+		}
+		if len(archive) != headerSize+size+nchunks*tagSize {
+			t.Fatalf("size %d: unexpected archive size %d", size, len(archive))
+		}
+	}
+
+	plain := make([]byte, 3*chunkSize)
+	rand.Read(plain)
+	archive := sealArchive(t, newWriter, plain)
+	sealedChunk := chunkSize + tagSize
+	if _, err = openArchive(archive, Keys{Identity: other}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong key: err = %v, want ErrDecrypt", err)
+	}
+	if _, err = openArchive(archive, Keys{}); !errors.Is(err, ErrIdentityRequired) {
+		t.Errorf("no key: err = %v, want ErrIdentityRequired", err)
+	}
+
+	// truncated at a chunk boundary, in a chunk and in the header
+	for _, n := range []int{headerSize + sealedChunk, headerSize + 2*sealedChunk, len(archive) - 1, headerSize + 100} {
+		if _, err = openArchive(archive[:n], Keys{Identity: key}); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("truncated to %d: err = %v, want ErrDecrypt", n, err)
+		}
+	}
+	if _, err = openArchive(archive[:headerSize-1], Keys{Identity: key}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("truncated header: err = %v, want ErrInvalidFormat", err)
+	}
+
+	// the first two chunks are swapped
+	reordered := bytes.Clone(archive)
+	first := archive[headerSize : headerSize+sealedChunk]
+	second := archive[headerSize+sealedChunk : headerSize+2*sealedChunk]
+	copy(reordered[headerSize:], second)
+	copy(reordered[headerSize+sealedChunk:], first)
+	if _, err = openArchive(reordered, Keys{Identity: key}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("reordered: err = %v, want ErrDecrypt", err)
+	}
+
+	// a bit flipped in the header, which is authenticated with every chunk
+	tampered := bytes.Clone(archive)
+	tampered[headerSize-1] ^= 1
+	if _, err = openArchive(tampered, Keys{Identity: key}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered header: err = %v, want ErrDecrypt", err)
+	}
+	tampered = bytes.Clone(archive)
+	tampered[len(tampered)-1] ^= 1
+	if _, err = openArchive(tampered, Keys{Identity: key}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered chunk: err = %v, want ErrDecrypt", err)
+	}
+
+	// the last chunk is appended again
+	extended := append(bytes.Clone(archive), archive[len(archive)-sealedChunk:]...)
+	if _, err = openArchive(extended, Keys{Identity: key}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("extended: err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	newWriter := func(w io.Writer) (io.WriteCloser, error) { return NewPassphraseWriter(w, "secret") }
+	if _, err := newWriter(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPassphraseWriter(io.Discard, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("empty passphrase: err = %v, want ErrPassphraseRequired", err)
+	}
+
+	plain := make([]byte, chunkSize)
+	rand.Read(plain)
+	archive := sealArchive(t, newWriter, plain)
+	if mode, err := Mode(bytes.NewReader(archive)); err != nil || mode != ModePassphrase {
+		t.Fatalf("mode = %d, %v", mode, err)
+	}
+	got, err := openArchive(archive, Keys{Passphrase: "secret"})
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+	if _, err = openArchive(archive, Keys{Passphrase: "wrong"}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong passphrase: err = %v, want ErrDecrypt", err)
+	}
+	if _, err = openArchive(archive, Keys{}); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("no passphrase: err = %v, want ErrPassphraseRequired", err)
+	}
+
+	// costs above those of writers are refused before scrypt runs
+	costly := bytes.Clone(archive)
+	costly[len(magic)+2+saltSize] = 22
+	if _, err = openArchive(costly, Keys{Passphrase: "secret"}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("logN 22: err = %v, want ErrInvalidFormat", err)
+	}
+}
+
+func TestInvalidFormat(t *testing.T) {
+	if IsEncrypted(bytes.NewReader([]byte("PK\x03\x04"))) {
+		t.Error("a zip should not be taken as an archive")
+	}
+	if _, err := openArchive([]byte("CONSEE\x02\x01"), Keys{Passphrase: "secret"}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("err = %v, want ErrUnsupportedVersion", err)
+	}
+	if _, err := openArchive([]byte("CONSEE\x01\x09"), Keys{Passphrase: "secret"}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("err = %v, want ErrInvalidFormat", err)
+	}
+}
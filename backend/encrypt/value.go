@@ -0,0 +1,189 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package encrypt
+
+// Envelope encryption of KV values.
+//
+// A value is sealed with AES-256-GCM under a random data key, and the data key is
+// wrapped (sealed) by a master key. The stored value is text, so that it's safe in JSON:
+//
+//	"consee:v1:" key id ":" base64(wrapped data key) ":" base64(sealed value)
+//
+// The key id is the first 8 hex digits of SHA-256 of the master key, so that values
+// sealed before a rotation are opened with the old key. Both seals have a 12-byte
+// random nonce before the ciphertext, and the header up to the key id as additional data.
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const valuePrefix = "consee:v1:"
+
+var (
+	ErrInvalidValue = errors.New("invalid encrypted value")
+	ErrUnknownKey   = errors.New("the value is encrypted with an unknown master key")
+	ErrInvalidKey   = errors.New("master keys should be base64 encoded 32 bytes")
+)
+
+// IsSealed reports whether the value is sealed by a Keyring.
+func IsSealed(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(valuePrefix))
+}
+
+// SealedKeyID returns the id of the master key a sealed value is sealed with, or "" if it's not sealed.
+func SealedKeyID(value []byte) string {
+	if !IsSealed(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(string(value[len(valuePrefix):]), ":")
+	return id
+}
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring seals values with its first master key, and opens values sealed with any of its keys.
+type Keyring struct {
+	keys []masterKey
+}
+
+// GenerateMasterKey returns a random master key, encoded as ParseMasterKey expects.
+func GenerateMasterKey() (string, error) {
+	key, err := randomBytes(keySize)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseMasterKey decodes a base64 encoded 32-byte master key.
+func ParseMasterKey(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != keySize {
+		return nil, ErrInvalidKey
+	}
+	return b, nil
+}
+
+// NewKeyring creates a keyring of master keys. The first key is the current one.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrInvalidKey
+	}
+	k := &Keyring{keys: make([]masterKey, 0, len(keys))}
+	for _, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		k.keys = append(k.keys, masterKey{id: hex.EncodeToString(sum[:4]), aead: aead})
+	}
+	return k, nil
+}
+
+// KeyID returns the id of the current master key.
+func (k *Keyring) KeyID() string {
+	return k.keys[0].id
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidValue
+	}
+	n := aead.NonceSize()
+	plaintext, err := aead.Open(nil, sealed[:n], sealed[n:], ad)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return plaintext, nil
+}
+
+// Seal encrypts the value with a new data key wrapped by the current master key.
+func (k *Keyring) Seal(value []byte) ([]byte, error) {
+	current := k.keys[0]
+	header := valuePrefix + current.id
+	dataKey, err := randomBytes(keySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(current.aead, dataKey, []byte(header))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(aead, value, []byte(header))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString(header)
+	b.WriteByte(':')
+	b.WriteString(base64.StdEncoding.EncodeToString(wrapped))
+	b.WriteByte(':')
+	b.WriteString(base64.StdEncoding.EncodeToString(sealed))
+	return b.Bytes(), nil
+}
+
+// Open decrypts a value sealed with any master key of the keyring.
+func (k *Keyring) Open(value []byte) ([]byte, error) {
+	if !IsSealed(value) {
+		return nil, ErrInvalidValue
+	}
+	parts := strings.Split(string(value[len(valuePrefix):]), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidValue
+	}
+	id := parts[0]
+	i := 0
+	for i < len(k.keys) && k.keys[i].id != id {
+		i++
+	}
+	if i == len(k.keys) {
+		return nil, ErrUnknownKey
+	}
+	wrapped, err1 := base64.StdEncoding.DecodeString(parts[1])
+	sealed, err2 := base64.StdEncoding.DecodeString(parts[2])
+	if err1 != nil || err2 != nil {
+		return nil, ErrInvalidValue
+	}
+	header := []byte(valuePrefix + id)
+	dataKey, err := open(k.keys[i].aead, wrapped, header)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return open(aead, sealed, header)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+	parsed := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		b, err := ParseMasterKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, b)
+	}
+	k, err := NewKeyring(parsed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyring(t *testing.T) {
+	oldKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _ := GenerateMasterKey()
+	old := newTestKeyring(t, oldKey)
+	plain := []byte("password: s3cret\n")
+
+	sealed, err := old.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || !strings.HasPrefix(string(sealed), valuePrefix+old.KeyID()+":") {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+	if id := SealedKeyID(sealed); id != old.KeyID() {
+		t.Errorf("key id of the sealed value = %q", id)
+	}
+	if id := SealedKeyID(plain); id != "" {
+		t.Errorf("key id of a plain value = %q", id)
+	}
+	if bytes.Contains(sealed, []byte("s3cret")) {
+		t.Fatal("plaintext in the sealed value")
+	}
+	if again, _ := old.Seal(plain); bytes.Equal(again, sealed) {
+		t.Error("values should be sealed with random data keys")
+	}
+	if got, err := old.Open(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("open = %q, %v", got, err)
+	}
+	if empty, err := old.Seal(nil); err != nil {
+		t.Fatal(err)
+	} else if got, err := old.Open(empty); err != nil || len(got) != 0 {
+		t.Errorf("open empty = %q, %v", got, err)
+	}
+
+	// after a rotation, values are sealed with the new key and old values are still opened
+	rotated := newTestKeyring(t, newKey, oldKey)
+	if rotated.KeyID() == old.KeyID() {
+		t.Fatal("key ids of different keys should differ")
+	}
+	if got, err := rotated.Open(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("open with the old key = %q, %v", got, err)
+	}
+	resealed, err := rotated.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(resealed), valuePrefix+rotated.KeyID()+":") {
+		t.Errorf("value sealed with the old key: %q", resealed)
+	}
+	if _, err := old.Open(resealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("open with an unknown key = %v", err)
+	}
+}
+
+func TestOpenInvalidValue(t *testing.T) {
+	key, _ := GenerateMasterKey()
+	k := newTestKeyring(t, key)
+	sealed, err := k.Seal([]byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(string(sealed[len(valuePrefix):]), ":")
+	join := func(parts ...string) []byte { return []byte(valuePrefix + strings.Join(parts, ":")) }
+	// tamper flips a bit of the decoded part, so that the base64 stays valid
+	tamper := func(part string) string {
+		b := []byte(part)
+		if b[0] == 'A' {
+			b[0] = 'B'
+		} else {
+			b[0] = 'A'
+		}
+		return string(b)
+	}
+
+	tests := map[string]struct {
+		value []byte
+		err   error
+	}{
+		"plain":             {[]byte("value"), ErrInvalidValue},
+		"missing parts":     {join(parts[0], parts[1]), ErrInvalidValue},
+		"extra parts":       {join(parts[0], parts[1], parts[2], ""), ErrInvalidValue},
+		"unknown key":       {join("00000000", parts[1], parts[2]), ErrUnknownKey},
+		"invalid base64":    {join(parts[0], parts[1], "!"+parts[2]), ErrInvalidValue},
+		"tampered data key": {join(parts[0], tamper(parts[1]), parts[2]), ErrInvalidValue},
+		"tampered value":    {join(parts[0], parts[1], tamper(parts[2])), ErrInvalidValue},
+		"truncated value":   {join(parts[0], parts[1], "AAAA"), ErrInvalidValue},
+	}
+	for name, tt := range tests {
+		if _, err := k.Open(tt.value); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", name, err, tt.err)
+		}
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	key, _ := GenerateMasterKey()
+	if b, err := ParseMasterKey(" " + key + "\n"); err != nil || len(b) != keySize {
+		t.Errorf("parse = %d bytes, %v", len(b), err)
+	}
+	for _, s := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := ParseMasterKey(s); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("parse %q = %v", s, err)
+		}
+	}
+	if _, err := NewKeyring(); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("keyring without keys = %v", err)
+	}
+}
@@ -69,6 +69,10 @@ func (a *acl) ListRoles(ctx context.Context) (*consul.Response[[]*consul.ACLRole
 	return a.client.ACL().RoleList(ctx, consul.QueryOptionsFromContext(ctx))
 }
 
+func (a *acl) ListRolesFiltered(ctx context.Context, r consul.ACLRoleFilterOptions) (*consul.Response[[]*consul.ACLRole], error) {
+	return a.client.ACL().RoleListFiltered(ctx, consul.QueryOptionsFromContext(ctx), r)
+}
+
 func (a *acl) ReadRole(ctx context.Context, id string) (*consul.Response[*consul.ACLRole], error) {
 	return a.client.ACL().RoleRead(ctx, id, consul.QueryOptionsFromContext(ctx))
 }
@@ -31,6 +31,22 @@ func (a *admin) Write(ctx context.Context, key, value string) (*consul.Response[
 	return a.client.KV().Put(ctx, &consul.KVPair{Key: key, Value: []byte(value)}, a.w)
 }
 
+func (a *admin) WriteFlags(ctx context.Context, key, value string, flags uint64) (*consul.Response[bool], error) {
+	return a.client.KV().Put(ctx, &consul.KVPair{Key: key, Value: []byte(value), Flags: flags}, a.w)
+}
+
+func (a *admin) WriteCAS(ctx context.Context, key, value string, flags, index uint64) (*consul.Response[bool], error) {
+	return a.client.KV().CAS(ctx, &consul.KVPair{Key: key, Value: []byte(value), Flags: flags, ModifyIndex: index}, a.w)
+}
+
+func (a *admin) Acquire(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error) {
+	return a.client.KV().Acquire(ctx, pair, a.w)
+}
+
+func (a *admin) Release(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error) {
+	return a.client.KV().Release(ctx, pair, a.w)
+}
+
 func (a *admin) Delete(ctx context.Context, key string) (*consul.Response[bool], error) {
 	if key[len(key)-1] == '/' {
 		return a.client.KV().DeleteTree(ctx, key, consul.WriteOptionsFromContext(ctx))
@@ -38,6 +54,14 @@ func (a *admin) Delete(ctx context.Context, key string) (*consul.Response[bool],
 	return a.client.KV().Delete(ctx, key, a.w)
 }
 
+func (a *admin) DeleteCAS(ctx context.Context, key string, index uint64) (*consul.Response[bool], error) {
+	return a.client.KV().DeleteCAS(ctx, &consul.KVPair{Key: key, ModifyIndex: index}, a.w)
+}
+
+func (a *admin) Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error) {
+	return a.client.KV().Txn(ctx, ops, a.w)
+}
+
 func (a *admin) WatchKeys(ctx context.Context, prefix string, onResponse func(*consul.Response[[]string], error) (stop bool)) {
 	a.client.KV().WatchKeys(ctx, prefix, a.q, onResponse)
 }
@@ -98,6 +122,10 @@ func (a *admin) ListRoles(ctx context.Context) (*consul.Response[[]*consul.ACLRo
 	return a.client.ACL().RoleList(ctx, a.q)
 }
 
+func (a *admin) ListRolesFiltered(ctx context.Context, r consul.ACLRoleFilterOptions) (*consul.Response[[]*consul.ACLRole], error) {
+	return a.client.ACL().RoleListFiltered(ctx, a.q, r)
+}
+
 func (a *admin) ReadRole(ctx context.Context, id string) (*consul.Response[*consul.ACLRole], error) {
 	return a.client.ACL().RoleRead(ctx, id, a.q)
 }
@@ -0,0 +1,42 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type catalog struct {
+	client *consul.Client
+}
+
+func (c *catalog) ListDatacenters(ctx context.Context) (*consul.Response[[]string], error) {
+	return c.client.Catalog().Datacenters(ctx, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *catalog) ListServices(ctx context.Context) (*consul.Response[map[string][]string], error) {
+	return c.client.Catalog().Services(ctx, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *catalog) ListNodes(ctx context.Context) (*consul.Response[[]*consul.Node], error) {
+	return c.client.Catalog().Nodes(ctx, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *catalog) ReadNode(ctx context.Context, node string) (*consul.Response[*consul.CatalogNode], error) {
+	return c.client.Catalog().Node(ctx, node, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *catalog) ListNodeChecks(ctx context.Context, node string) (*consul.Response[[]*consul.HealthCheck], error) {
+	return c.client.Health().Node(ctx, node, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *catalog) ListServiceHealth(ctx context.Context, service string, tags []string, passingOnly bool) (*consul.Response[[]*consul.ServiceEntry], error) {
+	return c.client.Health().Service(ctx, service, tags, passingOnly, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *catalog) ListChecksByState(ctx context.Context, state string) (*consul.Response[[]*consul.HealthCheck], error) {
+	return c.client.Health().State(ctx, state, consul.QueryOptionsFromContext(ctx))
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type configEntry struct {
+	client *consul.Client
+}
+
+func (c *configEntry) ListConfigEntries(ctx context.Context, kind string) (*consul.Response[[]consul.ConfigEntry], error) {
+	return c.client.ConfigEntries().List(ctx, kind, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *configEntry) ReadConfigEntry(ctx context.Context, kind, name string) (*consul.Response[consul.ConfigEntry], error) {
+	return c.client.ConfigEntries().Get(ctx, kind, name, consul.QueryOptionsFromContext(ctx))
+}
+
+func (c *configEntry) WriteConfigEntry(ctx context.Context, entry consul.ConfigEntry) (*consul.Response[bool], error) {
+	return c.client.ConfigEntries().Set(ctx, entry, consul.WriteOptionsFromContext(ctx))
+}
+
+func (c *configEntry) CASConfigEntry(ctx context.Context, entry consul.ConfigEntry, index uint64) (*consul.Response[bool], error) {
+	return c.client.ConfigEntries().CAS(ctx, entry, index, consul.WriteOptionsFromContext(ctx))
+}
+
+func (c *configEntry) DeleteConfigEntry(ctx context.Context, kind, name string) (*consul.Response[bool], error) {
+	return c.client.ConfigEntries().Delete(ctx, kind, name, consul.WriteOptionsFromContext(ctx))
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitCommitterName  = "Consee"
+	gitCommitterEmail = "consee@localhost"
+)
+
+// git runs the git command in the working copy.
+type git struct {
+	dir    string
+	remote string
+}
+
+func (g *git) Dir() string {
+	return g.dir
+}
+
+func (g *git) run(ctx context.Context, args ...string) (string, error) {
+	sub := args[0]
+	args = append([]string{
+		"-C", g.dir,
+		"-c", "user.name=" + gitCommitterName,
+		"-c", "user.email=" + gitCommitterEmail,
+	}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New("git " + sub + ": " + msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *git) Open(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.dir, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(g.dir, 0o700); err != nil {
+		return err
+	}
+	if g.remote == "" {
+		_, err := g.run(ctx, "init", "-q")
+		return err
+	}
+	_, err := g.run(ctx, "clone", "-q", g.remote, ".")
+	return err
+}
+
+// remoteEmpty reports whether the remote has no branches, e.g. a new bare repository.
+func (g *git) remoteEmpty(ctx context.Context) (bool, error) {
+	out, err := g.run(ctx, "ls-remote", "--heads", "origin")
+	return out == "", err
+}
+
+func (g *git) Pull(ctx context.Context) error {
+	if g.remote == "" {
+		return nil
+	}
+	if empty, err := g.remoteEmpty(ctx); err != nil || empty {
+		return err
+	}
+	_, err := g.run(ctx, "pull", "-q", "--ff-only", "origin", "HEAD")
+	return err
+}
+
+func (g *git) Commit(ctx context.Context, message, author string) (string, error) {
+	if _, err := g.run(ctx, "add", "-A"); err != nil {
+		return "", err
+	}
+	status, err := g.run(ctx, "status", "--porcelain")
+	if err != nil || status == "" {
+		return "", err
+	}
+	if _, err = g.run(ctx, "commit", "-q", "-m", message, "--author", author+" <"+gitCommitterEmail+">"); err != nil {
+		return "", err
+	}
+	return g.Head(ctx)
+}
+
+func (g *git) Push(ctx context.Context) error {
+	if g.remote == "" {
+		return nil
+	}
+	_, err := g.run(ctx, "push", "-q", "origin", "HEAD")
+	return err
+}
+
+func (g *git) Ahead(ctx context.Context) (bool, error) {
+	if g.remote == "" {
+		return false, nil
+	}
+	head, err := g.Head(ctx)
+	if err != nil || head == "" {
+		return false, err
+	}
+	branch, err := g.run(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return false, err
+	}
+	// the working copy is pulled before, so the remote branch is either HEAD or behind it
+	out, err := g.run(ctx, "ls-remote", "origin", "refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+	remote, _, _ := strings.Cut(out, "\t")
+	return remote != head, nil
+}
+
+func (g *git) Head(ctx context.Context) (string, error) {
+	// rev-parse fails if nothing is committed
+	if _, err := g.run(ctx, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		return "", nil
+	}
+	return g.run(ctx, "rev-parse", "HEAD")
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type intention struct {
+	client *consul.Client
+}
+
+func (i *intention) ListIntentions(ctx context.Context) (*consul.Response[[]*consul.Intention], error) {
+	return i.client.Intentions().List(ctx, consul.QueryOptionsFromContext(ctx))
+}
+
+func (i *intention) ReadIntention(ctx context.Context, source, destination string) (*consul.Response[*consul.Intention], error) {
+	return i.client.Intentions().ReadExact(ctx, source, destination, consul.QueryOptionsFromContext(ctx))
+}
+
+func (i *intention) UpsertIntention(ctx context.Context, in *consul.Intention) (*consul.Response[bool], error) {
+	return i.client.Intentions().Upsert(ctx, in, consul.WriteOptionsFromContext(ctx))
+}
+
+func (i *intention) DeleteIntention(ctx context.Context, source, destination string) (*consul.Response[bool], error) {
+	return i.client.Intentions().DeleteExact(ctx, source, destination, consul.WriteOptionsFromContext(ctx))
+}
+
+func (i *intention) CheckIntention(ctx context.Context, source, destination string) (*consul.Response[bool], error) {
+	return i.client.Intentions().Check(ctx, source, destination, consul.QueryOptionsFromContext(ctx))
+}
+
+func (i *intention) MatchIntentions(ctx context.Context, by, name string) (*consul.Response[[]*consul.Intention], error) {
+	return i.client.Intentions().Match(ctx, by, name, consul.QueryOptionsFromContext(ctx))
+}
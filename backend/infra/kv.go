@@ -29,6 +29,22 @@ func (kv *kv) Write(ctx context.Context, key, value string) (*consul.Response[bo
 	return kv.client.KV().Put(ctx, &consul.KVPair{Key: key, Value: []byte(value)}, consul.WriteOptionsFromContext(ctx))
 }
 
+func (kv *kv) WriteFlags(ctx context.Context, key, value string, flags uint64) (*consul.Response[bool], error) {
+	return kv.client.KV().Put(ctx, &consul.KVPair{Key: key, Value: []byte(value), Flags: flags}, consul.WriteOptionsFromContext(ctx))
+}
+
+func (kv *kv) WriteCAS(ctx context.Context, key, value string, flags, index uint64) (*consul.Response[bool], error) {
+	return kv.client.KV().CAS(ctx, &consul.KVPair{Key: key, Value: []byte(value), Flags: flags, ModifyIndex: index}, consul.WriteOptionsFromContext(ctx))
+}
+
+func (kv *kv) Acquire(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error) {
+	return kv.client.KV().Acquire(ctx, pair, consul.WriteOptionsFromContext(ctx))
+}
+
+func (kv *kv) Release(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error) {
+	return kv.client.KV().Release(ctx, pair, consul.WriteOptionsFromContext(ctx))
+}
+
 func (kv *kv) Delete(ctx context.Context, key string) (*consul.Response[bool], error) {
 	if key[len(key)-1] == '/' {
 		return kv.client.KV().DeleteTree(ctx, key, consul.WriteOptionsFromContext(ctx))
@@ -36,6 +52,14 @@ func (kv *kv) Delete(ctx context.Context, key string) (*consul.Response[bool], e
 	return kv.client.KV().Delete(ctx, key, consul.WriteOptionsFromContext(ctx))
 }
 
+func (kv *kv) DeleteCAS(ctx context.Context, key string, index uint64) (*consul.Response[bool], error) {
+	return kv.client.KV().DeleteCAS(ctx, &consul.KVPair{Key: key, ModifyIndex: index}, consul.WriteOptionsFromContext(ctx))
+}
+
+func (kv *kv) Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error) {
+	return kv.client.KV().Txn(ctx, ops, consul.WriteOptionsFromContext(ctx))
+}
+
 func (kv *kv) WatchKeys(ctx context.Context, prefix string, onResponse func(*consul.Response[[]string], error) (stop bool)) {
 	kv.client.KV().WatchKeys(ctx, prefix, consul.QueryOptionsFromContext(ctx), onResponse)
 }
@@ -0,0 +1,160 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+	"go.etcd.io/bbolt"
+)
+
+// consulMetadata stores metadata in consul KV under ConseeInternalKeyPrefix with the admin token.
+type consulMetadata struct {
+	admin repo.AdminRepo
+}
+
+func statusError(status int) error {
+	if status == http.StatusForbidden {
+		return repo.ErrMetadataPermissionDenied
+	}
+	return fmt.Errorf("unexpected status %d", status)
+}
+
+func (m *consulMetadata) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp, err := m.admin.Read(ctx, ConseeInternalKeyPrefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	switch resp.Status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, statusError(resp.Status)
+	}
+	if resp.Err != nil {
+		return nil, false, resp.Err
+	}
+	if resp.Body == nil {
+		return nil, false, nil
+	}
+	return resp.Body.Value, true, nil
+}
+
+func (m *consulMetadata) Put(ctx context.Context, key string, value []byte) error {
+	resp, err := m.admin.Write(ctx, ConseeInternalKeyPrefix+key, string(value))
+	if err != nil {
+		return err
+	}
+	if resp.Status != http.StatusOK {
+		return statusError(resp.Status)
+	}
+	return nil
+}
+
+// Delete deletes exactly the key. AdminRepo.Delete is not used since it deletes the tree of keys ending with "/",
+// e.g. schemas of nested prefixes along with "kvmeta/schema/app/".
+func (m *consulMetadata) Delete(ctx context.Context, key string) error {
+	resp, err := m.admin.Txn(ctx, []*consul.KVTxnOp{{Verb: consul.KVDelete, Key: ConseeInternalKeyPrefix + key}})
+	if err != nil {
+		return err
+	}
+	if resp.Status != http.StatusOK {
+		return statusError(resp.Status)
+	}
+	return nil
+}
+
+func (m *consulMetadata) List(ctx context.Context, prefix string) ([]repo.MetadataPair, error) {
+	resp, err := m.admin.List(ctx, ConseeInternalKeyPrefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Status {
+	case http.StatusOK, http.StatusNotFound:
+	default:
+		return nil, statusError(resp.Status)
+	}
+	pairs := make([]repo.MetadataPair, 0, len(resp.Body))
+	for _, pair := range resp.Body {
+		pairs = append(pairs, repo.MetadataPair{Key: strings.TrimPrefix(pair.Key, ConseeInternalKeyPrefix), Value: pair.Value})
+	}
+	return pairs, nil
+}
+
+var metadataBucket = []byte("metadata")
+
+// boltMetadata stores metadata in a local bbolt database, so that it's out of consul.
+type boltMetadata struct {
+	db *bbolt.DB
+}
+
+func openBolt(path string) (*boltMetadata, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	// the file is locked by a running consee, so the timeout fails fast instead of waiting forever
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(metadataBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltMetadata{db: db}, nil
+}
+
+func (m *boltMetadata) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	err = m.db.View(func(tx *bbolt.Tx) error {
+		// values are only valid in the transaction
+		if v := tx.Bucket(metadataBucket).Get([]byte(key)); v != nil {
+			value, ok = bytes.Clone(v), true
+		}
+		return nil
+	})
+	return value, ok, err
+}
+
+func (m *boltMetadata) Put(ctx context.Context, key string, value []byte) error {
+	return m.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(metadataBucket).Put([]byte(key), value)
+	})
+}
+
+func (m *boltMetadata) Delete(ctx context.Context, key string) error {
+	return m.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(metadataBucket).Delete([]byte(key))
+	})
+}
+
+func (m *boltMetadata) List(ctx context.Context, prefix string) ([]repo.MetadataPair, error) {
+	pairs := []repo.MetadataPair{}
+	err := m.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(metadataBucket).Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			pairs = append(pairs, repo.MetadataPair{Key: string(k), Value: bytes.Clone(v)})
+		}
+		return nil
+	})
+	return pairs, err
+}
+
+func (m *boltMetadata) Close() error {
+	return m.db.Close()
+}
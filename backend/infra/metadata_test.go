@@ -0,0 +1,163 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// fakeAdminRepo keeps keys in memory. Delete deletes the tree of keys ending with "/" like consul.
+type fakeAdminRepo struct {
+	repo.AdminRepo
+	values map[string]string
+}
+
+func (f *fakeAdminRepo) Read(ctx context.Context, key string) (*consul.Response[*consul.KVPair], error) {
+	v, ok := f.values[key]
+	if !ok {
+		return &consul.Response[*consul.KVPair]{Status: http.StatusNotFound}, nil
+	}
+	return &consul.Response[*consul.KVPair]{Status: http.StatusOK, Body: &consul.KVPair{Key: key, Value: []byte(v)}}, nil
+}
+
+func (f *fakeAdminRepo) Write(ctx context.Context, key, value string) (*consul.Response[bool], error) {
+	f.values[key] = value
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+func (f *fakeAdminRepo) Delete(ctx context.Context, key string) (*consul.Response[bool], error) {
+	for k := range f.values {
+		if k == key || strings.HasSuffix(key, "/") && strings.HasPrefix(k, key) {
+			delete(f.values, k)
+		}
+	}
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+func (f *fakeAdminRepo) Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error) {
+	for _, op := range ops {
+		if op.Verb != consul.KVDelete {
+			return &consul.Response[*consul.TxnResponse]{Status: http.StatusBadRequest}, nil
+		}
+		delete(f.values, op.Key)
+	}
+	return &consul.Response[*consul.TxnResponse]{Status: http.StatusOK, Body: &consul.TxnResponse{}}, nil
+}
+
+func (f *fakeAdminRepo) List(ctx context.Context, prefix string) (*consul.Response[[]*consul.KVPair], error) {
+	var pairs []*consul.KVPair
+	for k, v := range f.values {
+		if strings.HasPrefix(k, prefix) {
+			pairs = append(pairs, &consul.KVPair{Key: k, Value: []byte(v)})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b *consul.KVPair) int { return strings.Compare(a.Key, b.Key) })
+	return &consul.Response[[]*consul.KVPair]{Status: http.StatusOK, Body: pairs}, nil
+}
+
+func TestMetadataStore(t *testing.T) {
+	bolt, closer, err := NewBoltMetadata(filepath.Join(t.TempDir(), "data", "consee.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	stores := map[string]repo.MetadataStore{
+		"consul": NewConsulMetadata(&fakeAdminRepo{values: map[string]string{}}),
+		"bolt":   bolt,
+	}
+	ctx := context.Background()
+	for name, m := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"kvmeta/schema/", "kvmeta/schema/app/", "kvmeta/schema/app/db/", "kvmeta/schema/app2", "kvmeta/secret/app/"} {
+				if err := m.Put(ctx, key, []byte("v "+key)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if v, ok, err := m.Get(ctx, "kvmeta/schema/app/"); err != nil || !ok || string(v) != "v kvmeta/schema/app/" {
+				t.Fatalf("get = %q, %v, %v", v, ok, err)
+			}
+			if _, ok, err := m.Get(ctx, "kvmeta/schema/missing"); err != nil || ok {
+				t.Fatalf("get missing = %v, %v", ok, err)
+			}
+
+			// deleting the schema of a prefix keeps schemas of nested prefixes
+			for _, key := range []string{"kvmeta/schema/app/", "kvmeta/schema/"} {
+				if err := m.Delete(ctx, key); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.Delete(ctx, "kvmeta/schema/missing"); err != nil {
+				t.Fatalf("delete missing: %v", err)
+			}
+			pairs, err := m.List(ctx, "kvmeta/schema/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, pair := range pairs {
+				keys = append(keys, pair.Key)
+			}
+			if want := []string{"kvmeta/schema/app/db/", "kvmeta/schema/app2"}; !slices.Equal(keys, want) {
+				t.Errorf("schemas after delete = %v, want %v", keys, want)
+			}
+			if _, ok, _ := m.Get(ctx, "kvmeta/secret/app/"); !ok {
+				t.Error("other metadata deleted")
+			}
+
+			// so does unmarking the secret of a prefix
+			if err := m.Put(ctx, "kvmeta/secret/app/db", nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Delete(ctx, "kvmeta/secret/app/"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := m.Get(ctx, "kvmeta/secret/app/db"); !ok {
+				t.Error("secret of a nested key deleted with its prefix")
+			}
+		})
+	}
+}
+
+func TestBoltMetadata(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "consee.db")
+	m, closer, err := NewBoltMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put(ctx, "kvmeta/valuetype/YQ==", []byte("json")); err != nil {
+		t.Fatal(err)
+	}
+	// the file is locked by the open store
+	if _, c, err := NewBoltMetadata(path); err == nil {
+		c.Close()
+		t.Fatal("expected the locked file not to be opened again")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, closer, err = NewBoltMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	if v, ok, err := m.Get(ctx, "kvmeta/valuetype/YQ=="); err != nil || !ok || string(v) != "json" {
+		t.Errorf("value after reopen = %q, %v, %v", v, ok, err)
+	}
+	if pairs, err := m.List(ctx, "kvmeta/valuetype/Y"); err != nil || len(pairs) != 1 {
+		t.Errorf("list = %v, %v", pairs, err)
+	}
+	if pairs, err := m.List(ctx, "kvmeta/valuetype/Z"); err != nil || len(pairs) != 0 {
+		t.Errorf("list of another prefix = %v, %v", pairs, err)
+	}
+}
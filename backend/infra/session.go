@@ -0,0 +1,34 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type session struct {
+	client *consul.Client
+}
+
+func (s *session) CreateSession(ctx context.Context, se *consul.SessionEntry) (*consul.Response[string], error) {
+	return s.client.Session().Create(ctx, se, consul.WriteOptionsFromContext(ctx))
+}
+
+func (s *session) RenewSession(ctx context.Context, id string) (*consul.Response[*consul.SessionEntry], error) {
+	return s.client.Session().Renew(ctx, id, consul.WriteOptionsFromContext(ctx))
+}
+
+func (s *session) DestroySession(ctx context.Context, id string) (*consul.Response[bool], error) {
+	return s.client.Session().Destroy(ctx, id, consul.WriteOptionsFromContext(ctx))
+}
+
+func (s *session) ReadSession(ctx context.Context, id string) (*consul.Response[*consul.SessionEntry], error) {
+	return s.client.Session().Info(ctx, id, consul.QueryOptionsFromContext(ctx))
+}
+
+func (s *session) ListSessions(ctx context.Context) (*consul.Response[[]*consul.SessionEntry], error) {
+	return s.client.Session().List(ctx, consul.QueryOptionsFromContext(ctx))
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package infra
+
+import (
+	"context"
+	"io"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type snapshot struct {
+	client *consul.Client
+}
+
+func (s *snapshot) SaveSnapshot(ctx context.Context) (*consul.Response[io.ReadCloser], error) {
+	return s.client.Snapshot().Save(ctx, consul.QueryOptionsFromContext(ctx))
+}
+
+func (s *snapshot) RestoreSnapshot(ctx context.Context, r io.Reader) (*consul.Response[bool], error) {
+	return s.client.Snapshot().Restore(ctx, r, consul.WriteOptionsFromContext(ctx))
+}
@@ -4,6 +4,8 @@
 package infra
 
 import (
+	"io"
+
 	"github.com/FlyingOnion/consee/backend/consul"
 	"github.com/FlyingOnion/consee/backend/repo"
 )
@@ -13,6 +15,16 @@ var (
 	_ repo.KVRepo  = &admin{}
 	_ repo.ACLRepo = &acl{}
 	_ repo.ACLRepo = &admin{}
+
+	_ repo.CatalogRepo     = &catalog{}
+	_ repo.IntentionRepo   = &intention{}
+	_ repo.ConfigEntryRepo = &configEntry{}
+	_ repo.SnapshotRepo    = &snapshot{}
+	_ repo.SessionRepo     = &session{}
+	_ repo.GitRepo         = &git{}
+
+	_ repo.MetadataStore = &consulMetadata{}
+	_ repo.MetadataStore = &boltMetadata{}
 )
 
 func NewKV(client *consul.Client) repo.KVRepo {
@@ -26,3 +38,44 @@ func NewAdmin(client *consul.Client, qAdmin *consul.QueryOptions, wAdmin *consul
 func NewACL(client *consul.Client) repo.ACLRepo {
 	return &acl{client: client}
 }
+
+func NewCatalog(client *consul.Client) repo.CatalogRepo {
+	return &catalog{client: client}
+}
+
+func NewIntention(client *consul.Client) repo.IntentionRepo {
+	return &intention{client: client}
+}
+
+func NewConfigEntry(client *consul.Client) repo.ConfigEntryRepo {
+	return &configEntry{client: client}
+}
+
+func NewSnapshot(client *consul.Client) repo.SnapshotRepo {
+	return &snapshot{client: client}
+}
+
+func NewSession(client *consul.Client) repo.SessionRepo {
+	return &session{client: client}
+}
+
+// NewGit returns the git working copy in dir.
+// If remote is not empty, it's cloned into dir, and pulled and pushed by sync.
+func NewGit(dir, remote string) repo.GitRepo {
+	return &git{dir: dir, remote: remote}
+}
+
+// NewConsulMetadata stores metadata in consul KV under ConseeInternalKeyPrefix with the admin repo.
+func NewConsulMetadata(admin repo.AdminRepo) repo.MetadataStore {
+	return &consulMetadata{admin: admin}
+}
+
+// NewBoltMetadata stores metadata in the bbolt database file at path, which is created if it doesn't exist.
+// The returned closer closes the database.
+func NewBoltMetadata(path string) (repo.MetadataStore, io.Closer, error) {
+	m, err := openBolt(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, m, nil
+}
@@ -7,6 +7,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -17,7 +18,9 @@ import (
 
 	httpadapter "github.com/FlyingOnion/consee/backend/adapter/http"
 	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
 	"github.com/FlyingOnion/consee/backend/infra"
+	"github.com/FlyingOnion/consee/backend/repo"
 	"github.com/FlyingOnion/consee/backend/service"
 	"github.com/spf13/pflag"
 
@@ -25,10 +28,15 @@ import (
 )
 
 var (
-	configfile string
-	verbose    int
-	t          string
-	port       int
+	configfile   string
+	verbose      int
+	t            string
+	port         int
+	genBackupKey bool
+	genValueKey  bool
+
+	migrateMetadataFrom  string
+	migrateMetadataPurge bool
 )
 
 func parseCmd() {
@@ -36,6 +44,10 @@ func parseCmd() {
 	pflag.StringVarP(&t, "token", "t", "", "consee admin token")
 	pflag.IntVarP(&verbose, "verbose", "v", 0, "show more output")
 	pflag.IntVarP(&port, "port", "p", 3668, "http server port")
+	pflag.BoolVar(&genBackupKey, "gen-backup-key", false, "generate a key pair for encrypted backups and exit")
+	pflag.BoolVar(&genValueKey, "gen-value-key", false, "generate a master key for encrypted values and exit")
+	pflag.StringVar(&migrateMetadataFrom, "migrate-metadata-from", "", "copy metadata from another backend (consul or bolt) to the configured one and exit")
+	pflag.BoolVar(&migrateMetadataPurge, "migrate-metadata-purge", false, "delete metadata from the backend of --migrate-metadata-from after it's copied and verified")
 	pflag.Parse()
 }
 
@@ -66,6 +78,25 @@ parseFromFlags:
 
 func main() {
 	parseCmd()
+	if genBackupKey {
+		key, err := encrypt.GenerateKey()
+		if err != nil {
+			slog.Error("failed to generate key pair", "error", err)
+			os.Exit(1)
+		}
+		fmt.Println("public key (backup.public_key in config):", encrypt.EncodeKey(key.PublicKey().Bytes()))
+		fmt.Println("private key (keep it safe, required to restore):", encrypt.EncodeKey(key.Bytes()))
+		return
+	}
+	if genValueKey {
+		key, err := encrypt.GenerateMasterKey()
+		if err != nil {
+			slog.Error("failed to generate master key", "error", err)
+			os.Exit(1)
+		}
+		fmt.Println("master key (encryption.keys in config, keep it safe):", key)
+		return
+	}
 	parseConfig()
 
 	client := consul.NewClient()
@@ -80,11 +111,105 @@ func main() {
 	adminRepo := infra.NewAdmin(client, qAdmin, wAdmin)
 	kvRepo := infra.NewKV(client)
 	aclRepo := infra.NewACL(client)
+	catalogRepo := infra.NewCatalog(client)
+	intentionRepo := infra.NewIntention(client)
+	configEntryRepo := infra.NewConfigEntry(client)
+	snapshotRepo := infra.NewSnapshot(client)
 
-	adminService := service.NewAdminService(adminRepo)
-	kvService := service.NewKVService(kvRepo, adminService)
+	metadataStore, metadataCloser, err := config.Metadata.metadataStore(config.Metadata.Backend, adminRepo)
+	if err != nil {
+		slog.Error("failed to open metadata store", "backend", config.Metadata.Backend, "error", err)
+		os.Exit(1)
+	}
+	if metadataCloser != nil {
+		defer metadataCloser.Close()
+	}
+	// os.Exit skips deferred calls, so the store is closed before, e.g. to flush and unlock the bolt file
+	exit := func(code int) {
+		if metadataCloser != nil {
+			metadataCloser.Close()
+		}
+		os.Exit(code)
+	}
+	if migrateMetadataFrom != "" {
+		if err := migrateMetadata(metadataStore, adminRepo); err != nil {
+			slog.Error("failed to migrate metadata", "from", migrateMetadataFrom, "to", config.Metadata.Backend, "error", err)
+			exit(1)
+		}
+		return
+	}
+
+	keyring, err := config.Encryption.keyring()
+	if err != nil {
+		slog.Error("invalid encryption keys", "error", err)
+		exit(1)
+	}
+	var encryption *service.ValueEncryption
+	if keyring != nil {
+		encryption = &service.ValueEncryption{Prefixes: config.Encryption.Prefixes, Keyring: keyring}
+	} else if len(config.Encryption.Prefixes) > 0 {
+		slog.Error("encryption prefixes are set, but no master key is configured")
+		exit(1)
+	}
+
+	adminService := service.NewAdminService(adminRepo, metadataStore)
+	sessionRepo := infra.NewSession(client)
+	kvService := service.NewKVService(kvRepo, sessionRepo, adminService, encryption)
 	aclService := service.NewACLService(aclRepo, adminService)
-	a2 := service.NewA2(kvService, aclService, adminService)
+	catalogService := service.NewCatalogService(catalogRepo)
+	intentionService := service.NewIntentionService(intentionRepo)
+	configEntryService := service.NewConfigEntryService(configEntryRepo)
+	snapshotService := service.NewSnapshotService(snapshotRepo, aclRepo, adminService)
+	promoteService := service.NewPromoteService(kvRepo, aclRepo, adminService, encryption)
+	schemaService := service.NewSchemaService(kvRepo, aclRepo, adminService)
+	lockService := service.NewLockService(kvRepo, sessionRepo, aclRepo, adminService)
+	secretService := service.NewSecretService(kvService, aclRepo, adminService)
+	var encryptionService service.EncryptionService
+	if encryption != nil {
+		encryptionService = service.NewEncryptionService(kvRepo, aclRepo, adminService, encryption)
+	}
+	a2 := service.NewA2(kvService, aclService, adminService, intentionService, configEntryService, config.Template.VarsDir)
+
+	backupOptions := service.BackupOptions{
+		Prefixes: config.Backup.Prefixes,
+		ACL:      config.Backup.ACL,
+		Dir:      config.Backup.Dir,
+		Keep:     config.Backup.Keep,
+	}
+	if config.Backup.PublicKey != "" {
+		recipient, err := encrypt.ParsePublicKey(config.Backup.PublicKey)
+		if err != nil {
+			slog.Error("invalid backup public key", "error", err)
+			exit(1)
+		}
+		backupOptions.Recipient = recipient
+	}
+	backupService := service.NewBackupService(a2, kvService, aclRepo, adminService, backupOptions)
+
+	var syncService service.SyncService
+	syncOptions := service.SyncOptions{
+		Prefix:  config.Sync.Prefix,
+		Mode:    config.Sync.Mode,
+		Watch:   config.Sync.Watch,
+		Prune:   config.Sync.Prune,
+		Secrets: config.Sync.Secrets,
+	}
+	if config.Sync.Dir != "" {
+		if syncOptions.Mode != service.SyncModePush && syncOptions.Mode != service.SyncModePull {
+			slog.Error("invalid sync mode", "mode", syncOptions.Mode)
+			exit(1)
+		}
+		if config.Sync.Interval != "" {
+			interval, err := time.ParseDuration(config.Sync.Interval)
+			if err != nil || interval <= 0 {
+				slog.Error("invalid sync interval", "interval", config.Sync.Interval)
+				exit(1)
+			}
+			syncOptions.Interval = interval
+		}
+		gitRepo := infra.NewGit(config.Sync.Dir, config.Sync.Remote)
+		syncService = service.NewSyncService(kvRepo, gitRepo, aclRepo, adminService, encryption, syncOptions)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	initCtx := consul.ContextWithQueryOptions(ctx, qAdmin)
@@ -93,10 +218,23 @@ func main() {
 	if err := a2.Initialize(initCtx); err != nil {
 		slog.Error("failed to initialize", "error", err)
 		cancel()
-		os.Exit(1)
+		exit(1)
 	}
 
-	httpAdapter := httpadapter.NewAdapter(a2, kvService, aclService, adminService)
+	httpAdapter := httpadapter.NewAdapter(a2, kvService, aclService, adminService,
+		httpadapter.WithCatalogService(catalogService),
+		httpadapter.WithIntentionService(intentionService),
+		httpadapter.WithConfigEntryService(configEntryService),
+		httpadapter.WithSnapshotService(snapshotService),
+		httpadapter.WithBackupService(backupService),
+		httpadapter.WithSyncService(syncService),
+		httpadapter.WithPromoteService(promoteService),
+		httpadapter.WithSchemaService(schemaService),
+		httpadapter.WithLockService(lockService),
+		httpadapter.WithSecretService(secretService),
+		httpadapter.WithEncryptionService(encryptionService),
+		httpadapter.WithMaxImportSize(config.MaxImportSize),
+	)
 	httpServer := &http.Server{
 		Addr:    ":" + strconv.Itoa(config.Port),
 		Handler: httpAdapter.Handler(),
@@ -106,6 +244,31 @@ func main() {
 		IdleTimeout:       30 * time.Second,
 	}
 
+	if config.Snapshot.Interval != "" {
+		interval, err := time.ParseDuration(config.Snapshot.Interval)
+		if err != nil || interval <= 0 {
+			slog.Error("invalid snapshot interval", "interval", config.Snapshot.Interval)
+			cancel()
+			exit(1)
+		}
+		qSnapshot := qAdmin.Copy()
+		qSnapshot.AllowStale = config.Snapshot.Stale
+		snapshotCtx := consul.ContextWithQueryOptions(initCtx, qSnapshot)
+		go service.RunSnapshotSchedule(snapshotCtx, snapshotService, interval, config.Snapshot.Dir, config.Snapshot.Keep)
+	}
+
+	if config.Backup.Schedule != "" {
+		if err := service.RunBackupSchedule(initCtx, backupService, config.Backup.Schedule); err != nil {
+			slog.Error("invalid backup schedule", "schedule", config.Backup.Schedule, "error", err)
+			cancel()
+			exit(1)
+		}
+	}
+
+	if syncService != nil {
+		go service.RunSync(initCtx, syncService, kvRepo, syncOptions)
+	}
+
 	sigC := make(chan os.Signal, 1)
 	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
 
@@ -131,3 +294,26 @@ func main() {
 	signal.Stop(sigC)
 	slog.Info("stopped")
 }
+
+// migrateMetadata copies metadata from the backend of --migrate-metadata-from to the configured store.
+func migrateMetadata(to repo.MetadataStore, adminRepo repo.AdminRepo) error {
+	if migrateMetadataFrom == config.Metadata.Backend {
+		return fmt.Errorf("metadata is already stored in %s", migrateMetadataFrom)
+	}
+	from, closer, err := config.Metadata.metadataStore(migrateMetadataFrom, adminRepo)
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	n, err := service.MigrateMetadata(context.Background(), from, to, migrateMetadataPurge)
+	if err != nil {
+		return fmt.Errorf("%d entries copied before the error: %w", n, err)
+	}
+	fmt.Printf("%d metadata entries copied from %s to %s\n", n, migrateMetadataFrom, config.Metadata.Backend)
+	if migrateMetadataPurge {
+		fmt.Printf("%d metadata entries purged from %s\n", n, migrateMetadataFrom)
+	}
+	return nil
+}
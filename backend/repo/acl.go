@@ -26,6 +26,7 @@ type ACLRepo interface {
 	DeletePolicy(ctx context.Context, id string) (*consul.Response[bool], error)
 
 	ListRoles(ctx context.Context) (*consul.Response[[]*consul.ACLRole], error)
+	ListRolesFiltered(ctx context.Context, r consul.ACLRoleFilterOptions) (*consul.Response[[]*consul.ACLRole], error)
 	ReadRole(ctx context.Context, id string) (*consul.Response[*consul.ACLRole], error)
 	ReadRoleByName(ctx context.Context, name string) (*consul.Response[*consul.ACLRole], error)
 	CreateRole(ctx context.Context, req *consul.ACLRole) (*consul.Response[*consul.ACLRole], error)
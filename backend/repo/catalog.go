@@ -0,0 +1,22 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// CatalogRepo is a read-only view of the consul catalog and health checks.
+type CatalogRepo interface {
+	ListDatacenters(ctx context.Context) (*consul.Response[[]string], error)
+	ListServices(ctx context.Context) (*consul.Response[map[string][]string], error)
+	ListNodes(ctx context.Context) (*consul.Response[[]*consul.Node], error)
+	ReadNode(ctx context.Context, node string) (*consul.Response[*consul.CatalogNode], error)
+
+	ListNodeChecks(ctx context.Context, node string) (*consul.Response[[]*consul.HealthCheck], error)
+	ListServiceHealth(ctx context.Context, service string, tags []string, passingOnly bool) (*consul.Response[[]*consul.ServiceEntry], error)
+	ListChecksByState(ctx context.Context, state string) (*consul.Response[[]*consul.HealthCheck], error)
+}
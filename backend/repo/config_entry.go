@@ -0,0 +1,18 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type ConfigEntryRepo interface {
+	ListConfigEntries(ctx context.Context, kind string) (*consul.Response[[]consul.ConfigEntry], error)
+	ReadConfigEntry(ctx context.Context, kind, name string) (*consul.Response[consul.ConfigEntry], error)
+	WriteConfigEntry(ctx context.Context, entry consul.ConfigEntry) (*consul.Response[bool], error)
+	CASConfigEntry(ctx context.Context, entry consul.ConfigEntry, index uint64) (*consul.Response[bool], error)
+	DeleteConfigEntry(ctx context.Context, kind, name string) (*consul.Response[bool], error)
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import "context"
+
+// GitRepo is a local git working copy, which may track a remote.
+type GitRepo interface {
+	// Dir is the root of the working copy.
+	Dir() string
+	// Open clones the remote into the working copy, or initializes an empty one, if it doesn't exist.
+	Open(ctx context.Context) error
+	// Pull fast-forwards the working copy to the remote. It does nothing without a remote.
+	Pull(ctx context.Context) error
+	// Commit stages all changes and commits them with author as the name of the author.
+	// It returns the hash of the commit, or "" if nothing changed.
+	Commit(ctx context.Context, message, author string) (string, error)
+	// Push pushes HEAD to the remote. It does nothing without a remote.
+	Push(ctx context.Context) error
+	// Ahead reports whether HEAD has commits which are not pushed to the remote yet.
+	// It's always false without a remote.
+	Ahead(ctx context.Context) (bool, error)
+	// Head returns the hash of HEAD, or "" if nothing is committed.
+	Head(ctx context.Context) (string, error)
+}
@@ -0,0 +1,19 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type IntentionRepo interface {
+	ListIntentions(ctx context.Context) (*consul.Response[[]*consul.Intention], error)
+	ReadIntention(ctx context.Context, source, destination string) (*consul.Response[*consul.Intention], error)
+	UpsertIntention(ctx context.Context, intention *consul.Intention) (*consul.Response[bool], error)
+	DeleteIntention(ctx context.Context, source, destination string) (*consul.Response[bool], error)
+	CheckIntention(ctx context.Context, source, destination string) (*consul.Response[bool], error)
+	MatchIntentions(ctx context.Context, by, name string) (*consul.Response[[]*consul.Intention], error)
+}
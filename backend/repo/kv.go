@@ -14,6 +14,17 @@ type KVRepo interface {
 	List(ctx context.Context, prefix string) (*consul.Response[[]*consul.KVPair], error)
 	Read(ctx context.Context, key string) (*consul.Response[*consul.KVPair], error)
 	Write(ctx context.Context, key, value string) (*consul.Response[bool], error)
+	// WriteFlags writes the key with flags. Write resets flags of the key to 0.
+	WriteFlags(ctx context.Context, key, value string, flags uint64) (*consul.Response[bool], error)
+	// WriteCAS writes the key with flags only if its ModifyIndex is index, 0 means the key should not exist.
+	WriteCAS(ctx context.Context, key, value string, flags, index uint64) (*consul.Response[bool], error)
+	// Acquire writes the pair and locks it with pair.Session, and Release writes the pair and unlocks it.
+	// The pair is written with CAS if pair.ModifyIndex is not 0.
+	Acquire(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error)
+	Release(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error)
 	Delete(ctx context.Context, key string) (*consul.Response[bool], error)
+	DeleteCAS(ctx context.Context, key string, index uint64) (*consul.Response[bool], error)
+	// Txn runs the operations in a transaction.
+	Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error)
 	WatchKeys(ctx context.Context, prefix string, onResponse func(*consul.Response[[]string], error) (stop bool))
 }
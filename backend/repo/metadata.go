@@ -0,0 +1,30 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMetadataPermissionDenied is returned by MetadataStore if the access is denied, e.g. by the ACL of the admin token.
+var ErrMetadataPermissionDenied = errors.New("permission denied")
+
+type MetadataPair struct {
+	Key   string
+	Value []byte
+}
+
+// MetadataStore stores consee metadata, e.g. value types, schemas, token names and audit records.
+// Keys are paths relative to the store, e.g. "kvmeta/valuetype/<b64key>".
+// It's used behind AdminService only.
+type MetadataStore interface {
+	// Get returns the value of key, ok is false if it doesn't exist.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Put(ctx context.Context, key string, value []byte) error
+	// Delete deletes the key. It's not an error if the key doesn't exist.
+	Delete(ctx context.Context, key string) error
+	// List lists entries under prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]MetadataPair, error)
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import (
+	"context"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type SessionRepo interface {
+	CreateSession(ctx context.Context, se *consul.SessionEntry) (*consul.Response[string], error)
+	RenewSession(ctx context.Context, id string) (*consul.Response[*consul.SessionEntry], error)
+	DestroySession(ctx context.Context, id string) (*consul.Response[bool], error)
+	ReadSession(ctx context.Context, id string) (*consul.Response[*consul.SessionEntry], error)
+	ListSessions(ctx context.Context) (*consul.Response[[]*consul.SessionEntry], error)
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package repo
+
+import (
+	"context"
+	"io"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+type SnapshotRepo interface {
+	SaveSnapshot(ctx context.Context) (*consul.Response[io.ReadCloser], error)
+	RestoreSnapshot(ctx context.Context, r io.Reader) (*consul.Response[bool], error)
+}
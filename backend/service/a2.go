@@ -13,26 +13,39 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/FlyingOnion/consee/backend/buffer"
 	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
 )
 
 type All interface {
 	Initialize(ctx context.Context) error
 	Import(ctx context.Context, req *ImportRequest) (*ImportResponse, error)
-	Export(ctx context.Context, req *ExportRequest) ([]byte, error)
+	// Export writes the archive into w while it's being generated.
+	Export(ctx context.Context, req *ExportRequest, w io.Writer) error
 }
 
 type a2 struct {
-	kv    KVService
-	acl   ACLService
-	admin AdminService
+	kv        KVService
+	acl       ACLService
+	admin     AdminService
+	intention IntentionService
+
+	configEntry ConfigEntryService
+
+	// varsDir holds vars files of templates
+	varsDir string
 }
 
-func NewA2(kv KVService, acl ACLService, admin AdminService) All {
-	return &a2{kv, acl, admin}
+func NewA2(kv KVService, acl ACLService, admin AdminService, intention IntentionService, configEntry ConfigEntryService, varsDir string) All {
+	return &a2{kv, acl, admin, intention, configEntry, varsDir}
 }
 
 func (s *a2) Initialize(ctx context.Context) (err error) {
@@ -59,11 +72,12 @@ func (s *a2) Initialize(ctx context.Context) (err error) {
 	return errNotAdmin
 
 validateIdNameMapping:
-	resp1, _ := s.admin.AdminRepo().Read(ctx, ConseeInternalKeyPrefix+"acl-token/id-name/"+resp0.Body.AccessorID)
+	_, err = s.admin.GetTokenName(ctx, resp0.Body.AccessorID)
 	now := time.Now().Format(time.DateTime)
 	// have checked permissions before
-	// 403 is impossible here
-	if resp1.Status == http.StatusNotFound || resp1.Body == nil {
+	// but the metadata store may be a local file, so other errors are still possible
+	if dErr, ok := err.(*DomainError); ok && dErr.Code == DomainErrorCodeNotFound {
+		err = nil
 		s.admin.WriteIdNameMapping(ctx, resp0.Body.AccessorID, ConseeAdmin)
 		s.admin.WriteTokenMetadata(ctx, resp0.Body.AccessorID, &TokenMetadata{
 			CreatedAt:     now,
@@ -77,82 +91,197 @@ validateIdNameMapping:
 	return
 }
 
-func (s *a2) Export(ctx context.Context, req *ExportRequest) (data []byte, err error) {
+func (s *a2) Export(ctx context.Context, req *ExportRequest, w io.Writer) error {
+	if req.Passphrase != "" {
+		ew, err := encrypt.NewPassphraseWriter(w, req.Passphrase)
+		if err != nil {
+			slog.Error("failed to create encrypted writer for export", "error", err)
+			return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to encrypt export"}
+		}
+		plain := *req
+		plain.Passphrase = ""
+		if err = s.Export(ctx, &plain, ew); err != nil {
+			return err
+		}
+		return ew.Close()
+	}
 	switch req.Format {
 	case "json":
-		return s.exportJSON(ctx, req)
+		return s.exportJSON(ctx, req, w)
 	case "zip":
-		return s.exportZip(ctx, req)
+		return s.exportZip(ctx, req, w)
 	}
-	return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "unsupported format"}
+	if isNativeFormat(req.Format) {
+		return s.exportNative(ctx, req, w)
+	}
+	return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "unsupported format"}
 }
 
-func (s *a2) exportJSON(ctx context.Context, req *ExportRequest) (data []byte, err error) {
+// exportKeys resolves keys to export from the request.
+// Keys are sorted so that folders come before keys inside them.
+func (s *a2) exportKeys(ctx context.Context, req *ExportRequest) ([]string, error) {
+	keys := make([]string, 0, len(req.Keys))
+	keys = append(keys, req.Keys...)
+	if req.All || len(req.Prefixes) > 0 {
+		allKeys, err := s.kv.ListKeys(ctx)
+		if err != nil {
+			slog.Error("failed to list keys during export", "error", err)
+			return nil, err
+		}
+		for _, key := range allKeys {
+			if req.All || slices.ContainsFunc(req.Prefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) }) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	// secrets are left out rather than masked, so that masks could not be imported over the values
+	var secrets []string
+	if !req.RevealSecrets {
+		secrets = listSecrets(ctx, s.admin)
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return key == "" || strings.HasPrefix(key, ConseeInternalKeyPrefix) || isSecret(secrets, key)
+	})
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+// getExportedKV reads a key to export. Encrypted values are exported as they are.
+// A folder given in the request may exist only as the prefix of other keys,
+// and it's skipped (nil is returned) if there is no placeholder key.
+func (s *a2) getExportedKV(ctx context.Context, key string) (*GetValueResponse, error) {
+	kv, err := s.kv.GetStored(ctx, key)
+	if err != nil && strings.HasSuffix(key, "/") {
+		if dErr, ok := err.(*DomainError); ok && dErr.Code == DomainErrorCodeNotFound {
+			return nil, nil
+		}
+	}
+	return kv, err
+}
+
+func (s *a2) exportJSON(ctx context.Context, req *ExportRequest, w io.Writer) error {
+	keys, err := s.exportKeys(ctx, req)
+	if err != nil {
+		return err
+	}
+	// every entry is written once it's ready
 	var buf buffer.Buffer
 	buf.WriteByte('[')
-	for i, key := range req.Keys {
-		resp, err := s.kv.Get(ctx, key)
+	n := 0
+	for _, key := range keys {
+		resp, err := s.getExportedKV(ctx, key)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if resp == nil {
+			continue
 		}
-		if i > 0 {
+		n++
+		if n > 1 {
 			buf.WriteByte(',')
 		}
 		buf.WriteString(`{"key": "`).
-			WriteJsonSafeString(resp.Key).WriteString(`","flags": 0,"value": "`).
-			WriteString(base64.RawStdEncoding.EncodeToString([]byte(resp.Value))).
+			WriteJsonSafeString(resp.Key).WriteString(`","flags": `).WriteUint64(resp.Flags).WriteString(`,"value": "`).
+			WriteString(base64.StdEncoding.EncodeToString([]byte(resp.Value))).
 			WriteString(`"}`)
+		if _, err = w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
 	}
 	buf.WriteByte(']')
-	return buf.Bytes(), nil
-}
-
-func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, err error) {
-	// 如果没有指定keys，则导出所有key
-	keys := req.Keys
-	// if len(keys) == 0 {
-	// 	allKeys, err := s.kv.ListKeys(ctx)
-	// 	if err != nil {
-	// 		slog.Error("failed to list all keys during export", "error", err)
-	// 		return nil, err
-	// 	}
-	// 	keys = allKeys
-	// }
-
-	// 创建zip文件
-	var buf buffer.Buffer
-	zipWriter := zip.NewWriter(&buf)
-	defer func() {
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// exportNative exports keys under the root in a native format.
+// Tree formats have to be built in memory, but values are small enough.
+func (s *a2) exportNative(ctx context.Context, req *ExportRequest, w io.Writer) error {
+	root := normalizeRoot(req.Root)
+	selected := *req
+	if root != "" && len(req.Keys) == 0 && len(req.Prefixes) == 0 && !req.All {
+		selected.Prefixes = []string{root}
+	}
+	keys, err := s.exportKeys(ctx, &selected)
+	if err != nil {
+		return err
+	}
+	kvs := make([]*GetValueResponse, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, root) {
+			return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "key " + key + " is not under root " + root}
+		}
+		kv, err := s.getExportedKV(ctx, key)
+		if err != nil {
+			slog.Error("failed to get key during export", "key", key, "error", err)
+			return err
+		}
+		if kv != nil {
+			kvs = append(kvs, kv)
+		}
+	}
+	if isTreeFormat(req.Format) {
+		tree, err := buildKVTree(kvs, root, req.Typed)
 		if err != nil {
-			zipWriter.Close()
+			return err
 		}
-	}()
+		return encodeKVTree(w, req.Format, tree)
+	}
+	sep := req.Separator
+	if sep == "" {
+		sep = defaultSeparator(req.Format)
+	}
+	return encodeFlat(w, req.Format, kvs, root, sep)
+}
+
+// exportZip streams the zip archive into out.
+// On errors the archive is left unfinished without the central directory,
+// so that a truncated export is never a valid zip.
+func (s *a2) exportZip(ctx context.Context, req *ExportRequest, out io.Writer) error {
+	keys, err := s.exportKeys(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// 创建zip文件，直接写入out
+	zipWriter := zip.NewWriter(out)
 
 	kvMeta := make([]ExportedKVMeta, 0, len(keys))
+	indexes := make(map[string]uint64)
 	// 导出每个key的值
 	for _, key := range keys {
-		kv, err := s.kv.Get(ctx, key)
+		kv, err := s.getExportedKV(ctx, key)
 		// err := s.writeKVToZip(ctx, zipWriter, key)
 		if err != nil {
 			slog.Error("failed to get key during export", "key", key, "error", err)
-			return nil, err
+			return err
+		}
+		if kv == nil {
+			continue
 		}
 		b64key := base64.StdEncoding.EncodeToString([]byte(key))
 		f, err := zipWriter.Create("kv/" + b64key + "/latest")
 		if err != nil {
 			slog.Error("failed to create zip entry for key", "key", key, "b64key", b64key, "error", err)
-			return nil, err
+			return err
+		}
+		if _, err = f.Write([]byte(kv.Value)); err != nil {
+			slog.Error("failed to write key to zip", "key", key, "b64key", b64key, "error", err)
+			return err
 		}
-		f.Write([]byte(kv.Value))
 
-		vtkv, err := s.admin.GetValueType(ctx, b64key)
+		// folders have no value type
+		vtkv := ""
+		if !strings.HasSuffix(key, "/") {
+			vtkv, err = s.admin.GetValueType(ctx, b64key)
+		}
 		if err != nil {
 			dErr := err.(*DomainError)
 			if dErr.Code == DomainErrorCodeNotFound {
 				vtkv = "plaintext"
 			} else {
 				slog.Error("failed to get value type during export", "key", key, "b64key", b64key, "error", err)
-				return nil, err
+				return err
 			}
 		}
 
@@ -167,26 +296,32 @@ func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, er
 			htvalue, err := s.admin.GetKVHistoryValue(ctx, b64key, ht)
 			if err != nil {
 				slog.Error("failed to get kv history value during export", "key", key, "b64key", b64key, "history", ht, "error", err)
-				return nil, err
+				return err
 			}
 			f, err := zipWriter.Create("kv/" + b64key + "/" + ht)
 			if err != nil {
 				slog.Error("failed to create zip entry for history", "key", key, "b64key", b64key, "history", ht, "error", err)
-				return nil, err
+				return err
+			}
+			if _, err = f.Write([]byte(htvalue)); err != nil {
+				slog.Error("failed to write history to zip", "key", key, "b64key", b64key, "history", ht, "error", err)
+				return err
 			}
-			f.Write([]byte(htvalue))
 		}
 		kvMeta = append(kvMeta, ExportedKVMeta{
 			Name:            key,
 			ValueType:       vtkv,
 			HistoryVersions: historyKeys,
+			Flags:           &kv.Flags,
 		})
+		indexes[ImportItemKey("kv", key)] = kv.ModifyIndex
 	}
 
 	e := ExportMetadata{
-		Keys:     kvMeta,
-		Tokens:   []ACLLink{},
-		Policies: []string{},
+		Keys:          kvMeta,
+		Tokens:        []ACLLink{},
+		Policies:      []string{},
+		ModifyIndexes: indexes,
 	}
 
 	if req.ACL {
@@ -195,7 +330,7 @@ func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, er
 			token, err := s.acl.ReadToken(ctx, t.ID)
 			if err != nil {
 				slog.Error("failed to read token during export", "tokenId", t.ID, "tokenName", t.Name, "error", err)
-				return nil, err
+				return err
 			}
 			policyMode := "common"
 			rules := ""
@@ -205,7 +340,7 @@ func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, er
 				policyMode = "exclusive"
 				policy, err := s.acl.ReadPolicy(ctx, token.Policies[0].Name)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				rules = policy.Rules
 			} else {
@@ -217,8 +352,9 @@ func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, er
 			f, err := zipWriter.Create("tokens/" + t.ID)
 			if err != nil {
 				slog.Error("failed to create zip entry for token", "tokenId", t.ID, "tokenName", t.Name, "error", err)
-				return nil, err
+				return err
 			}
+			indexes[ImportItemKey("token", iritp(t.ID, t.Name))] = token.ModifyIndex
 			b, _ := json.Marshal(CreateTokenRequest{
 				AccessorID: token.AccessorID,
 				SecretID:   token.SecretID,
@@ -227,7 +363,10 @@ func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, er
 				Policies:   policies,
 				Rules:      rules,
 			})
-			f.Write(b)
+			if _, err = f.Write(b); err != nil {
+				slog.Error("failed to write token to zip", "tokenId", t.ID, "tokenName", t.Name, "error", err)
+				return err
+			}
 		}
 		e.Tokens = tokens
 
@@ -240,121 +379,448 @@ func (s *a2) exportZip(ctx context.Context, req *ExportRequest) (data []byte, er
 			policy, err := s.acl.ReadPolicy(ctx, p.Name)
 			if err != nil {
 				slog.Error("failed to read policy during export", "policyId", p.ID, "policyName", p.Name, "error", err)
-				return nil, err
+				return err
 			}
 			b64PolicyName := base64.StdEncoding.EncodeToString([]byte(p.Name))
 			f, err := zipWriter.Create("policies/" + b64PolicyName)
 			if err != nil {
 				slog.Error("failed to create zip entry for policy", "policyId", p.ID, "policyName", p.Name, "b64PolicyName", b64PolicyName, "error", err)
-				return nil, err
+				return err
 			}
+			indexes[ImportItemKey("policy", p.Name)] = policy.ModifyIndex
 			b, _ := json.Marshal(CreatePolicyRequest{
 				Name:        policy.Name,
 				Description: policy.Description,
 				Rules:       policy.Rules,
 			})
-			f.Write(b)
+			if _, err = f.Write(b); err != nil {
+				slog.Error("failed to write policy to zip", "policyId", p.ID, "policyName", p.Name, "error", err)
+				return err
+			}
 			policyNames = append(policyNames, p.Name)
 		}
 		e.Policies = policyNames
 
 	}
 
+	if req.Intentions {
+		intentions, err := s.intention.ListIntentions(ctx)
+		if err != nil {
+			slog.Error("failed to list intentions during export", "error", err)
+			return err
+		}
+		e.Intentions = intentions
+	}
+
+	if req.ConfigEntries {
+		for _, kind := range ConfigEntryKinds {
+			if kind == consul.ServiceIntentions && req.Intentions {
+				// intentions are stored as service-intentions config entries,
+				// and they have been exported above
+				continue
+			}
+			entries, err := s.configEntry.ListConfigEntries(ctx, kind)
+			if err != nil {
+				slog.Error("failed to list config entries during export", "kind", kind, "error", err)
+				return err
+			}
+			for _, entry := range entries {
+				name := consul.ConfigEntry(entry).Name()
+				f, err := zipWriter.Create("config-entries/" + kind + "/" + base64.StdEncoding.EncodeToString([]byte(name)))
+				if err != nil {
+					slog.Error("failed to create zip entry for config entry", "kind", kind, "name", name, "error", err)
+					return err
+				}
+				if _, err = f.Write([]byte(renderConfigEntry(entry))); err != nil {
+					slog.Error("failed to write config entry to zip", "kind", kind, "name", name, "error", err)
+					return err
+				}
+				e.ConfigEntries = append(e.ConfigEntries, ConfigEntryLink{Kind: kind, Name: name})
+			}
+		}
+	}
+
 	b, _ := json.Marshal(e)
 	w, err := zipWriter.Create("metadata.json")
 	if err != nil {
 		slog.Error("failed to create zip entry for metadata", "error", err)
-		return nil, err
+		return err
+	}
+	if _, err = w.Write(b); err != nil {
+		slog.Error("failed to write metadata to zip", "error", err)
+		return err
 	}
-	w.Write(b)
-	zipWriter.Flush()
-	zipWriter.Close()
-	return buf.Bytes(), nil
+	return zipWriter.Close()
 }
 
 func (s *a2) Import(ctx context.Context, req *ImportRequest) (*ImportResponse, error) {
+	if encrypt.IsEncrypted(req.File) {
+		plain, f, err := decryptImport(req)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		req = plain
+	}
 	slog.Info("a2 import", "dryrun", req.Dryrun, "format", req.Format)
 	switch req.Format {
 	case "zip":
 		return s.importZip(ctx, req)
 	case "json":
+		// a json object is a tree, while consul exports a list
+		if firstByte(req.File) == '{' {
+			tree := *req
+			tree.Format = FormatJSONTree
+			return s.importNative(ctx, &tree)
+		}
 		return s.importJson(ctx, req)
 	}
+	if isNativeFormat(req.Format) {
+		return s.importNative(ctx, req)
+	}
 	return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid file format"}
 }
 
+// readArchiveKVs reads the latest values of keys in the archive of req, decrypting it if needed.
+// Folders are not included. flags is nil if the format has no flags, and keys of zip archives exported without flags have none.
+func readArchiveKVs(req *ImportRequest) (values map[string]string, flags map[string]uint64, err error) {
+	if encrypt.IsEncrypted(req.File) {
+		plain, f, err := decryptImport(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		req = plain
+	}
+	values = map[string]string{}
+	var kvs CompatibleKVMetaList
+	switch {
+	case req.Format == "zip":
+		r, err := zip.NewReader(req.File, req.Size)
+		if err != nil {
+			return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid zip file"}
+		}
+		f, err := r.Open("metadata.json")
+		if err != nil {
+			return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid file format: metadata.json not found"}
+		}
+		var meta ExportMetadata
+		err = json.NewDecoder(f).Decode(&meta)
+		f.Close()
+		if err != nil {
+			return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid file format: metadata.json is invalid"}
+		}
+		for _, kv := range meta.Keys {
+			if strings.HasSuffix(kv.Name, "/") {
+				continue
+			}
+			value, _, err := readZipValue(r, base64.StdEncoding.EncodeToString([]byte(kv.Name)), kv.Name, nil)
+			if err != nil {
+				return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "failed to read " + kv.Name + ": " + err.Error()}
+			}
+			values[kv.Name] = value
+		}
+		return values, zipFlags(meta.Keys), nil
+	case req.Format == "json" && firstByte(req.File) != '{':
+		if err := json.NewDecoder(io.NewSectionReader(req.File, 0, req.Size)).Decode(&kvs); err != nil {
+			return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid json file"}
+		}
+		flags = make(map[string]uint64, len(kvs))
+	case req.Format == "json" || isNativeFormat(req.Format):
+		tree := *req
+		if tree.Format == "json" {
+			tree.Format = FormatJSONTree
+		}
+		if kvs, err = decodeNative(&tree); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid file format"}
+	}
+	for _, kv := range kvs {
+		if strings.HasSuffix(kv.Key, "/") {
+			continue
+		}
+		values[kv.Key] = string(kv.Value)
+		if flags != nil {
+			flags[kv.Key] = kv.Flags
+		}
+	}
+	return values, flags, nil
+}
+
+// decryptImport decrypts the archive of req into a temporary file,
+// and detects the format of the plain archive unless it's a native format.
+// The caller should close and remove the returned file.
+func decryptImport(req *ImportRequest) (*ImportRequest, *os.File, error) {
+	keys := encrypt.Keys{Passphrase: req.Passphrase}
+	mode, err := encrypt.Mode(req.File)
+	if err != nil {
+		return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: err.Error()}
+	}
+	if req.Passphrase == "" {
+		return nil, nil, &DomainError{Code: DomainErrorCodePassphraseRequired, Message: "the archive is encrypted, passphrase is required"}
+	}
+	if mode == encrypt.ModeRecipient {
+		if keys.Identity, err = encrypt.ParsePrivateKey(req.Passphrase); err != nil {
+			return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "the archive is encrypted to a public key, a valid private key is required"}
+		}
+	}
+	r, err := encrypt.NewReader(io.NewSectionReader(req.File, 0, req.Size), keys)
+	if err != nil {
+		return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: err.Error()}
+	}
+
+	f, err := os.CreateTemp("", "consee-import-*")
+	if err != nil {
+		slog.Error("failed to create temporary file for decryption", "error", err)
+		return nil, nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to decrypt archive"}
+	}
+	size, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		if err == encrypt.ErrDecrypt {
+			return nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: err.Error()}
+		}
+		slog.Error("failed to decrypt archive", "error", err)
+		return nil, nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to decrypt archive"}
+	}
+
+	plain := *req
+	plain.File, plain.Size = f, size
+	if isNativeFormat(req.Format) {
+		return &plain, f, nil
+	}
+	plain.Format = "json"
+	magic := make([]byte, 4)
+	if _, err := f.ReadAt(magic, 0); err == nil && binary.LittleEndian.Uint32(magic) == 0x04034b50 {
+		plain.Format = "zip"
+	}
+	return &plain, f, nil
+}
+
+// importProgress reports progress of an import item by item.
+type importProgress struct {
+	report  func(ImportProgress)
+	current int
+	total   int
+}
+
+func (p *importProgress) step(kind, param string) {
+	p.current++
+	if p.report != nil {
+		p.report(ImportProgress{Kind: kind, Param: param, Current: p.current, Total: p.total})
+	}
+}
+
+// firstByte returns the first non-space byte of r, or 0 if there is none in the beginning.
+func firstByte(r io.ReaderAt) byte {
+	b := make([]byte, 512)
+	n, _ := r.ReadAt(b, 0)
+	for _, c := range b[:n] {
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c
+		}
+	}
+	return 0
+}
+
+// importNative imports a document in a native format into the root folder.
+// Keys are imported as plaintext values.
+func (s *a2) importNative(ctx context.Context, req *ImportRequest) (*ImportResponse, error) {
+	c, err := newConflictResolver(req)
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := decodeNative(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.importKVs(ctx, req, kvs, c)
+}
+
+// decodeNative decodes keys of a document in a native format under the root folder.
+func decodeNative(req *ImportRequest) (CompatibleKVMetaList, error) {
+	root := normalizeRoot(req.Root)
+	r := io.NewSectionReader(req.File, 0, req.Size)
+	if isTreeFormat(req.Format) {
+		tree, err := decodeKVTree(r, req.Format)
+		if err != nil {
+			return nil, err
+		}
+		return flattenKVTree(tree, root, nil), nil
+	}
+	sep := req.Separator
+	if sep == "" {
+		sep = defaultSeparator(req.Format)
+	}
+	return decodeFlat(r, req.Format, root, sep)
+}
+
 func (s *a2) importJson(ctx context.Context, req *ImportRequest) (*ImportResponse, error) {
+	c, err := newConflictResolver(req)
+	if err != nil {
+		return nil, err
+	}
 	var kvs CompatibleKVMetaList
-	err := json.Unmarshal(req.FileContent, &kvs)
+	err = json.NewDecoder(io.NewSectionReader(req.File, 0, req.Size)).Decode(&kvs)
 	if err != nil {
 		slog.Error("failed to unmarshal json during import", "error", err)
 		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid json file"}
 	}
-	resp := s.ImportDryrun(ctx, kvs.DryrunMetadata())
+	return s.importKVs(ctx, req, kvs, c)
+}
+
+// importKVs renders kvs if templates are enabled, and imports them.
+// The dryrun response of native formats lists the resulting keys.
+// Internal keys, e.g. of a document imported with an empty root, are reported as errors instead.
+func (s *a2) importKVs(ctx context.Context, req *ImportRequest, kvs CompatibleKVMetaList, c *conflictResolver) (*ImportResponse, error) {
+	var internalErrs []ImportResponseItem
+	kvs = slices.DeleteFunc(kvs, func(kv *CompatibleKVMeta) bool {
+		if !strings.HasPrefix(kv.Key, ConseeInternalKeyPrefix) {
+			return false
+		}
+		internalErrs = append(internalErrs, ImportResponseItem{Kind: "kv", Param: kv.Key, Cause: "internal keys could not be imported"})
+		return true
+	})
+	var templateErrs []ImportResponseItem
+	if req.Template {
+		var err error
+		if kvs, templateErrs, err = s.renderKVs(ctx, req, kvs); err != nil {
+			return nil, err
+		}
+	}
+	var resp *ImportResponse
 	if req.Dryrun {
-		return resp, nil
+		meta := kvs.DryrunMetadata()
+		if req.Format == "json" {
+			meta.Flags = make(map[string]uint64, len(kvs))
+			for _, kv := range kvs {
+				meta.Flags[kv.Key] = kv.Flags
+			}
+		}
+		resp = s.ImportDryrun(ctx, meta, c)
+		for _, kv := range kvs {
+			s.dryrunValue(ctx, resp, kv.Key, string(kv.Value), "plaintext")
+		}
+	} else {
+		progress := &importProgress{report: req.Progress, total: len(kvs)}
+		// only the consul json format has flags, native formats keep flags of existing keys
+		resp = s.doImportJson(ctx, kvs, c, progress, req.Format == "json")
 	}
-
-	return s.doImportJson(ctx, kvs, req.OnConflict), nil
+	resp.Errors = append(resp.Errors, internalErrs...)
+	resp.Errors = append(resp.Errors, templateErrs...)
+	return resp, nil
 }
 
-func (s *a2) doImportJson(ctx context.Context, kvs CompatibleKVMetaList, onConflict OnConflictPolicy) *ImportResponse {
+func (s *a2) doImportJson(ctx context.Context, kvs CompatibleKVMetaList, c *conflictResolver, progress *importProgress, withFlags bool) *ImportResponse {
 	resp := &ImportResponse{
 		Successes: []ImportResponseItem{},
 		Conflicts: []ImportResponseItem{},
+		Errors:    []ImportResponseItem{},
 	}
 	for _, kv := range kvs {
-		key, value := kv.Key, string(kv.Value)
-
-		// 创建或更新KV
-		existingKV, _ := s.kv.Get(ctx, key)
-		if existingKV == nil {
-			// 如果key不存在，创建新的
-			err := s.kv.Create(ctx, &CreateKeyValueRequest{
-				Key:       key,
-				Value:     value,
-				ValueType: "plaintext",
-			})
-			if err != nil {
-				resp.Errors = append(resp.Errors, ImportResponseItem{
-					Kind:  "kv",
-					Param: key,
-					Cause: err.Error(),
-				})
-			}
-			resp.Successes = append(resp.Successes, ImportResponseItem{
-				Kind:  "kv",
-				Param: key,
-			})
-			continue
+		progress.step("kv", kv.Key)
+		var flags *uint64
+		if withFlags {
+			flags = &kv.Flags
 		}
-
-		if existingKV.Value != value {
-			// 值一样时直接跳过更新，否则记录冲突
-			if onConflict == OnConflictPolicyReplace {
-				// 如果key存在，更新值
-				err := s.kv.Update(ctx, key, value)
-				if err != nil {
-					resp.Errors = append(resp.Errors, ImportResponseItem{
-						Kind:  "kv",
-						Param: key,
-						Cause: err.Error(),
-					})
-				}
-				b64key := base64.StdEncoding.EncodeToString([]byte(key))
-				s.admin.WriteValueType(ctx, b64key, "plaintext")
-			}
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "kv", Param: key})
+		valueType := "plaintext"
+		if !utf8.Valid(kv.Value) {
+			valueType = ValueTypeBinary
 		}
+		s.importKV(ctx, resp, c, kv.Key, string(kv.Value), valueType, flags)
 	}
 	return resp
 }
 
+// importKV creates the key, or resolves the conflict with the existing one.
+// Flags of an existing key are kept if flags is nil, e.g. formats without flags.
+// It returns the key the value is written to, or "" if nothing is written.
+func (s *a2) importKV(ctx context.Context, resp *ImportResponse, c *conflictResolver, key, value, valueType string, flags *uint64) string {
+	existingKV, _ := s.kv.GetStored(ctx, key)
+	if existingKV == nil {
+		// 如果key不存在，创建新的
+		req := &CreateKeyValueRequest{
+			Key:       key,
+			Value:     value,
+			ValueType: valueType,
+		}
+		if flags != nil {
+			req.Flags = *flags
+		}
+		err := s.kv.Create(ctx, req)
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "kv", Param: key, Cause: err.Error()})
+			return ""
+		}
+		resp.Successes = append(resp.Successes, ImportResponseItem{Kind: "kv", Param: key})
+		return key
+	}
+	// 值和flags一样时直接跳过更新，否则按策略处理冲突
+	if existingKV.Value == value && (flags == nil || existingKV.Flags == *flags) {
+		return ""
+	}
+	item := c.resolve("kv", key, existingKV.ModifyIndex)
+	switch item.Resolution {
+	case OnConflictPolicyReplace:
+		if err := s.kv.Update(ctx, key, &UpdateValueRequest{Value: value, Flags: flags}); err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "kv", Param: key, Cause: err.Error()})
+			return ""
+		}
+		s.admin.WriteValueType(ctx, base64.StdEncoding.EncodeToString([]byte(key)), valueType)
+	case OnConflictPolicyRename:
+		item.RenamedTo = c.renamedKey(key)
+		// the renamed key takes the flags of the existing one if flags is nil
+		req := &CreateKeyValueRequest{
+			Key:       item.RenamedTo,
+			Value:     value,
+			ValueType: valueType,
+			Flags:     existingKV.Flags,
+		}
+		if flags != nil {
+			req.Flags = *flags
+		}
+		err := s.kv.Create(ctx, req)
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "kv", Param: key, Cause: "failed to import as " + item.RenamedTo + ": " + err.Error()})
+			return ""
+		}
+		key = item.RenamedTo
+	default:
+		key = ""
+	}
+	resp.Conflicts = append(resp.Conflicts, item)
+	return key
+}
+
+// dryrunValue reports the value if it would be rejected by its value type or schema.
+// Existing keys are updated with their own value types, see importKV.
+func (s *a2) dryrunValue(ctx context.Context, resp *ImportResponse, key, value, valueType string) {
+	if strings.HasSuffix(key, "/") {
+		return
+	}
+	if existingKV, _ := s.kv.GetStored(ctx, key); existingKV != nil {
+		valueType = ""
+	}
+	if err := s.kv.CheckValue(ctx, key, value, valueType); err != nil {
+		resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "kv", Param: key, Cause: err.Error()})
+	}
+}
+
 func (s *a2) importZip(ctx context.Context, req *ImportRequest) (*ImportResponse, error) {
-	if binary.LittleEndian.Uint32(req.FileContent[:4]) != 0x04034b50 {
+	c, err := newConflictResolver(req)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 4)
+	if _, err := req.File.ReadAt(magic, 0); err != nil || binary.LittleEndian.Uint32(magic) != 0x04034b50 {
 		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid zip file"}
 	}
-	r, err := zip.NewReader(bytes.NewReader(req.FileContent), int64(len(req.FileContent)))
+	r, err := zip.NewReader(req.File, req.Size)
 	if err != nil {
 		slog.Error("failed to create zip reader for import", "error", err)
 		return nil, errUnknown
@@ -372,19 +838,123 @@ func (s *a2) importZip(ctx context.Context, req *ImportRequest) (*ImportResponse
 		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid file format: metadata.json is invalid"}
 	}
 	f.Close()
+	c.indexes = exportmeta.ModifyIndexes
 	slog.Info("parse metadata file successfully")
 	slog.Debug("metadata", "keys", exportmeta.Keys, "tokens", exportmeta.Tokens, "policies", exportmeta.Policies)
 
-	resp := s.ImportDryrun(ctx, exportmeta.DryrunMetadata())
+	// values are rendered beforehand, since they may reference each other
+	var values map[string]string
+	var templateErrs []ImportResponseItem
+	if req.Template {
+		if values, templateErrs, err = s.renderZipValues(ctx, req, r, &exportmeta); err != nil {
+			return nil, err
+		}
+	}
+
 	if req.Dryrun {
+		dryrunMeta := exportmeta.DryrunMetadata()
+		dryrunMeta.Values = make(map[string]string, len(exportmeta.Keys))
+		dryrunMeta.Flags = zipFlags(exportmeta.Keys)
+		for _, kv := range exportmeta.Keys {
+			if value, ok, err := readZipValue(r, base64.StdEncoding.EncodeToString([]byte(kv.Name)), kv.Name, values); err == nil && ok {
+				dryrunMeta.Values[kv.Name] = value
+			}
+		}
+		dryrunMeta.PolicyRules = readZipPolicyRules(r, exportmeta.Policies)
+		dryrunMeta.ConfigEntryContents = readZipConfigEntries(r, exportmeta.ConfigEntries)
+		resp := s.ImportDryrun(ctx, dryrunMeta, c)
+		for _, kv := range exportmeta.Keys {
+			if value, ok := dryrunMeta.Values[kv.Name]; ok {
+				s.dryrunValue(ctx, resp, kv.Name, value, kv.ValueType)
+			}
+		}
+		resp.Errors = append(resp.Errors, templateErrs...)
 		return resp, nil
 	}
 
 	// 实际导入数据
-	return s.doImportZip(ctx, r, &exportmeta, req.OnConflict), nil
+	progress := &importProgress{
+		report: req.Progress,
+		total: len(exportmeta.Keys) + len(exportmeta.Policies) + len(exportmeta.Tokens) +
+			len(exportmeta.Intentions) + len(exportmeta.ConfigEntries),
+	}
+	resp := s.doImportZip(ctx, r, &exportmeta, values, c, progress)
+	resp.Errors = append(resp.Errors, templateErrs...)
+	return resp, nil
+}
+
+// zipFlags returns flags of keys exported with them.
+func zipFlags(keys []ExportedKVMeta) map[string]uint64 {
+	flags := make(map[string]uint64, len(keys))
+	for _, kv := range keys {
+		if kv.Flags != nil {
+			flags[kv.Name] = *kv.Flags
+		}
+	}
+	return flags
+}
+
+// readZipValue returns the latest value of key, from values if they are rendered.
+// ok is false if the key is not in rendered values.
+func readZipValue(r *zip.Reader, b64key, key string, values map[string]string) (value string, ok bool, err error) {
+	if values != nil {
+		value, ok = values[key]
+		return value, ok, nil
+	}
+	// 读取最新的值
+	f, err := r.Open("kv/" + b64key + "/latest")
+	if err != nil {
+		return "", false, err
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
+
+// readZipPolicyRules reads rules of the archived policies, keyed by name.
+// Policies which could not be read are left out.
+func readZipPolicyRules(r *zip.Reader, names []string) map[string]string {
+	rules := make(map[string]string, len(names))
+	for _, name := range names {
+		f, err := r.Open("policies/" + base64.StdEncoding.EncodeToString([]byte(name)))
+		if err != nil {
+			continue
+		}
+		var policy CreatePolicyRequest
+		err = json.NewDecoder(f).Decode(&policy)
+		f.Close()
+		if err == nil {
+			rules[name] = policy.Rules
+		}
+	}
+	return rules
+}
+
+// readZipConfigEntries reads the archived config entries rendered by renderConfigEntry, keyed by "kind/name".
+// Entries which could not be read are left out.
+func readZipConfigEntries(r *zip.Reader, links []ConfigEntryLink) map[string]string {
+	entries := make(map[string]string, len(links))
+	for _, link := range links {
+		f, err := r.Open("config-entries/" + link.Kind + "/" + base64.StdEncoding.EncodeToString([]byte(link.Name)))
+		if err != nil {
+			continue
+		}
+		var entry ConfigEntry
+		err = json.NewDecoder(f).Decode(&entry)
+		f.Close()
+		if err == nil {
+			entries[link.Kind+"/"+link.Name] = renderConfigEntry(entry)
+		}
+	}
+	return entries
 }
 
-func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadata, onConflict OnConflictPolicy) *ImportResponse {
+// doImportZip imports the archive. values are rendered values of keys,
+// and keys not in values are skipped; nil means values are read from the archive.
+func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadata, values map[string]string, c *conflictResolver, progress *importProgress) *ImportResponse {
 	resp := &ImportResponse{
 		Successes: []ImportResponseItem{},
 		Conflicts: []ImportResponseItem{},
@@ -392,9 +962,9 @@ func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadat
 	}
 	// 导入KV数据
 	for _, kv := range meta.Keys {
+		progress.step("kv", kv.Name)
 		b64key := base64.StdEncoding.EncodeToString([]byte(kv.Name))
-		// 读取最新的值
-		f, err := r.Open("kv/" + b64key + "/latest")
+		value, ok, err := readZipValue(r, b64key, kv.Name, values)
 		if err != nil {
 			resp.Errors = append(resp.Errors, ImportResponseItem{
 				Kind:  "kv",
@@ -403,57 +973,17 @@ func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadat
 			})
 			continue
 		}
-
-		valueBytes, err := io.ReadAll(f)
-		f.Close()
-		if err != nil {
-			resp.Errors = append(resp.Errors, ImportResponseItem{
-				Kind:  "kv",
-				Param: kv.Name,
-				Cause: err.Error(),
-			})
+		if !ok {
+			// 模板渲染失败，已在渲染时记录错误
 			continue
 		}
 
-		// 创建或更新KV
-		existingKV, _ := s.kv.Get(ctx, kv.Name)
-		if existingKV == nil {
-			// 如果key不存在，创建新的
-			err = s.kv.Create(ctx, &CreateKeyValueRequest{
-				Key:       kv.Name,
-				Value:     string(valueBytes),
-				ValueType: kv.ValueType,
-			})
-			if err != nil {
-				resp.Errors = append(resp.Errors, ImportResponseItem{
-					Kind:  "kv",
-					Param: kv.Name,
-					Cause: err.Error(),
-				})
-			}
-			resp.Successes = append(resp.Successes, ImportResponseItem{
-				Kind:  "kv",
-				Param: kv.Name,
-			})
-			goto importHistory
-		}
-		// 值一样时直接跳过更新，否则记录冲突
-		if existingKV.Value != string(valueBytes) {
-			if onConflict == OnConflictPolicyReplace {
-				// 如果key存在，更新值
-				err = s.kv.Update(ctx, kv.Name, string(valueBytes))
-				if err != nil {
-					resp.Errors = append(resp.Errors, ImportResponseItem{
-						Kind:  "kv",
-						Param: kv.Name,
-						Cause: err.Error(),
-					})
-				}
-				s.admin.WriteValueType(ctx, b64key, kv.ValueType)
-			}
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "kv", Param: kv.Name})
+		// 创建或更新KV，历史版本导入到实际写入的key；未写入（跳过）时不导入历史版本
+		target := s.importKV(ctx, resp, c, kv.Name, value, kv.ValueType, kv.Flags)
+		if target == "" {
+			continue
 		}
-	importHistory:
+		b64target := base64.StdEncoding.EncodeToString([]byte(target))
 		// 导入历史版本（如果有）
 		for _, history := range kv.HistoryVersions {
 			hf, err := r.Open("kv/" + b64key + "/" + history)
@@ -478,7 +1008,7 @@ func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadat
 
 			// 保存历史版本
 			s.admin.AddNewHistoryVersion(ctx,
-				b64key,
+				b64target,
 				history,
 				string(historyValue),
 			)
@@ -487,6 +1017,7 @@ func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadat
 
 	// 导入policies
 	for _, policyName := range meta.Policies {
+		progress.step("policy", policyName)
 		if policyName == PolicyNameGlobalManagement || policyName == PolicyNameBuiltinGlobalReadonly {
 			// 应该是不可能触发的，导出时已经排除了内置策略
 			continue // 跳过内置策略
@@ -529,22 +1060,37 @@ func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadat
 					Cause: err.Error(),
 				})
 			}
-		} else {
+			continue
+		}
+		// 规则一样时直接跳过，否则按策略处理冲突
+		if existingPolicy.Rules == policyReq.Rules {
+			continue
+		}
+		item := c.resolve("policy", policyName, existingPolicy.ModifyIndex)
+		switch item.Resolution {
+		case OnConflictPolicyReplace:
 			// 更新现有策略的规则
 			err = s.acl.UpdatePolicyRule(ctx, policyName, policyReq.Rules)
-			if err != nil {
-				resp.Errors = append(resp.Errors, ImportResponseItem{
-					Kind:  "policy",
-					Param: policyName,
-					Cause: err.Error(),
-				})
-			}
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "policy", Param: policyName})
+		case OnConflictPolicyRename:
+			item.RenamedTo = policyName + c.suffix
+			renamed := policyReq
+			renamed.Name = item.RenamedTo
+			err = s.acl.CreatePolicy(ctx, &renamed)
 		}
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{
+				Kind:  "policy",
+				Param: policyName,
+				Cause: err.Error(),
+			})
+			continue
+		}
+		resp.Conflicts = append(resp.Conflicts, item)
 	}
 
 	// 导入tokens
 	for _, token := range meta.Tokens {
+		progress.step("token", iritp(token.ID, token.Name))
 		f, err := r.Open("tokens/" + token.ID)
 		if err != nil {
 			resp.Errors = append(resp.Errors, ImportResponseItem{
@@ -581,26 +1127,120 @@ func (s *a2) doImportZip(ctx context.Context, r *zip.Reader, meta *ExportMetadat
 					Cause: err.Error(),
 				})
 			}
-		} else {
+			continue
+		}
+		item := c.resolve("token", iritp(token.ID, token.Name), existingToken.ModifyIndex)
+		switch item.Resolution {
+		case OnConflictPolicyReplace:
 			// 更新现有token
 			err = s.acl.UpdateToken(ctx, token.ID, &UpdateTokenRequest{
 				Policies: tokenReq.Policies,
 			})
-			if err != nil {
-				resp.Errors = append(resp.Errors, ImportResponseItem{
-					Kind:  "token",
-					Param: iritp(token.ID, token.Name),
-					Cause: err.Error(),
-				})
+		case OnConflictPolicyRename:
+			// 以新的ID创建token
+			item.RenamedTo = c.renamedTokenName(tokenReq.Name, token.ID)
+			renamed := tokenReq
+			renamed.AccessorID, renamed.SecretID, renamed.Name = "", "", item.RenamedTo
+			err = s.acl.CreateToken(ctx, &renamed)
+		}
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{
+				Kind:  "token",
+				Param: iritp(token.ID, token.Name),
+				Cause: err.Error(),
+			})
+			continue
+		}
+		resp.Conflicts = append(resp.Conflicts, item)
+	}
+
+	// 导入intentions
+	for i := range meta.Intentions {
+		in := &meta.Intentions[i]
+		name := IntentionName(in.Source, in.Destination)
+		progress.step("intention", name)
+		existing, err := s.intention.ReadIntention(ctx, in.Source, in.Destination)
+		if err != nil && err.(*DomainError).Code != DomainErrorCodeNotFound {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "intention", Param: name, Cause: err.Error()})
+			continue
+		}
+		if existing != nil {
+			if sameIntention(existing, in) {
+				continue
+			}
+			item := c.resolve("intention", name, 0)
+			resp.Conflicts = append(resp.Conflicts, item)
+			if item.Resolution != OnConflictPolicyReplace {
+				continue
 			}
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "token", Param: iritp(token.ID, token.Name)})
+		}
+		err = s.intention.UpsertIntention(ctx, in)
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "intention", Param: name, Cause: err.Error()})
+			continue
+		}
+		if existing == nil {
+			resp.Successes = append(resp.Successes, ImportResponseItem{Kind: "intention", Param: name})
+		}
+	}
+
+	// 导入config entries，导出时已按依赖顺序排列
+	for _, link := range meta.ConfigEntries {
+		param := link.Kind + "/" + link.Name
+		progress.step("config-entry", param)
+		f, err := r.Open("config-entries/" + link.Kind + "/" + base64.StdEncoding.EncodeToString([]byte(link.Name)))
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "config-entry", Param: param, Cause: "config entry not found"})
+			continue
+		}
+		var entry ConfigEntry
+		err = json.NewDecoder(f).Decode(&entry)
+		f.Close()
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "config-entry", Param: param, Cause: "invalid config entry"})
+			continue
+		}
+		existing, err := s.configEntry.ReadConfigEntry(ctx, link.Kind, link.Name)
+		if err != nil && err.(*DomainError).Code != DomainErrorCodeNotFound {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "config-entry", Param: param, Cause: err.Error()})
+			continue
+		}
+		if existing != nil {
+			if renderConfigEntry(existing) == renderConfigEntry(entry) {
+				continue
+			}
+			item := c.resolve("config-entry", param, 0)
+			resp.Conflicts = append(resp.Conflicts, item)
+			if item.Resolution != OnConflictPolicyReplace {
+				continue
+			}
+		}
+		err = s.configEntry.ApplyConfigEntry(ctx, entry)
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportResponseItem{Kind: "config-entry", Param: param, Cause: err.Error()})
+			continue
+		}
+		if existing == nil {
+			resp.Successes = append(resp.Successes, ImportResponseItem{Kind: "config-entry", Param: param})
 		}
 	}
 
 	return resp
 }
 
-func (s *a2) ImportDryrun(ctx context.Context, meta *DryrunMetadata) *ImportResponse {
+// sameIntention reports whether the two intentions have the same rules.
+// Precedence is computed by consul so it's not compared.
+func sameIntention(a, b *Intention) bool {
+	x, y := *a, *b
+	x.Precedence, y.Precedence = 0, 0
+	bx, _ := json.Marshal(x)
+	by, _ := json.Marshal(y)
+	return bytes.Equal(bx, by)
+}
+
+// ImportDryrun checks conflicts of the items to import.
+// Each conflict comes with the resolution that the real import would apply.
+func (s *a2) ImportDryrun(ctx context.Context, meta *DryrunMetadata, c *conflictResolver) *ImportResponse {
 	resp := &ImportResponse{
 		Successes: []ImportResponseItem{},
 		Conflicts: []ImportResponseItem{},
@@ -611,11 +1251,21 @@ func (s *a2) ImportDryrun(ctx context.Context, meta *DryrunMetadata) *ImportResp
 
 	// 检查KV数据冲突
 	for _, key := range meta.Keys {
-		existingKV, err := s.kv.Get(ctx, key)
+		existingKV, err := s.kv.GetStored(ctx, key)
 		slog.Debug("kv get response", "k", key, "v", existingKV)
 		if existingKV != nil {
+			// 值和flags一样时导入会跳过，不算冲突
+			if value, ok := meta.Values[key]; ok && existingKV.Value == value {
+				if flags, ok := meta.Flags[key]; !ok || existingKV.Flags == flags {
+					continue
+				}
+			}
 			// Key已存在，记录冲突
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "kv", Param: key})
+			item := c.resolve("kv", key, existingKV.ModifyIndex)
+			if item.Resolution == OnConflictPolicyRename {
+				item.RenamedTo = c.renamedKey(key)
+			}
+			resp.Conflicts = append(resp.Conflicts, item)
 			continue
 		}
 		dErr := err.(*DomainError)
@@ -640,8 +1290,16 @@ func (s *a2) ImportDryrun(ctx context.Context, meta *DryrunMetadata) *ImportResp
 
 		existingPolicy, err := s.acl.ReadPolicy(ctx, policyName)
 		if err == nil && existingPolicy != nil {
+			// 规则一样时导入会跳过，不算冲突
+			if rules, ok := meta.PolicyRules[policyName]; ok && existingPolicy.Rules == rules {
+				continue
+			}
 			// Policy已存在，记录冲突
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "policy", Param: policyName})
+			item := c.resolve("policy", policyName, existingPolicy.ModifyIndex)
+			if item.Resolution == OnConflictPolicyRename {
+				item.RenamedTo = policyName + c.suffix
+			}
+			resp.Conflicts = append(resp.Conflicts, item)
 			continue
 		}
 		dErr := err.(*DomainError)
@@ -663,7 +1321,11 @@ func (s *a2) ImportDryrun(ctx context.Context, meta *DryrunMetadata) *ImportResp
 
 		if err == nil && existingToken != nil {
 			// Token已存在，记录冲突
-			resp.Conflicts = append(resp.Conflicts, ImportResponseItem{Kind: "token", Param: iritp(token.ID, token.Name)})
+			item := c.resolve("token", iritp(token.ID, token.Name), existingToken.ModifyIndex)
+			if item.Resolution == OnConflictPolicyRename {
+				item.RenamedTo = c.renamedTokenName(token.Name, token.ID)
+			}
+			resp.Conflicts = append(resp.Conflicts, item)
 			continue
 		}
 		dErr := err.(*DomainError)
@@ -678,6 +1340,51 @@ func (s *a2) ImportDryrun(ctx context.Context, meta *DryrunMetadata) *ImportResp
 			Cause: dErr.Message,
 		})
 	}
+	// 检查intentions冲突
+	for i := range meta.Intentions {
+		in := &meta.Intentions[i]
+		name := IntentionName(in.Source, in.Destination)
+		existing, err := s.intention.ReadIntention(ctx, in.Source, in.Destination)
+		if err == nil {
+			if sameIntention(existing, in) {
+				continue
+			}
+			resp.Conflicts = append(resp.Conflicts, c.resolve("intention", name, 0))
+			continue
+		}
+		dErr := err.(*DomainError)
+		if dErr.Code == DomainErrorCodeNotFound {
+			resp.Successes = append(resp.Successes, ImportResponseItem{Kind: "intention", Param: name})
+			continue
+		}
+		resp.Errors = append(resp.Errors, ImportResponseItem{
+			Kind:  "intention",
+			Param: name,
+			Cause: dErr.Message,
+		})
+	}
+	// 检查config entries冲突
+	for _, link := range meta.ConfigEntries {
+		param := link.Kind + "/" + link.Name
+		existing, err := s.configEntry.ReadConfigEntry(ctx, link.Kind, link.Name)
+		if err == nil {
+			if content, ok := meta.ConfigEntryContents[param]; ok && renderConfigEntry(existing) == content {
+				continue
+			}
+			resp.Conflicts = append(resp.Conflicts, c.resolve("config-entry", param, 0))
+			continue
+		}
+		dErr := err.(*DomainError)
+		if dErr.Code == DomainErrorCodeNotFound {
+			resp.Successes = append(resp.Successes, ImportResponseItem{Kind: "config-entry", Param: param})
+			continue
+		}
+		resp.Errors = append(resp.Errors, ImportResponseItem{
+			Kind:  "config-entry",
+			Param: param,
+			Cause: dErr.Message,
+		})
+	}
 	slog.Debug("import response", "success", resp.Successes, "conflicts", resp.Conflicts, "errors", resp.Errors)
 	return resp
 }
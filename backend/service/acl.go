@@ -11,6 +11,7 @@ import (
 	"slices"
 	"time"
 
+	"github.com/FlyingOnion/consee/backend/buffer"
 	. "github.com/FlyingOnion/consee/backend/common"
 	"github.com/FlyingOnion/consee/backend/consul"
 	"github.com/FlyingOnion/consee/backend/repo"
@@ -40,7 +41,12 @@ type ACLService interface {
 	CreatePolicy(ctx context.Context, req *CreatePolicyRequest) error
 	ReadPolicy(ctx context.Context, name string) (*ReadPolicyResponse, error)
 	UpdatePolicyRule(ctx context.Context, name, rules string) error
-	DeletePolicy(ctx context.Context, name string) error
+	// PreviewDeletePolicy lists the tokens and roles that still use the policy.
+	PreviewDeletePolicy(ctx context.Context, name string) (*PolicyDeletePreview, error)
+	// DeletePolicy deletes the policy.
+	// If it is still in use, req.Confirm must be set, and the policy is detached from
+	// (or replaced by req.Replacement in) every token and role before deletion.
+	DeletePolicy(ctx context.Context, name string, req *DeletePolicyRequest) error
 
 	ListRoles(ctx context.Context) ([]ACLLink, error)
 	CreateRole(ctx context.Context, req *CreateRoleRequest) error
@@ -99,26 +105,7 @@ func (s *aclService) CheckAdmin(ctx context.Context) error {
 }
 
 func (s *aclService) ListTokens(ctx context.Context) ([]ACLLink, error) {
-	resp, err := s.admin.AdminRepo().List(ctx, ConseeInternalKeyPrefix+"acl-token/id-name/")
-	if err != nil {
-		slog.Error("failed to list tokens", "error", err)
-		return nil, errFailedToConnectConsul
-	}
-	if resp.Status == http.StatusForbidden {
-		return nil, errAdminPermissionDenied
-	}
-	if resp.Err != nil {
-		slog.Error("failed to parse token list response", "error", resp.Err)
-		return nil, errFailedToParse
-	}
-	tokenList := make([]ACLLink, 0, len(resp.Body))
-	for _, kvp := range resp.Body {
-		tokenList = append(tokenList, ACLLink{
-			ID:   kvp.Key[len(ConseeInternalKeyPrefix+"acl-token/id-name/"):],
-			Name: string(kvp.Value),
-		})
-	}
-	return tokenList, nil
+	return s.admin.ListTokenNames(ctx)
 }
 
 func (a *aclService) listPolicyTokens(ctx context.Context, policyId string) ([]ACLLink, error) {
@@ -137,6 +124,10 @@ func (a *aclService) listPolicyTokens(ctx context.Context, policyId string) ([]A
 	tokenList := make([]ACLLink, 0, len(resp.Body))
 	for _, t := range resp.Body {
 		name, err := a.admin.GetTokenName(ctx, t.AccessorID)
+		if dErr, ok := err.(*DomainError); ok && dErr.Code == DomainErrorCodeNotFound {
+			// token is not created by consee
+			name, err = t.Description, nil
+		}
 		if err != nil {
 			slog.Error("failed to get token name during policy token listing", "tokenId", t.AccessorID, "policyId", policyId, "error", err)
 			return []ACLLink{}, err
@@ -182,12 +173,13 @@ func (s *aclService) ReadToken(ctx context.Context, id string) (*ReadTokenRespon
 	}
 
 	return &ReadTokenResponse{
-		AccessorID: resp.Body.AccessorID,
-		SecretID:   resp.Body.SecretID,
-		Policies:   policies,
-		Roles:      roles,
-		Name:       name,
-		Metadata:   metadata,
+		AccessorID:  resp.Body.AccessorID,
+		SecretID:    resp.Body.SecretID,
+		Policies:    policies,
+		Roles:       roles,
+		Name:        name,
+		Metadata:    metadata,
+		ModifyIndex: resp.Body.ModifyIndex,
 	}, nil
 }
 
@@ -509,6 +501,20 @@ func (s *aclService) ValidateHCLRules(rules string) *ValidateHCLRulesResponse {
 	}
 }
 
+// currentActor returns "<accessor id> (<token name>)" of the token in ctx.
+// It's used to record who makes the change.
+func currentActor(ctx context.Context, acl repo.ACLRepo, admin AdminService) string {
+	resp, err := acl.ReadSelf(ctx)
+	if err != nil || resp.Status != http.StatusOK || resp.Body == nil {
+		return "unknown"
+	}
+	name, _ := admin.GetTokenName(ctx, resp.Body.AccessorID)
+	if name == "" {
+		name = "unknown"
+	}
+	return resp.Body.AccessorID + " (" + name + ")"
+}
+
 func aclLinkCompare(a, b ACLLink) int {
 	if a.Name < b.Name {
 		return -1
@@ -583,6 +589,7 @@ func (s *aclService) ReadPolicy(ctx context.Context, name string) (*ReadPolicyRe
 		ParsedRules: parsedRules,
 		Rules:       resp.Body.Rules,
 		Tokens:      tokens,
+		ModifyIndex: resp.Body.ModifyIndex,
 	}, nil
 }
 
@@ -628,25 +635,233 @@ func (s *aclService) CreatePolicy(ctx context.Context, req *CreatePolicyRequest)
 	return nil
 }
 
-func (s *aclService) DeletePolicy(ctx context.Context, name string) error {
+func (s *aclService) readCommonPolicy(ctx context.Context, name string) (*consul.ACLPolicy, error) {
 	resp, err := s.acl.ReadPolicyByName(ctx, name)
 	if err != nil {
-		return errFailedToConnectConsul
+		return nil, errFailedToConnectConsul
 	}
 	if resp.Status == http.StatusForbidden {
-		return errPermissionDenied
+		return nil, errPermissionDenied
 	}
 	if resp.Status == http.StatusNotFound {
-		return &DomainError{Code: DomainErrorCodeNotFound, Message: "policy not found"}
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "policy " + name + " not found"}
 	}
 	if resp.Err != nil || resp.Body == nil {
-		return errFailedToParse
+		return nil, errFailedToParse
 	}
 	if ConseeExclusivePolicyNameRegexp.MatchString(resp.Body.Name) {
-		return &DomainError{Code: DomainErrorCodePermissionDenied, Message: "exclusive policy can not be deleted separately"}
+		return nil, &DomainError{Code: DomainErrorCodePermissionDenied, Message: "exclusive policy can not be deleted separately"}
+	}
+	return resp.Body, nil
+}
+
+func (s *aclService) listPolicyRoles(ctx context.Context, policyId string) ([]ACLLink, error) {
+	resp, err := s.acl.ListRolesFiltered(ctx, consul.ACLRoleFilterOptions{Policy: policyId})
+	if err != nil {
+		slog.Error("failed to list policy roles", "policyId", policyId, "error", err)
+		return []ACLLink{}, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return []ACLLink{}, errPermissionDenied
+	}
+	if resp.Err != nil {
+		slog.Error("failed to parse policy roles response", "policyId", policyId, "error", resp.Err)
+		return []ACLLink{}, errFailedToParse
+	}
+	roleList := make([]ACLLink, 0, len(resp.Body))
+	for _, r := range resp.Body {
+		roleList = append(roleList, ACLLink{ID: r.ID, Name: r.Name})
+	}
+	slices.SortStableFunc(roleList, aclLinkCompare)
+	return roleList, nil
+}
+
+func (s *aclService) PreviewDeletePolicy(ctx context.Context, name string) (*PolicyDeletePreview, error) {
+	policy, err := s.readCommonPolicy(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.listPolicyTokens(ctx, policy.ID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.listPolicyRoles(ctx, policy.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyDeletePreview{
+		ID:     policy.ID,
+		Name:   policy.Name,
+		Tokens: tokens,
+		Roles:  roles,
+	}, nil
+}
+
+func (s *aclService) DeletePolicy(ctx context.Context, name string, req *DeletePolicyRequest) error {
+	if req == nil {
+		req = &DeletePolicyRequest{}
+	}
+	preview, err := s.PreviewDeletePolicy(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(preview.Tokens) == 0 && len(preview.Roles) == 0 {
+		return s.deletePolicy(ctx, preview.ID)
+	}
+	if !req.Confirm {
+		var b buffer.Buffer
+		b.WriteString("policy is still used by ").WriteInt(len(preview.Tokens)).WriteString(" token(s) and ").
+			WriteInt(len(preview.Roles)).WriteString(" role(s); confirmation is required to detach it")
+		return &DomainError{Code: DomainErrorCodeConflict, Message: b.String()}
+	}
+
+	var replacement *consul.ACLLink
+	if req.Replacement != "" {
+		if req.Replacement == preview.Name {
+			return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "replacement policy should not be the deleted one"}
+		}
+		p, err := s.readCommonPolicy(ctx, req.Replacement)
+		if err != nil {
+			return err
+		}
+		replacement = &consul.ACLLink{ID: p.ID, Name: p.Name}
+	}
+
+	// tokens and roles are updated one by one, so a failure reports those already updated
+	actor := currentActor(ctx, s.acl, s.admin)
+	var detached []string
+	for _, t := range preview.Tokens {
+		err = s.detachPolicyFromToken(ctx, t.ID, preview.ID, replacement, actor)
+		if err != nil {
+			slog.Error("failed to detach policy from token", "policyId", preview.ID, "tokenId", t.ID, "error", err)
+			return detachError(err, "token "+aclLinkName(t), detached)
+		}
+		detached = append(detached, "token "+aclLinkName(t))
+	}
+	for _, r := range preview.Roles {
+		err = s.detachPolicyFromRole(ctx, r.ID, preview.ID, replacement)
+		if err != nil {
+			slog.Error("failed to detach policy from role", "policyId", preview.ID, "roleId", r.ID, "error", err)
+			return detachError(err, "role "+aclLinkName(r), detached)
+		}
+		detached = append(detached, "role "+aclLinkName(r))
+	}
+	if err = s.deletePolicy(ctx, preview.ID); err != nil {
+		return detachError(err, "", detached)
+	}
+	return nil
+}
+
+// aclLinkName names a token or role in messages. Tokens created outside consee may have no name.
+func aclLinkName(l ACLLink) string {
+	if l.Name == "" {
+		return l.ID
+	}
+	return l.Name
+}
+
+// detachError describes a failure of DeletePolicy after the policy is detached from some tokens and roles,
+// which are not attached again. failed is the item which failed, or "" if the deletion failed.
+func detachError(err error, failed string, detached []string) error {
+	var b buffer.Buffer
+	b.WriteString("policy is not deleted: ")
+	if failed != "" {
+		b.WriteString("failed to detach it from ").WriteString(failed).WriteString(": ")
+	}
+	b.WriteString(err.Error())
+	for i, item := range detached {
+		if i == 0 {
+			b.WriteString("; already detached from ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(item)
+	}
+	code := DomainErrorCodeInternalError
+	if dErr, ok := err.(*DomainError); ok {
+		code = dErr.Code
+	}
+	return &DomainError{Code: code, Message: b.String()}
+}
+
+// replacePolicyLink removes the link of policyId, and appends replacement if it's not nil and not linked yet.
+func replacePolicyLink(links []*consul.ACLLink, policyId string, replacement *consul.ACLLink) []*consul.ACLLink {
+	result := make([]*consul.ACLLink, 0, len(links)+1)
+	for _, l := range links {
+		if l.ID == policyId {
+			continue
+		}
+		if replacement != nil && l.ID == replacement.ID {
+			replacement = nil
+		}
+		result = append(result, l)
+	}
+	if replacement != nil {
+		result = append(result, replacement)
+	}
+	return result
+}
+
+func (s *aclService) detachPolicyFromToken(ctx context.Context, tokenId, policyId string, replacement *consul.ACLLink, actor string) error {
+	resp, err := s.acl.ReadToken(ctx, tokenId)
+	if err != nil {
+		return errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return errPermissionDenied
 	}
+	if resp.Status != http.StatusOK || resp.Body == nil {
+		return errUnknown
+	}
+	token := resp.Body
+	token.Policies = replacePolicyLink(token.Policies, policyId, replacement)
+	resp2, err := s.acl.UpdateToken(ctx, token)
+	if err != nil {
+		return errFailedToConnectConsul
+	}
+	if resp2.Status == http.StatusForbidden {
+		return errPermissionDenied
+	}
+	if resp2.Status != http.StatusOK {
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to update token " + tokenId + ": " + string(resp2.RawBody)}
+	}
+
+	metadata, err := s.admin.GetTokenMetadata(ctx, tokenId)
+	if err != nil {
+		// tokens created outside consee have no metadata
+		return nil
+	}
+	now := time.Now().Format(time.DateTime)
+	metadata.Version = now
+	metadata.LastUpdatedAt = now
+	metadata.LastUpdatedBy = actor
+	return s.admin.WriteTokenMetadata(ctx, tokenId, metadata)
+}
 
-	return s.deletePolicy(ctx, resp.Body.ID)
+func (s *aclService) detachPolicyFromRole(ctx context.Context, roleId, policyId string, replacement *consul.ACLLink) error {
+	resp, err := s.acl.ReadRole(ctx, roleId)
+	if err != nil {
+		return errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return errPermissionDenied
+	}
+	if resp.Status != http.StatusOK || resp.Body == nil {
+		return errUnknown
+	}
+	role := resp.Body
+	role.Policies = replacePolicyLink(role.Policies, policyId, replacement)
+	resp2, err := s.acl.UpdateRole(ctx, role)
+	if err != nil {
+		return errFailedToConnectConsul
+	}
+	if resp2.Status == http.StatusForbidden {
+		return errPermissionDenied
+	}
+	if resp2.Status != http.StatusOK {
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to update role " + roleId + ": " + string(resp2.RawBody)}
+	}
+	return nil
 }
 
 func (s *aclService) deletePolicy(ctx context.Context, id string) error {
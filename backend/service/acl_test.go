@@ -0,0 +1,148 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// fakePolicyACLRepo keeps policies, tokens and roles in memory. Updates of failing tokens or roles are refused.
+type fakePolicyACLRepo struct {
+	fakeACLRepo
+	policies map[string]*consul.ACLPolicy
+	tokens   map[string]*consul.ACLToken
+	roles    map[string]*consul.ACLRole
+	failing  string
+}
+
+func linked(links []*consul.ACLLink, id string) bool {
+	for _, l := range links {
+		if l.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakePolicyACLRepo) ReadPolicyByName(ctx context.Context, name string) (*consul.Response[*consul.ACLPolicy], error) {
+	for _, p := range f.policies {
+		if p.Name == name {
+			return &consul.Response[*consul.ACLPolicy]{Status: http.StatusOK, Body: p}, nil
+		}
+	}
+	return &consul.Response[*consul.ACLPolicy]{Status: http.StatusNotFound}, nil
+}
+
+func (f *fakePolicyACLRepo) DeletePolicy(ctx context.Context, id string) (*consul.Response[bool], error) {
+	delete(f.policies, id)
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+func (f *fakePolicyACLRepo) ListTokensFiltered(ctx context.Context, o consul.ACLTokenFilterOptions) (*consul.Response[[]*consul.ACLToken], error) {
+	tokens := []*consul.ACLToken{}
+	for _, t := range f.tokens {
+		if linked(t.Policies, o.Policy) {
+			tokens = append(tokens, t)
+		}
+	}
+	return &consul.Response[[]*consul.ACLToken]{Status: http.StatusOK, Body: tokens}, nil
+}
+
+func (f *fakePolicyACLRepo) ReadToken(ctx context.Context, id string) (*consul.Response[*consul.ACLToken], error) {
+	t := *f.tokens[id]
+	return &consul.Response[*consul.ACLToken]{Status: http.StatusOK, Body: &t}, nil
+}
+
+func (f *fakePolicyACLRepo) UpdateToken(ctx context.Context, t *consul.ACLToken) (*consul.Response[*consul.ACLToken], error) {
+	if t.AccessorID == f.failing {
+		return &consul.Response[*consul.ACLToken]{Status: http.StatusInternalServerError, RawBody: []byte("rpc error")}, nil
+	}
+	f.tokens[t.AccessorID] = t
+	return &consul.Response[*consul.ACLToken]{Status: http.StatusOK, Body: t}, nil
+}
+
+func (f *fakePolicyACLRepo) ListRolesFiltered(ctx context.Context, o consul.ACLRoleFilterOptions) (*consul.Response[[]*consul.ACLRole], error) {
+	roles := []*consul.ACLRole{}
+	for _, r := range f.roles {
+		if linked(r.Policies, o.Policy) {
+			roles = append(roles, r)
+		}
+	}
+	return &consul.Response[[]*consul.ACLRole]{Status: http.StatusOK, Body: roles}, nil
+}
+
+func (f *fakePolicyACLRepo) ReadRole(ctx context.Context, id string) (*consul.Response[*consul.ACLRole], error) {
+	r := *f.roles[id]
+	return &consul.Response[*consul.ACLRole]{Status: http.StatusOK, Body: &r}, nil
+}
+
+func (f *fakePolicyACLRepo) UpdateRole(ctx context.Context, r *consul.ACLRole) (*consul.Response[*consul.ACLRole], error) {
+	if r.ID == f.failing {
+		return &consul.Response[*consul.ACLRole]{Status: http.StatusInternalServerError, RawBody: []byte("rpc error")}, nil
+	}
+	f.roles[r.ID] = r
+	return &consul.Response[*consul.ACLRole]{Status: http.StatusOK, Body: r}, nil
+}
+
+// tokenAdminService has no metadata of tokens, like tokens created outside consee.
+type tokenAdminService struct {
+	*fakeAdminService
+}
+
+func (tokenAdminService) GetTokenName(ctx context.Context, accessorId string) (string, error) {
+	return "", &DomainError{Code: DomainErrorCodeNotFound, Message: "token not found"}
+}
+
+func (tokenAdminService) GetTokenMetadata(ctx context.Context, accessorId string) (*TokenMetadata, error) {
+	return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "token not found"}
+}
+
+func TestDeletePolicy(t *testing.T) {
+	ctx := context.Background()
+	old := &consul.ACLLink{ID: "p-old", Name: "old"}
+	acl := &fakePolicyACLRepo{
+		policies: map[string]*consul.ACLPolicy{"p-old": {ID: "p-old", Name: "old"}, "p-new": {ID: "p-new", Name: "new"}},
+		tokens: map[string]*consul.ACLToken{
+			"t-ci":  {AccessorID: "t-ci", Description: "ci", Policies: []*consul.ACLLink{old}},
+			"t-app": {AccessorID: "t-app", Description: "app", Policies: []*consul.ACLLink{old}},
+		},
+		roles:   map[string]*consul.ACLRole{"r-ops": {ID: "r-ops", Name: "ops", Policies: []*consul.ACLLink{old}}},
+		failing: "r-ops",
+	}
+	s := NewACLService(acl, tokenAdminService{&fakeAdminService{}})
+
+	// a failure halfway reports the tokens already detached, and the policy is kept
+	err := s.DeletePolicy(ctx, "old", &DeletePolicyRequest{Confirm: true, Replacement: "new"})
+	if err == nil {
+		t.Fatal("expected the update of the role to fail")
+	}
+	for _, want := range []string{"role ops", "already detached from", "token ci", "token app"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %q", err, want)
+		}
+	}
+	if acl.policies["p-old"] == nil {
+		t.Error("policy deleted though it's still attached to a role")
+	}
+	for _, token := range acl.tokens {
+		if linked(token.Policies, "p-old") || !linked(token.Policies, "p-new") {
+			t.Errorf("policies of token %s = %v", token.AccessorID, token.Policies)
+		}
+	}
+
+	// the deletion is resumed once the role could be updated
+	acl.failing = ""
+	if err := s.DeletePolicy(ctx, "old", &DeletePolicyRequest{Confirm: true, Replacement: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if acl.policies["p-old"] != nil || linked(acl.roles["r-ops"].Policies, "p-old") || !linked(acl.roles["r-ops"].Policies, "p-new") {
+		t.Errorf("policy not deleted: %v, %v", acl.policies, acl.roles["r-ops"].Policies)
+	}
+}
@@ -4,10 +4,16 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
-	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	. "github.com/FlyingOnion/consee/backend/common"
 	"github.com/FlyingOnion/consee/backend/repo"
@@ -18,14 +24,30 @@ var _ AdminService = &adminService{}
 type AdminService interface {
 	// AdminRepo is used for admin operations.
 	// Other than initializations, it should not be used outside service package.
+	// Metadata should be accessed with methods below, since it may not be stored in consul.
 	AdminRepo() repo.AdminRepo
 
 	GetValueType(ctx context.Context, b64key string) (string, error)
+	// ListValueTypes lists value types of all keys, keyed by base64 encoded keys.
+	ListValueTypes(ctx context.Context) (map[string]string, error)
 	WriteValueType(ctx context.Context, b64key, vt string) error
 	DeleteValueType(ctx context.Context, b64key string) error
+	// ListSchemas lists JSON schemas attached to keys or prefixes, sorted by prefix.
+	ListSchemas(ctx context.Context) ([]KVSchema, error)
+	WriteSchema(ctx context.Context, prefix, schema string) error
+	DeleteSchema(ctx context.Context, prefix string) error
+	// ListSecrets lists keys or prefixes whose values are secrets, sorted.
+	ListSecrets(ctx context.Context) ([]string, error)
+	WriteSecret(ctx context.Context, prefix string) error
+	DeleteSecret(ctx context.Context, prefix string) error
+	// CopyKVMeta copies the value type and history versions of a key to another key.
+	CopyKVMeta(ctx context.Context, b64src, b64dst string) error
+	// DeleteKVMeta deletes the value type and history versions of a key.
+	DeleteKVMeta(ctx context.Context, b64key string) error
 	GetKVHistory(ctx context.Context, b64key string) ([]string, error)
 	AddNewHistoryVersion(ctx context.Context, b64key, version, oldValue string) error
 	GetKVHistoryValue(ctx context.Context, b64key, version string) (string, error)
+	DeleteKVHistory(ctx context.Context, b64key string) error
 	// GetKVMeta(ctx context.Context, key string) (*KVMeta, error)
 	// WriteKVMeta(ctx context.Context, key string, meta *KVMeta) error
 	// DeleteKVMeta(ctx context.Context, key string) error
@@ -37,63 +59,208 @@ type AdminService interface {
 	// CheckAdmin(ctx context.Context, token string) error
 	GetTokenMetadata(ctx context.Context, accessorId string) (*TokenMetadata, error)
 	GetTokenName(ctx context.Context, accessorId string) (string, error)
+	// ListTokenNames lists tokens created by consee with their names.
+	ListTokenNames(ctx context.Context) ([]ACLLink, error)
 	GetTokenIdByName(ctx context.Context, name string) (accessorId string, err error)
 	// WriteIdNameMapping writes both id-name and name-id mapping
 	WriteIdNameMapping(ctx context.Context, accessorId, name string) error
 	WriteTokenMetadata(ctx context.Context, accessorId string, metadata *TokenMetadata) error
 	DeleteTokenMetadata(ctx context.Context, accessorId, name string) error
+
+	// WriteAuditRecord records a sensitive operation.
+	WriteAuditRecord(ctx context.Context, record *AuditRecord) error
+	// ListAuditRecords lists audit records, latest first.
+	ListAuditRecords(ctx context.Context) ([]AuditRecord, error)
 }
 
 type adminService struct {
-	admin repo.AdminRepo
+	admin   repo.AdminRepo
+	meta    repo.MetadataStore
+	schemas schemaList
+}
+
+// schemaListTTL is how long listed schemas are cached, so that schemas written by other consee instances take effect.
+const schemaListTTL = 10 * time.Second
+
+// schemaList caches listed schemas, since writes of JSON and YAML values look them up.
+type schemaList struct {
+	mu      sync.Mutex
+	schemas []KVSchema
+	listed  time.Time
 }
 
-func NewAdminService(admin repo.AdminRepo) AdminService {
-	return &adminService{admin}
+func NewAdminService(admin repo.AdminRepo, meta repo.MetadataStore) AdminService {
+	return &adminService{admin: admin, meta: meta}
 }
 
 func (a *adminService) AdminRepo() repo.AdminRepo {
 	return a.admin
 }
 
+// metadataError converts errors of the metadata store to domain errors.
+func metadataError(err error) error {
+	if errors.Is(err, repo.ErrMetadataPermissionDenied) {
+		return errAdminPermissionDenied
+	}
+	return errFailedToAccessMetadata
+}
+
 func (a *adminService) GetValueType(ctx context.Context, b64key string) (string, error) {
-	resp, err := a.admin.Read(ctx, ConseeInternalKeyPrefix+"kvmeta/valuetype/"+b64key)
+	value, _, err := a.meta.Get(ctx, "kvmeta/valuetype/"+b64key)
 	if err != nil {
 		slog.Error("failed to get value type", "b64key", b64key, "error", err)
-		return "", errFailedToConnectConsul
+		return "", metadataError(err)
 	}
-	if resp.Status == http.StatusForbidden {
-		return "", errAdminPermissionDenied
+	return string(value), nil
+}
+
+func (a *adminService) ListValueTypes(ctx context.Context) (map[string]string, error) {
+	pairs, err := a.meta.List(ctx, "kvmeta/valuetype/")
+	if err != nil {
+		slog.Error("failed to list value types", "error", err)
+		return nil, metadataError(err)
 	}
-	if resp.Body != nil && len(resp.Body.Value) > 0 {
-		return string(resp.Body.Value), nil
+	valueTypes := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		valueTypes[strings.TrimPrefix(pair.Key, "kvmeta/valuetype/")] = string(pair.Value)
 	}
-	return "", nil
+	return valueTypes, nil
 }
 
 func (a *adminService) WriteValueType(ctx context.Context, b64key, vt string) error {
 	if vt == "" {
 		vt = "plaintext"
 	}
-	resp, err := a.admin.Write(ctx, ConseeInternalKeyPrefix+"kvmeta/valuetype/"+b64key, vt)
-	if err != nil {
+	if err := a.meta.Put(ctx, "kvmeta/valuetype/"+b64key, []byte(vt)); err != nil {
 		slog.Error("failed to write value type", "b64key", b64key, "valueType", vt, "error", err)
-		return errFailedToConnectConsul
-	}
-	if resp.Status == http.StatusForbidden {
-		return errAdminPermissionDenied
+		return metadataError(err)
 	}
 	return nil
 }
 
 func (a *adminService) DeleteValueType(ctx context.Context, b64key string) error {
-	resp, err := a.admin.Delete(ctx, ConseeInternalKeyPrefix+"kvmeta/valuetype/"+b64key)
-	if err != nil {
+	if err := a.meta.Delete(ctx, "kvmeta/valuetype/"+b64key); err != nil {
 		slog.Error("failed to delete value type", "b64key", b64key, "error", err)
-		return errFailedToConnectConsul
+		return metadataError(err)
 	}
-	if resp.Status == http.StatusForbidden {
-		return errAdminPermissionDenied
+	return nil
+}
+
+func (a *adminService) ListSchemas(ctx context.Context) ([]KVSchema, error) {
+	a.schemas.mu.Lock()
+	defer a.schemas.mu.Unlock()
+	if !a.schemas.listed.IsZero() && time.Since(a.schemas.listed) < schemaListTTL {
+		return slices.Clone(a.schemas.schemas), nil
+	}
+	pairs, err := a.meta.List(ctx, "kvmeta/schema/")
+	if err != nil {
+		slog.Error("failed to list schemas", "error", err)
+		return nil, metadataError(err)
+	}
+	schemas := make([]KVSchema, 0, len(pairs))
+	for _, pair := range pairs {
+		schemas = append(schemas, KVSchema{
+			Prefix: strings.TrimPrefix(pair.Key, "kvmeta/schema/"),
+			Schema: string(pair.Value),
+		})
+	}
+	a.schemas.schemas, a.schemas.listed = schemas, time.Now()
+	return slices.Clone(schemas), nil
+}
+
+// resetSchemas makes the next ListSchemas list schemas again.
+func (a *adminService) resetSchemas() {
+	a.schemas.mu.Lock()
+	a.schemas.listed = time.Time{}
+	a.schemas.mu.Unlock()
+}
+
+func (a *adminService) WriteSchema(ctx context.Context, prefix, schema string) error {
+	defer a.resetSchemas()
+	if err := a.meta.Put(ctx, "kvmeta/schema/"+prefix, []byte(schema)); err != nil {
+		slog.Error("failed to write schema", "prefix", prefix, "error", err)
+		return metadataError(err)
+	}
+	return nil
+}
+
+func (a *adminService) DeleteSchema(ctx context.Context, prefix string) error {
+	defer a.resetSchemas()
+	if err := a.meta.Delete(ctx, "kvmeta/schema/"+prefix); err != nil {
+		slog.Error("failed to delete schema", "prefix", prefix, "error", err)
+		return metadataError(err)
+	}
+	return nil
+}
+
+func (a *adminService) ListSecrets(ctx context.Context) ([]string, error) {
+	pairs, err := a.meta.List(ctx, "kvmeta/secret/")
+	if err != nil {
+		slog.Error("failed to list secrets", "error", err)
+		return nil, metadataError(err)
+	}
+	secrets := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		secrets = append(secrets, strings.TrimPrefix(pair.Key, "kvmeta/secret/"))
+	}
+	return secrets, nil
+}
+
+func (a *adminService) WriteSecret(ctx context.Context, prefix string) error {
+	if err := a.meta.Put(ctx, "kvmeta/secret/"+prefix, []byte{}); err != nil {
+		slog.Error("failed to write secret", "prefix", prefix, "error", err)
+		return metadataError(err)
+	}
+	return nil
+}
+
+func (a *adminService) DeleteSecret(ctx context.Context, prefix string) error {
+	if err := a.meta.Delete(ctx, "kvmeta/secret/"+prefix); err != nil {
+		slog.Error("failed to delete secret", "prefix", prefix, "error", err)
+		return metadataError(err)
+	}
+	return nil
+}
+
+func (a *adminService) CopyKVMeta(ctx context.Context, b64src, b64dst string) error {
+	vt, err := a.GetValueType(ctx, b64src)
+	if err != nil {
+		return err
+	}
+	// keys without value types, e.g. written by other consul clients, are copied without them
+	if vt == "" {
+		err = a.DeleteValueType(ctx, b64dst)
+	} else {
+		err = a.WriteValueType(ctx, b64dst, vt)
+	}
+	if err != nil {
+		return err
+	}
+	versions, err := a.GetKVHistory(ctx, b64src)
+	if err == errNotImplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		value, err := a.GetKVHistoryValue(ctx, b64src, version)
+		if err != nil {
+			return err
+		}
+		if err = a.AddNewHistoryVersion(ctx, b64dst, version, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *adminService) DeleteKVMeta(ctx context.Context, b64key string) error {
+	if err := a.DeleteValueType(ctx, b64key); err != nil {
+		return err
+	}
+	if err := a.DeleteKVHistory(ctx, b64key); err != nil && err != errNotImplemented {
+		return err
 	}
 	return nil
 }
@@ -110,6 +277,10 @@ func (a *adminService) GetKVHistoryValue(ctx context.Context, b64key, version st
 	return "", errNotImplemented
 }
 
+func (a *adminService) DeleteKVHistory(ctx context.Context, b64key string) error {
+	return errNotImplemented
+}
+
 func (a *adminService) ListNotifications(ctx context.Context) (*ListNotificationsResponse, error) {
 	return nil, errNotImplemented
 }
@@ -123,23 +294,16 @@ func (a *adminService) WriteNotification(ctx context.Context, n *Notification) e
 }
 
 func (a *adminService) GetTokenMetadata(ctx context.Context, accessorId string) (*TokenMetadata, error) {
-	resp, err := a.admin.Read(ctx, ConseeInternalKeyPrefix+"acl-token/metadata/"+accessorId)
+	value, ok, err := a.meta.Get(ctx, "acl-token/metadata/"+accessorId)
 	if err != nil {
 		slog.Error("failed to get token metadata", "accessorId", accessorId, "error", err)
-		return nil, errFailedToConnectConsul
+		return nil, metadataError(err)
 	}
-	if resp.Status == http.StatusForbidden {
-		return nil, errAdminPermissionDenied
-	}
-	if resp.Status == http.StatusNotFound {
+	if !ok {
 		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "metadata not found"}
 	}
-	if resp.Err != nil {
-		slog.Error("failed to parse token metadata response", "accessorId", accessorId, "error", resp.Err)
-		return nil, errFailedToParse
-	}
 	var meta TokenMetadata
-	err = json.Unmarshal(resp.Body.Value, &meta)
+	err = json.Unmarshal(value, &meta)
 	if err != nil {
 		slog.Error("failed to unmarshal token metadata", "accessorId", accessorId, "error", err)
 		return nil, errFailedToParse
@@ -148,47 +312,43 @@ func (a *adminService) GetTokenMetadata(ctx context.Context, accessorId string)
 }
 
 func (a *adminService) GetTokenIdByName(ctx context.Context, name string) (accessorId string, err error) {
-	resp, err := a.admin.Read(ctx, ConseeInternalKeyPrefix+"acl-token/name-id/"+name)
+	value, ok, err := a.meta.Get(ctx, "acl-token/name-id/"+name)
 	if err != nil {
 		slog.Error("failed to get token id by name", "name", name, "error", err)
-		return "", errFailedToConnectConsul
+		return "", metadataError(err)
 	}
-	if resp.Status == http.StatusForbidden {
-		return "", errAdminPermissionDenied
-	}
-	if resp.Status == http.StatusNotFound {
+	if !ok {
 		return "", &DomainError{Code: DomainErrorCodeNotFound, Message: "token not found"}
 	}
-	if resp.Err != nil {
-		slog.Error("failed to parse token id response", "name", name, "error", resp.Err)
-		return "", errFailedToParse
-	}
-	if resp.Body == nil || resp.Body.Value == nil {
-		return "", nil
-	}
-	return string(resp.Body.Value), nil
+	return string(value), nil
 }
 
 func (a *adminService) GetTokenName(ctx context.Context, id string) (string, error) {
-	resp, err := a.admin.Read(ctx, ConseeInternalKeyPrefix+"acl-token/id-name/"+id)
+	value, ok, err := a.meta.Get(ctx, "acl-token/id-name/"+id)
 	if err != nil {
 		slog.Error("failed to get token name by id", "id", id, "error", err)
-		return "", errFailedToConnectConsul
+		return "", metadataError(err)
 	}
-	if resp.Status == http.StatusForbidden {
-		return "", errAdminPermissionDenied
-	}
-	if resp.Status == http.StatusNotFound {
+	if !ok {
 		return "", &DomainError{Code: DomainErrorCodeNotFound, Message: "token not found"}
 	}
-	if resp.Err != nil {
-		slog.Error("failed to parse token name response", "id", id, "error", resp.Err)
-		return "", errFailedToParse
+	return string(value), nil
+}
+
+func (a *adminService) ListTokenNames(ctx context.Context) ([]ACLLink, error) {
+	pairs, err := a.meta.List(ctx, "acl-token/id-name/")
+	if err != nil {
+		slog.Error("failed to list tokens", "error", err)
+		return nil, metadataError(err)
 	}
-	if resp.Body == nil || resp.Body.Value == nil {
-		return "", nil
+	tokenList := make([]ACLLink, 0, len(pairs))
+	for _, pair := range pairs {
+		tokenList = append(tokenList, ACLLink{
+			ID:   strings.TrimPrefix(pair.Key, "acl-token/id-name/"),
+			Name: string(pair.Value),
+		})
 	}
-	return string(resp.Body.Value), nil
+	return tokenList, nil
 }
 
 func (a *adminService) WriteIdNameMapping(ctx context.Context, id, name string) (err error) {
@@ -200,57 +360,100 @@ func (a *adminService) WriteIdNameMapping(ctx context.Context, id, name string)
 }
 
 func (a *adminService) writeIdNameMapping(ctx context.Context, id, name string) error {
-	resp, err := a.admin.Write(ctx, ConseeInternalKeyPrefix+"acl-token/id-name/"+id, name)
-	if err != nil {
+	if err := a.meta.Put(ctx, "acl-token/id-name/"+id, []byte(name)); err != nil {
 		slog.Error("failed to write id-name mapping", "id", id, "name", name, "error", err)
-		return errFailedToConnectConsul
-	}
-	if resp.Status == http.StatusForbidden {
-		return errAdminPermissionDenied
-	}
-	if resp.Err != nil {
-		slog.Error("failed to parse id-name mapping response", "id", id, "name", name, "error", resp.Err)
-		return errFailedToParse
+		return metadataError(err)
 	}
 	return nil
 }
 
 func (a *adminService) writeNameIdMapping(ctx context.Context, name, id string) error {
-	resp, err := a.admin.Write(ctx, ConseeInternalKeyPrefix+"acl-token/name-id/"+name, id)
-	if err != nil {
+	if err := a.meta.Put(ctx, "acl-token/name-id/"+name, []byte(id)); err != nil {
 		slog.Error("failed to write name-id mapping", "name", name, "id", id, "error", err)
-		return errFailedToConnectConsul
-	}
-	if resp.Status == http.StatusForbidden {
-		return errAdminPermissionDenied
-	}
-	if resp.Err != nil {
-		slog.Error("failed to parse name-id mapping response", "name", name, "id", id, "error", resp.Err)
-		return errFailedToParse
+		return metadataError(err)
 	}
 	return nil
 }
 
 func (a *adminService) WriteTokenMetadata(ctx context.Context, id string, metadata *TokenMetadata) error {
 	b, _ := metadata.MarshalJSON()
-	resp, err := a.admin.Write(ctx, ConseeInternalKeyPrefix+"acl-token/metadata/"+id, string(b))
-	if err != nil {
+	if err := a.meta.Put(ctx, "acl-token/metadata/"+id, b); err != nil {
 		slog.Error("failed to write token metadata", "id", id, "error", err)
-		return errFailedToConnectConsul
-	}
-	if resp.Status == http.StatusForbidden {
-		return errAdminPermissionDenied
-	}
-	if resp.Err != nil {
-		slog.Error("failed to parse token metadata response", "id", id, "error", resp.Err)
-		return errFailedToParse
+		return metadataError(err)
 	}
 	return nil
 }
 
 func (a *adminService) DeleteTokenMetadata(ctx context.Context, id, name string) error {
-	a.admin.Delete(ctx, ConseeInternalKeyPrefix+"acl-token/id-name/"+id)
-	a.admin.Delete(ctx, ConseeInternalKeyPrefix+"acl-token/name-id/"+name)
-	a.admin.Delete(ctx, ConseeInternalKeyPrefix+"acl-token/metadata/"+id)
+	a.meta.Delete(ctx, "acl-token/id-name/"+id)
+	a.meta.Delete(ctx, "acl-token/name-id/"+name)
+	a.meta.Delete(ctx, "acl-token/metadata/"+id)
+	return nil
+}
+
+func (a *adminService) WriteAuditRecord(ctx context.Context, record *AuditRecord) error {
+	now := time.Now()
+	if record.Time == "" {
+		record.Time = now.Format(time.DateTime)
+	}
+	b, _ := json.Marshal(record)
+	// keys are sortable by time, and the nanoseconds keep them unique
+	key := "audit/" + now.UTC().Format("20060102150405.000000000") + "-" + record.Action
+	if err := a.meta.Put(ctx, key, b); err != nil {
+		slog.Error("failed to write audit record", "action", record.Action, "actor", record.Actor, "error", err)
+		return metadataError(err)
+	}
 	return nil
 }
+
+func (a *adminService) ListAuditRecords(ctx context.Context) ([]AuditRecord, error) {
+	pairs, err := a.meta.List(ctx, "audit/")
+	if err != nil {
+		slog.Error("failed to list audit records", "error", err)
+		return nil, metadataError(err)
+	}
+	records := make([]AuditRecord, 0, len(pairs))
+	for i := len(pairs) - 1; i >= 0; i-- {
+		var record AuditRecord
+		if err := json.Unmarshal(pairs[i].Value, &record); err != nil {
+			slog.Warn("invalid audit record", "key", pairs[i].Key, "error", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// MigrateMetadata copies all metadata from one store to another, and returns the number of copied entries.
+// Entries in the destination are overwritten. The source is kept unless purge is true, and then
+// copied entries are deleted from it only after all of them are read back from the destination as they are.
+// It should run while consee is stopped, so that no entry is written during the migration.
+func MigrateMetadata(ctx context.Context, from, to repo.MetadataStore, purge bool) (int, error) {
+	pairs, err := from.List(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	for i, pair := range pairs {
+		if err := to.Put(ctx, pair.Key, pair.Value); err != nil {
+			return i, err
+		}
+	}
+	if !purge {
+		return len(pairs), nil
+	}
+	for _, pair := range pairs {
+		value, ok, err := to.Get(ctx, pair.Key)
+		if err != nil {
+			return len(pairs), fmt.Errorf("failed to verify %s, nothing is purged: %w", pair.Key, err)
+		}
+		if !ok || !bytes.Equal(value, pair.Value) {
+			return len(pairs), fmt.Errorf("%s is not copied as it is, nothing is purged", pair.Key)
+		}
+	}
+	for _, pair := range pairs {
+		if err := from.Delete(ctx, pair.Key); err != nil {
+			return len(pairs), fmt.Errorf("failed to purge %s: %w", pair.Key, err)
+		}
+	}
+	return len(pairs), nil
+}
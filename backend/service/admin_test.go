@@ -0,0 +1,83 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/FlyingOnion/consee/backend/infra"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// lossyMetadataStore drops writes of a key, like a destination which fails silently.
+type lossyMetadataStore struct {
+	repo.MetadataStore
+	lost string
+}
+
+func (m *lossyMetadataStore) Put(ctx context.Context, key string, value []byte) error {
+	if key == m.lost {
+		return nil
+	}
+	return m.MetadataStore.Put(ctx, key, value)
+}
+
+func TestMigrateMetadata(t *testing.T) {
+	ctx := context.Background()
+	open := func(name string) repo.MetadataStore {
+		t.Helper()
+		m, closer, err := infra.NewBoltMetadata(filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { closer.Close() })
+		return m
+	}
+	from := open("from.db")
+	entries := map[string]string{"kvmeta/valuetype/YQ==": "json", "kvmeta/secret/app/": "", "audit/1": `{"action":"kv-promote"}`}
+	for key, value := range entries {
+		if err := from.Put(ctx, key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(m repo.MetadataStore) int {
+		pairs, err := m.List(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(pairs)
+	}
+
+	// nothing is purged unless every entry is read back from the destination
+	lossy := &lossyMetadataStore{MetadataStore: open("lossy.db"), lost: "audit/1"}
+	if _, err := MigrateMetadata(ctx, from, lossy, true); err == nil {
+		t.Error("expected the verification to fail")
+	}
+	if count(from) != len(entries) {
+		t.Fatal("source purged though an entry is not copied")
+	}
+
+	// the source is kept by default
+	to := open("to.db")
+	if n, err := MigrateMetadata(ctx, from, to, false); err != nil || n != len(entries) {
+		t.Fatalf("migrate = %d, %v", n, err)
+	}
+	if count(from) != len(entries) {
+		t.Error("source changed without purge")
+	}
+	for key, value := range entries {
+		if v, ok, err := to.Get(ctx, key); err != nil || !ok || string(v) != value {
+			t.Errorf("%s = %q, %v, %v", key, v, ok, err)
+		}
+	}
+
+	if n, err := MigrateMetadata(ctx, from, to, true); err != nil || n != len(entries) {
+		t.Fatalf("migrate with purge = %d, %v", n, err)
+	}
+	if count(from) != 0 || count(to) != len(entries) {
+		t.Errorf("entries after purge: %d in the source, %d in the destination", count(from), count(to))
+	}
+}
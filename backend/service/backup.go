@@ -0,0 +1,276 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/repo"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	AuditActionBackupRestore = "backup-restore"
+
+	backupFilePrefix   = "consee-backup-"
+	backupFileSuffix   = ".zip"
+	backupManifestName = "manifest.json"
+)
+
+type BackupOptions struct {
+	// Prefixes of keys to back up. All keys are backed up if it's empty.
+	Prefixes []string
+	ACL      bool
+	Dir      string
+	// Keep is the number of latest backups to keep, 0 means all.
+	Keep int
+	// Recipient encrypts backups to the public key if it's not nil.
+	Recipient *ecdh.PublicKey
+}
+
+// BackupService writes zip exports into a local directory, and restores them via import.
+type BackupService interface {
+	// Backup writes a new backup. ctx should contain options with the admin token.
+	Backup(ctx context.Context) (*BackupFile, error)
+	ListBackups(ctx context.Context) ([]BackupFile, error)
+	// RestoreBackup imports the backup after verifying its checksum.
+	RestoreBackup(ctx context.Context, name string, req *RestoreBackupRequest) (*ImportResponse, error)
+}
+
+type backupService struct {
+	all     All
+	kv      KVService
+	acl     repo.ACLRepo
+	admin   AdminService
+	options BackupOptions
+
+	// mu protects the manifest
+	mu sync.Mutex
+}
+
+func NewBackupService(all All, kv KVService, acl repo.ACLRepo, admin AdminService, options BackupOptions) BackupService {
+	return &backupService{all: all, kv: kv, acl: acl, admin: admin, options: options}
+}
+
+func (s *backupService) readManifest() (*BackupManifest, error) {
+	manifest := &BackupManifest{Backups: []BackupFile{}}
+	b, err := os.ReadFile(filepath.Join(s.options.Dir, backupManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, manifest)
+	return manifest, err
+}
+
+func (s *backupService) writeManifest(manifest *BackupManifest) error {
+	b, _ := json.MarshalIndent(manifest, "", "  ")
+	return writeFileAtomically(filepath.Join(s.options.Dir, backupManifestName), bytes.NewReader(b))
+}
+
+// keys lists keys matching the configured prefixes.
+func (s *backupService) keys(ctx context.Context) ([]string, error) {
+	keys, err := s.kv.ListKeys(ctx)
+	if err != nil || len(s.options.Prefixes) == 0 {
+		return keys, err
+	}
+	return slices.DeleteFunc(keys, func(key string) bool {
+		return !slices.ContainsFunc(s.options.Prefixes, func(prefix string) bool {
+			return strings.HasPrefix(key, prefix)
+		})
+	}), nil
+}
+
+func (s *backupService) Backup(ctx context.Context) (*BackupFile, error) {
+	keys, err := s.keys(ctx)
+	if err != nil {
+		slog.Error("failed to list keys for backup", "error", err)
+		return nil, err
+	}
+	if err = os.MkdirAll(s.options.Dir, 0o700); err != nil {
+		slog.Error("failed to create backup directory", "dir", s.options.Dir, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to create backup directory"}
+	}
+
+	now := time.Now()
+	backup := BackupFile{
+		Name:      backupFilePrefix + now.Format("20060102-150405") + backupFileSuffix,
+		CreatedAt: now.Format(time.DateTime),
+		Prefixes:  s.options.Prefixes,
+		Keys:      len(keys),
+		ACL:       s.options.ACL,
+		Encrypted: s.options.Recipient != nil,
+	}
+	if backup.Encrypted {
+		backup.Name += encrypt.Ext
+	}
+	path := filepath.Join(s.options.Dir, backup.Name)
+
+	// the archive is streamed into the file and hashed on the fly
+	pr, pw := io.Pipe()
+	exportErr := make(chan error, 1)
+	go func() {
+		err := s.export(ctx, keys, pw)
+		pw.CloseWithError(err)
+		exportErr <- err
+	}()
+	h := sha256.New()
+	err = writeFileAtomically(path, io.TeeReader(pr, h))
+	// unblock the export if the file could not be written
+	pr.CloseWithError(err)
+	if err := <-exportErr; err != nil {
+		slog.Error("failed to export for backup", "error", err)
+		return nil, err
+	}
+	if err != nil {
+		slog.Error("failed to write backup", "name", backup.Name, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to write backup"}
+	}
+	if fi, err := os.Stat(path); err == nil {
+		backup.Size = fi.Size()
+	}
+	backup.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	manifest, err := s.readManifest()
+	if err != nil {
+		slog.Error("failed to read backup manifest", "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read backup manifest"}
+	}
+	manifest.Backups = append(manifest.Backups, backup)
+	if keep := s.options.Keep; keep > 0 && len(manifest.Backups) > keep {
+		for _, old := range manifest.Backups[:len(manifest.Backups)-keep] {
+			if err := os.Remove(filepath.Join(s.options.Dir, old.Name)); err != nil && !os.IsNotExist(err) {
+				slog.Warn("failed to remove old backup", "name", old.Name, "error", err)
+			}
+		}
+		manifest.Backups = slices.Clone(manifest.Backups[len(manifest.Backups)-keep:])
+	}
+	if err = s.writeManifest(manifest); err != nil {
+		slog.Error("failed to write backup manifest", "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to write backup manifest"}
+	}
+	return &backup, nil
+}
+
+// export writes a zip export of keys into w, encrypted if a recipient is configured.
+func (s *backupService) export(ctx context.Context, keys []string, w io.Writer) error {
+	// backups should be restorable as they are, secrets included
+	req := &ExportRequest{Keys: keys, Format: "zip", ACL: s.options.ACL, RevealSecrets: true}
+	if s.options.Recipient == nil {
+		return s.all.Export(ctx, req, w)
+	}
+	ew, err := encrypt.NewRecipientWriter(w, s.options.Recipient)
+	if err != nil {
+		slog.Error("failed to create encrypted writer for backup", "error", err)
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to encrypt backup"}
+	}
+	if err = s.all.Export(ctx, req, ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func (s *backupService) ListBackups(ctx context.Context) ([]BackupFile, error) {
+	s.mu.Lock()
+	manifest, err := s.readManifest()
+	s.mu.Unlock()
+	if err != nil {
+		slog.Error("failed to read backup manifest", "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read backup manifest"}
+	}
+	slices.Reverse(manifest.Backups)
+	return manifest.Backups, nil
+}
+
+func (s *backupService) RestoreBackup(ctx context.Context, name string, req *RestoreBackupRequest) (*ImportResponse, error) {
+	backups, err := s.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// only files in the manifest could be restored
+	i := slices.IndexFunc(backups, func(b BackupFile) bool { return b.Name == name })
+	if i < 0 {
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "backup not found"}
+	}
+	f, err := os.Open(filepath.Join(s.options.Dir, name))
+	if err != nil {
+		slog.Error("failed to open backup", "name", name, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "backup file is missing"}
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		slog.Error("failed to read backup", "name", name, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read backup"}
+	}
+	if hex.EncodeToString(h.Sum(nil)) != backups[i].SHA256 {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "checksum mismatch, the backup file is corrupted"}
+	}
+	resp, err := s.all.Import(ctx, &ImportRequest{
+		Format:       "zip",
+		Dryrun:       req.Dryrun,
+		OnConflict:   req.OnConflict,
+		Overrides:    req.Overrides,
+		RenameSuffix: req.RenameSuffix,
+		File:         f,
+		Size:         size,
+		Passphrase:   req.PrivateKey,
+	})
+	if err != nil || req.Dryrun {
+		return resp, err
+	}
+	err = s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: AuditActionBackupRestore,
+		Target: name,
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", AuditActionBackupRestore, "error", err)
+	}
+	return resp, nil
+}
+
+// RunBackupSchedule runs backups on the cron schedule (e.g. "0 3 * * *") until ctx is done.
+// ctx should contain options with the admin token.
+func RunBackupSchedule(ctx context.Context, s BackupService, schedule string) error {
+	c := cron.New()
+	_, err := c.AddFunc(schedule, func() {
+		backup, err := s.Backup(ctx)
+		if err != nil {
+			slog.Error("scheduled backup failed", "error", err)
+			return
+		}
+		slog.Info("scheduled backup saved", "name", backup.Name, "keys", backup.Keys, "size", backup.Size)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("backup schedule started", "schedule", schedule)
+	c.Start()
+	go func() {
+		<-ctx.Done()
+		c.Stop()
+	}()
+	return nil
+}
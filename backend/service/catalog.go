@@ -0,0 +1,319 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// CatalogService is a read-only view of registered services, nodes and health checks.
+//
+// Every list method also returns the consul index of the primary query,
+// which could be used as the wait index of the next blocking query.
+type CatalogService interface {
+	ListDatacenters(ctx context.Context) ([]string, error)
+	ListServices(ctx context.Context) ([]ServiceSummary, uint64, error)
+	ListServiceInstances(ctx context.Context, service string, options ListServiceInstancesOptions) ([]ServiceInstance, uint64, error)
+	ListNodes(ctx context.Context) ([]NodeSummary, uint64, error)
+	ReadNode(ctx context.Context, node string) (*NodeDetail, uint64, error)
+	ListChecks(ctx context.Context, state string) ([]HealthCheckInfo, uint64, error)
+}
+
+type catalogService struct {
+	catalog repo.CatalogRepo
+}
+
+func NewCatalogService(catalog repo.CatalogRepo) CatalogService {
+	return &catalogService{catalog: catalog}
+}
+
+// responseError converts a failed consul response into a DomainError.
+// It returns nil if the response is ok.
+func responseError[T any](resp *consul.Response[T], err error, process string) error {
+	if err != nil {
+		slog.Error("failed to "+process, "error", err)
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return errPermissionDenied
+	case http.StatusNotFound:
+		return &DomainError{Code: DomainErrorCodeNotFound, Message: "not found"}
+	case http.StatusBadRequest:
+		// mostly caused by invalid filter expressions
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: string(resp.RawBody)}
+	default:
+		slog.Error("unexpected status when trying to "+process, "status", resp.Status, "body", string(resp.RawBody))
+		return errUnknown
+	}
+	if resp.Err != nil {
+		slog.Error("failed to parse response when trying to "+process, "error", resp.Err)
+		return errFailedToParse
+	}
+	return nil
+}
+
+// secondaryContext returns a context for the auxiliary queries of a request.
+// Those queries should neither block nor be filtered.
+func secondaryContext(ctx context.Context) context.Context {
+	q := consul.QueryOptionsFromContext(ctx).Copy()
+	q.WaitIndex, q.WaitTime, q.WaitHash, q.Filter = 0, 0, "", ""
+	return consul.ContextWithQueryOptions(ctx, q)
+}
+
+func lastIndex[T any](resp *consul.Response[T]) uint64 {
+	if resp.Metadata == nil {
+		return 0
+	}
+	return resp.Metadata.LastIndex
+}
+
+func healthStatusRank(status string) int {
+	switch status {
+	case consul.HealthPassing:
+		return 1
+	case consul.HealthWarning:
+		return 2
+	case consul.HealthCritical:
+		return 3
+	case consul.HealthMaint:
+		return 4
+	}
+	return 0
+}
+
+// worseStatus returns the worse one of the two statuses.
+func worseStatus(a, b string) string {
+	if healthStatusRank(b) > healthStatusRank(a) {
+		return b
+	}
+	return a
+}
+
+// checkStatus returns the status of the check, or HealthMaint for checks of the maintenance mode.
+func checkStatus(c *consul.HealthCheck) string {
+	if c.CheckID == consul.NodeMaint || strings.HasPrefix(c.CheckID, consul.ServiceMaintPrefix) {
+		return consul.HealthMaint
+	}
+	return c.Status
+}
+
+func toHealthCheckInfo(c *consul.HealthCheck) HealthCheckInfo {
+	return HealthCheckInfo{
+		Node:        c.Node,
+		CheckID:     c.CheckID,
+		Name:        c.Name,
+		Status:      checkStatus(c),
+		Notes:       c.Notes,
+		Output:      c.Output,
+		ServiceID:   c.ServiceID,
+		ServiceName: c.ServiceName,
+		Type:        c.Type,
+	}
+}
+
+func (s *catalogService) ListDatacenters(ctx context.Context) ([]string, error) {
+	resp, err := s.catalog.ListDatacenters(ctx)
+	if err := responseError(resp, err, "list datacenters"); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *catalogService) ListServices(ctx context.Context) ([]ServiceSummary, uint64, error) {
+	resp, err := s.catalog.ListServices(ctx)
+	if err := responseError(resp, err, "list services"); err != nil {
+		return nil, 0, err
+	}
+	resp2, err := s.catalog.ListChecksByState(secondaryContext(ctx), consul.HealthAny)
+	if err := responseError(resp2, err, "list checks"); err != nil {
+		return nil, 0, err
+	}
+	services := make(map[string]*ServiceSummary, len(resp.Body))
+	for name, tags := range resp.Body {
+		if tags == nil {
+			tags = []string{}
+		}
+		services[name] = &ServiceSummary{Name: name, Tags: tags}
+	}
+	for _, c := range resp2.Body {
+		summary, ok := services[c.ServiceName]
+		if !ok {
+			continue
+		}
+		switch checkStatus(c) {
+		case consul.HealthPassing:
+			summary.Passing++
+		case consul.HealthWarning:
+			summary.Warning++
+		case consul.HealthCritical:
+			summary.Critical++
+		case consul.HealthMaint:
+			summary.Maintenance++
+		}
+	}
+	result := make([]ServiceSummary, 0, len(services))
+	for _, summary := range services {
+		result = append(result, *summary)
+	}
+	slices.SortFunc(result, func(a, b ServiceSummary) int { return cmp.Compare(a.Name, b.Name) })
+	return result, lastIndex(resp), nil
+}
+
+func (s *catalogService) ListServiceInstances(ctx context.Context, service string, options ListServiceInstancesOptions) ([]ServiceInstance, uint64, error) {
+	if options.MergeCentralConfig {
+		q := consul.QueryOptionsFromContext(ctx).Copy()
+		q.MergeCentralConfig = true
+		ctx = consul.ContextWithQueryOptions(ctx, q)
+	}
+	resp, err := s.catalog.ListServiceHealth(ctx, service, options.Tags, options.PassingOnly)
+	if err := responseError(resp, err, "list service instances"); err != nil {
+		return nil, 0, err
+	}
+	instances := make([]ServiceInstance, 0, len(resp.Body))
+	for _, entry := range resp.Body {
+		if entry.Node == nil || entry.Service == nil {
+			continue
+		}
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		instance := ServiceInstance{
+			Node:        entry.Node.Node,
+			NodeAddress: entry.Node.Address,
+			Datacenter:  entry.Node.Datacenter,
+			ID:          entry.Service.ID,
+			Name:        entry.Service.Service,
+			Kind:        entry.Service.Kind,
+			Address:     address,
+			Port:        entry.Service.Port,
+			Tags:        entry.Service.Tags,
+			Meta:        entry.Service.Meta,
+			Status:      consul.HealthPassing,
+			Checks:      make([]HealthCheckInfo, 0, len(entry.Checks)),
+			Proxy:       entry.Service.Proxy,
+		}
+		for _, c := range entry.Checks {
+			instance.Status = worseStatus(instance.Status, checkStatus(c))
+			instance.Checks = append(instance.Checks, toHealthCheckInfo(c))
+		}
+		instances = append(instances, instance)
+	}
+	slices.SortFunc(instances, func(a, b ServiceInstance) int {
+		return cmp.Or(cmp.Compare(a.Node, b.Node), cmp.Compare(a.ID, b.ID))
+	})
+	return instances, lastIndex(resp), nil
+}
+
+func (s *catalogService) ListNodes(ctx context.Context) ([]NodeSummary, uint64, error) {
+	resp, err := s.catalog.ListNodes(ctx)
+	if err := responseError(resp, err, "list nodes"); err != nil {
+		return nil, 0, err
+	}
+	resp2, err := s.catalog.ListChecksByState(secondaryContext(ctx), consul.HealthAny)
+	if err := responseError(resp2, err, "list checks"); err != nil {
+		return nil, 0, err
+	}
+	nodeStatus := make(map[string]string, len(resp.Body))
+	for _, c := range resp2.Body {
+		if c.ServiceID != "" {
+			continue
+		}
+		nodeStatus[c.Node] = worseStatus(nodeStatus[c.Node], checkStatus(c))
+	}
+	nodes := make([]NodeSummary, 0, len(resp.Body))
+	for _, n := range resp.Body {
+		nodes = append(nodes, NodeSummary{
+			Name:       n.Node,
+			ID:         n.ID,
+			Address:    n.Address,
+			Datacenter: n.Datacenter,
+			Meta:       n.Meta,
+			Status:     worseStatus(consul.HealthPassing, nodeStatus[n.Node]),
+		})
+	}
+	return nodes, lastIndex(resp), nil
+}
+
+func (s *catalogService) ReadNode(ctx context.Context, node string) (*NodeDetail, uint64, error) {
+	resp, err := s.catalog.ReadNode(ctx, node)
+	if err := responseError(resp, err, "read node"); err != nil {
+		return nil, 0, err
+	}
+	if resp.Body == nil || resp.Body.Node == nil {
+		// consul returns 200 and null for nodes that do not exist
+		return nil, 0, &DomainError{Code: DomainErrorCodeNotFound, Message: "node not found"}
+	}
+	resp2, err := s.catalog.ListNodeChecks(secondaryContext(ctx), node)
+	if err := responseError(resp2, err, "list node checks"); err != nil {
+		return nil, 0, err
+	}
+	n := resp.Body.Node
+	detail := &NodeDetail{
+		NodeSummary: NodeSummary{
+			Name:       n.Node,
+			ID:         n.ID,
+			Address:    n.Address,
+			Datacenter: n.Datacenter,
+			Meta:       n.Meta,
+			Status:     consul.HealthPassing,
+		},
+		TaggedAddresses: n.TaggedAddresses,
+		Services:        make([]NodeService, 0, len(resp.Body.Services)),
+		Checks:          make([]HealthCheckInfo, 0, len(resp2.Body)),
+	}
+	serviceStatus := make(map[string]string, len(resp.Body.Services))
+	for _, c := range resp2.Body {
+		detail.Checks = append(detail.Checks, toHealthCheckInfo(c))
+		if c.ServiceID == "" {
+			detail.Status = worseStatus(detail.Status, checkStatus(c))
+			continue
+		}
+		serviceStatus[c.ServiceID] = worseStatus(serviceStatus[c.ServiceID], checkStatus(c))
+	}
+	for _, svc := range resp.Body.Services {
+		detail.Services = append(detail.Services, NodeService{
+			ID:      svc.ID,
+			Name:    svc.Service,
+			Kind:    svc.Kind,
+			Address: svc.Address,
+			Port:    svc.Port,
+			Tags:    svc.Tags,
+			Meta:    svc.Meta,
+			Status:  worseStatus(detail.Status, serviceStatus[svc.ID]),
+		})
+	}
+	slices.SortFunc(detail.Services, func(a, b NodeService) int { return cmp.Compare(a.ID, b.ID) })
+	return detail, lastIndex(resp), nil
+}
+
+func (s *catalogService) ListChecks(ctx context.Context, state string) ([]HealthCheckInfo, uint64, error) {
+	switch state {
+	case "":
+		state = consul.HealthAny
+	case consul.HealthAny, consul.HealthPassing, consul.HealthWarning, consul.HealthCritical:
+	default:
+		return nil, 0, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid check state " + state}
+	}
+	resp, err := s.catalog.ListChecksByState(ctx, state)
+	if err := responseError(resp, err, "list checks by state"); err != nil {
+		return nil, 0, err
+	}
+	checks := make([]HealthCheckInfo, 0, len(resp.Body))
+	for _, c := range resp.Body {
+		checks = append(checks, toHealthCheckInfo(c))
+	}
+	return checks, lastIndex(resp), nil
+}
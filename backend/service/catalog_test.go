@@ -0,0 +1,127 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+func TestCheckStatus(t *testing.T) {
+	tests := []struct {
+		checkID, status, want string
+	}{
+		{"serfHealth", consul.HealthPassing, consul.HealthPassing},
+		{"service:web-1", consul.HealthWarning, consul.HealthWarning},
+		{consul.NodeMaint, consul.HealthCritical, consul.HealthMaint},
+		{consul.ServiceMaintPrefix + "web-1", consul.HealthCritical, consul.HealthMaint},
+		{"_service_maintenance", consul.HealthCritical, consul.HealthCritical},
+	}
+	for _, tt := range tests {
+		if got := checkStatus(&consul.HealthCheck{CheckID: tt.checkID, Status: tt.status}); got != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.checkID, got, tt.want)
+		}
+	}
+	if got := worseStatus(consul.HealthCritical, consul.HealthMaint); got != consul.HealthMaint {
+		t.Errorf("maintenance should be worse than critical, got %s", got)
+	}
+	if got := worseStatus(consul.HealthWarning, consul.HealthPassing); got != consul.HealthWarning {
+		t.Errorf("warning should be worse than passing, got %s", got)
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *consul.Response[[]string]
+		err  error
+		want DomainErrorCode
+	}{
+		{"ok", &consul.Response[[]string]{Status: http.StatusOK}, nil, ""},
+		{"connection", nil, errors.New("connection refused"), errFailedToConnectConsul.Code},
+		{"forbidden", &consul.Response[[]string]{Status: http.StatusForbidden}, nil, DomainErrorCodePermissionDenied},
+		{"not found", &consul.Response[[]string]{Status: http.StatusNotFound}, nil, DomainErrorCodeNotFound},
+		{"bad filter", &consul.Response[[]string]{Status: http.StatusBadRequest, RawBody: []byte("invalid filter")}, nil, DomainErrorCodeInvalidInput},
+		{"server error", &consul.Response[[]string]{Status: http.StatusInternalServerError}, nil, DomainErrorCodeUnknown},
+		{"invalid body", &consul.Response[[]string]{Status: http.StatusOK, Err: errors.New("invalid json")}, nil, errFailedToParse.Code},
+	}
+	for _, tt := range tests {
+		err := responseError(tt.resp, tt.err, "list")
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		var derr *DomainError
+		if !errors.As(err, &derr) || derr.Code != tt.want {
+			t.Errorf("%s: err = %v, want code %s", tt.name, err, tt.want)
+		}
+	}
+	err := responseError(&consul.Response[[]string]{Status: http.StatusBadRequest, RawBody: []byte("invalid filter")}, nil, "list")
+	if err.Error() != "invalid filter" {
+		t.Errorf("message of a bad request = %q", err)
+	}
+}
+
+// fakeCatalogRepo records query options of the last request.
+type fakeCatalogRepo struct {
+	repo.CatalogRepo
+	entries []*consul.ServiceEntry
+	query   *consul.QueryOptions
+}
+
+func (f *fakeCatalogRepo) ListServiceHealth(ctx context.Context, service string, tags []string, passingOnly bool) (*consul.Response[[]*consul.ServiceEntry], error) {
+	f.query = consul.QueryOptionsFromContext(ctx)
+	return &consul.Response[[]*consul.ServiceEntry]{Status: http.StatusOK, Body: f.entries, Metadata: &consul.Metadata{LastIndex: 7}}, nil
+}
+
+func TestListServiceInstances(t *testing.T) {
+	node := &consul.Node{Node: "n1", Address: "10.0.0.1"}
+	f := &fakeCatalogRepo{entries: []*consul.ServiceEntry{
+		{Node: node, Service: &consul.AgentService{ID: "web-2", Service: "web"}, Checks: []*consul.HealthCheck{
+			{CheckID: "serfHealth", Status: consul.HealthPassing},
+			{CheckID: consul.ServiceMaintPrefix + "web-2", Status: consul.HealthCritical},
+		}},
+		{Node: node, Service: &consul.AgentService{ID: "web-1", Service: "web", Address: "10.0.1.1"}, Checks: []*consul.HealthCheck{
+			{CheckID: "service:web-1", Status: consul.HealthWarning},
+		}},
+	}}
+	s := NewCatalogService(f)
+	q := &consul.QueryOptions{Filter: `Service.Tags contains "v1"`, Near: "_agent", NodeMeta: map[string]string{"rack": "a"}, AllowStale: true, WaitIndex: 6}
+	ctx := consul.ContextWithQueryOptions(context.Background(), q)
+
+	instances, index, err := s.ListServiceInstances(ctx, "web", ListServiceInstancesOptions{MergeCentralConfig: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 7 || len(instances) != 2 {
+		t.Fatalf("instances = %+v, index = %d", instances, index)
+	}
+	if instances[0].ID != "web-1" || instances[0].Status != consul.HealthWarning || instances[0].Address != "10.0.1.1" {
+		t.Errorf("web-1 = %+v", instances[0])
+	}
+	if instances[1].Status != consul.HealthMaint || instances[1].Address != node.Address || instances[1].Checks[1].Status != consul.HealthMaint {
+		t.Errorf("web-2 = %+v", instances[1])
+	}
+	// options of the request are passed through, and the merge is added to a copy
+	if f.query.Filter != q.Filter || f.query.Near != q.Near || f.query.NodeMeta["rack"] != "a" || !f.query.AllowStale || f.query.WaitIndex != 6 || !f.query.MergeCentralConfig {
+		t.Errorf("query options = %+v", f.query)
+	}
+	if q.MergeCentralConfig {
+		t.Error("query options of the request changed")
+	}
+
+	// auxiliary queries neither block nor filter
+	secondary := consul.QueryOptionsFromContext(secondaryContext(ctx))
+	if secondary.WaitIndex != 0 || secondary.Filter != "" || !secondary.AllowStale || secondary.Near != q.Near {
+		t.Errorf("secondary query options = %+v", secondary)
+	}
+}
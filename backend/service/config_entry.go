@@ -0,0 +1,478 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+	"github.com/goccy/go-yaml"
+)
+
+// ConfigEntryKinds lists kinds supported by consee.
+// Kinds are ordered by their dependencies,
+// e.g. service-defaults should be written before routers and splitters.
+var ConfigEntryKinds = []string{
+	consul.ProxyDefaults,
+	consul.MeshConfig,
+	consul.ServiceDefaults,
+	consul.ServiceResolver,
+	consul.ServiceSplitter,
+	consul.ServiceRouter,
+	consul.IngressGateway,
+	consul.TerminatingGateway,
+	consul.ServiceIntentions,
+}
+
+// ConfigEntryService manages config entries of the mesh.
+// Entries are validated per kind before they are written.
+type ConfigEntryService interface {
+	ListConfigEntries(ctx context.Context, kind string) ([]ConfigEntry, error)
+	ReadConfigEntry(ctx context.Context, kind, name string) (ConfigEntry, error)
+	// WriteConfigEntry writes the entry with check-and-set.
+	WriteConfigEntry(ctx context.Context, req *WriteConfigEntryRequest) error
+	// ApplyConfigEntry writes the entry unconditionally.
+	ApplyConfigEntry(ctx context.Context, entry ConfigEntry) error
+	DeleteConfigEntry(ctx context.Context, kind, name string) error
+	// DiffConfigEntry compares the current entry with a proposed version in json, yaml or hcl.
+	DiffConfigEntry(ctx context.Context, kind, name string, req *DiffConfigEntryRequest) (*ConfigEntryDiff, error)
+}
+
+type configEntryService struct {
+	configEntry repo.ConfigEntryRepo
+}
+
+func NewConfigEntryService(configEntry repo.ConfigEntryRepo) ConfigEntryService {
+	return &configEntryService{configEntry: configEntry}
+}
+
+func invalidConfigEntry(format string, a ...any) error {
+	return &DomainError{Code: DomainErrorCodeInvalidInput, Message: fmt.Sprintf(format, a...)}
+}
+
+func checkConfigEntryKind(kind string) error {
+	if !slices.Contains(ConfigEntryKinds, kind) {
+		return invalidConfigEntry("unsupported config entry kind %q", kind)
+	}
+	return nil
+}
+
+// parseConfigEntry parses a config entry in json, yaml or hcl.
+func parseConfigEntry(format, content string) (ConfigEntry, error) {
+	var (
+		entry ConfigEntry
+		err   error
+	)
+	switch format {
+	case "json":
+		err = json.Unmarshal([]byte(content), &entry)
+	case "yaml":
+		err = yaml.Unmarshal([]byte(content), &entry)
+	case "hcl":
+		entry, err = ParseHCLObject(content)
+	default:
+		return nil, invalidConfigEntry("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, invalidConfigEntry("failed to parse %s: %s", format, err.Error())
+	}
+	if entry == nil {
+		return nil, invalidConfigEntry("config entry should be an object")
+	}
+	// round trip through json so that values have the same types as entries read from consul
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return nil, invalidConfigEntry("failed to parse %s: %s", format, err.Error())
+	}
+	entry = ConfigEntry{}
+	json.Unmarshal(b, &entry)
+	return entry, nil
+}
+
+// renderConfigEntry renders the entry as indented json with sorted keys.
+// Fields maintained by consul are omitted so that only user changes are compared.
+func renderConfigEntry(entry ConfigEntry) string {
+	if entry == nil {
+		return ""
+	}
+	e := make(ConfigEntry, len(entry))
+	for k, v := range entry {
+		switch k {
+		case "CreateIndex", "ModifyIndex", "Hash":
+			continue
+		}
+		e[k] = v
+	}
+	b, _ := json.MarshalIndent(e, "", "  ")
+	return string(b) + "\n"
+}
+
+func stringField(entry map[string]any, field string) (string, error) {
+	v, ok := entry[field]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", invalidConfigEntry("%s should be a string", field)
+	}
+	return s, nil
+}
+
+func objectField(entry map[string]any, field string) (map[string]any, error) {
+	v, ok := entry[field]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, invalidConfigEntry("%s should be an object", field)
+	}
+	return m, nil
+}
+
+// listField returns elements of a list of objects.
+// A single object is taken as a list of one object, and the field is rewritten as such,
+// since a HCL block that is not repeated is parsed as an object.
+func listField(entry map[string]any, field string) ([]map[string]any, error) {
+	v, ok := entry[field]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	if m, ok := v.(map[string]any); ok {
+		entry[field] = []any{m}
+		return []map[string]any{m}, nil
+	}
+	l, ok := v.([]any)
+	if !ok {
+		return nil, invalidConfigEntry("%s should be a list", field)
+	}
+	objects := make([]map[string]any, 0, len(l))
+	for i, e := range l {
+		m, ok := e.(map[string]any)
+		if !ok {
+			return nil, invalidConfigEntry("%s[%d] should be an object", field, i)
+		}
+		objects = append(objects, m)
+	}
+	return objects, nil
+}
+
+func enumField(entry map[string]any, field string, values ...string) error {
+	s, err := stringField(entry, field)
+	if err != nil {
+		return err
+	}
+	if s != "" && !slices.Contains(values, s) {
+		return invalidConfigEntry("%s should be one of %s", field, strings.Join(values, ", "))
+	}
+	return nil
+}
+
+func durationField(entry map[string]any, field string) error {
+	s, err := stringField(entry, field)
+	if err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	if _, err = time.ParseDuration(s); err != nil {
+		return invalidConfigEntry("%s should be a duration like \"5s\"", field)
+	}
+	return nil
+}
+
+// validateConfigEntry checks the fields that are commonly misconfigured for each kind.
+// Consul does a full validation when the entry is written.
+func validateConfigEntry(entry ConfigEntry) error {
+	kind, err := stringField(entry, "Kind")
+	if err != nil {
+		return err
+	}
+	if err = checkConfigEntryKind(kind); err != nil {
+		return err
+	}
+	name, err := stringField(entry, "Name")
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return invalidConfigEntry("Name is required")
+	}
+
+	switch kind {
+	case consul.ProxyDefaults:
+		if name != consul.ProxyConfigGlobal {
+			return invalidConfigEntry("Name of proxy-defaults should be %q", consul.ProxyConfigGlobal)
+		}
+		if _, err = objectField(entry, "Config"); err != nil {
+			return err
+		}
+		return enumField(entry, "Mode", "direct", "transparent")
+	case consul.MeshConfig:
+		if name != consul.MeshConfigMesh {
+			return invalidConfigEntry("Name of mesh should be %q", consul.MeshConfigMesh)
+		}
+	case consul.ServiceDefaults:
+		if err = enumField(entry, "Protocol", "tcp", "http", "http2", "grpc"); err != nil {
+			return err
+		}
+		return enumField(entry, "Mode", "direct", "transparent")
+	case consul.ServiceResolver:
+		return validateServiceResolver(entry)
+	case consul.ServiceSplitter:
+		return validateServiceSplitter(entry)
+	case consul.ServiceRouter:
+		return validateServiceRouter(entry)
+	case consul.ServiceIntentions:
+		return validateServiceIntentions(entry)
+	}
+	return nil
+}
+
+func validateServiceResolver(entry ConfigEntry) error {
+	subsets, err := objectField(entry, "Subsets")
+	if err != nil {
+		return err
+	}
+	for name, subset := range subsets {
+		if _, ok := subset.(map[string]any); !ok {
+			return invalidConfigEntry("Subsets.%s should be an object", name)
+		}
+	}
+	defaultSubset, err := stringField(entry, "DefaultSubset")
+	if err != nil {
+		return err
+	}
+	if _, ok := subsets[defaultSubset]; defaultSubset != "" && !ok {
+		return invalidConfigEntry("DefaultSubset %q is not defined in Subsets", defaultSubset)
+	}
+	if err = durationField(entry, "ConnectTimeout"); err != nil {
+		return err
+	}
+	if err = durationField(entry, "RequestTimeout"); err != nil {
+		return err
+	}
+	redirect, err := objectField(entry, "Redirect")
+	if err != nil {
+		return err
+	}
+	failover, err := objectField(entry, "Failover")
+	if err != nil {
+		return err
+	}
+	if redirect != nil && (subsets != nil || failover != nil) {
+		return invalidConfigEntry("Redirect cannot be used together with Subsets or Failover")
+	}
+	return nil
+}
+
+func validateServiceSplitter(entry ConfigEntry) error {
+	splits, err := listField(entry, "Splits")
+	if err != nil {
+		return err
+	}
+	if len(splits) == 0 {
+		return invalidConfigEntry("Splits should not be empty")
+	}
+	var sum float64
+	for i, split := range splits {
+		weight, ok := split["Weight"].(float64)
+		if !ok || weight < 0 || weight > 100 {
+			return invalidConfigEntry("Splits[%d].Weight should be a number between 0 and 100", i)
+		}
+		sum += weight
+	}
+	// consul allows weights with 2 decimal places
+	if math.Abs(sum-100) > 0.01 {
+		return invalidConfigEntry("sum of weights should be 100, got %v", sum)
+	}
+	return nil
+}
+
+func validateServiceRouter(entry ConfigEntry) error {
+	routes, err := listField(entry, "Routes")
+	if err != nil {
+		return err
+	}
+	for i, route := range routes {
+		match, err := objectField(route, "Match")
+		if err != nil {
+			return invalidConfigEntry("Routes[%d]: %s", i, err.Error())
+		}
+		if match != nil {
+			http, err := objectField(match, "HTTP")
+			if err != nil {
+				return invalidConfigEntry("Routes[%d].Match: %s", i, err.Error())
+			}
+			paths := 0
+			for _, field := range []string{"PathExact", "PathPrefix", "PathRegex"} {
+				s, err := stringField(http, field)
+				if err != nil {
+					return invalidConfigEntry("Routes[%d].Match.HTTP: %s", i, err.Error())
+				}
+				if s != "" {
+					paths++
+				}
+				if s != "" && field != "PathRegex" && !strings.HasPrefix(s, "/") {
+					return invalidConfigEntry("Routes[%d].Match.HTTP.%s should begin with '/'", i, field)
+				}
+			}
+			if paths > 1 {
+				return invalidConfigEntry("Routes[%d].Match.HTTP should have at most one of PathExact, PathPrefix and PathRegex", i)
+			}
+		}
+		destination, err := objectField(route, "Destination")
+		if err != nil {
+			return invalidConfigEntry("Routes[%d]: %s", i, err.Error())
+		}
+		for _, field := range []string{"RequestTimeout", "IdleTimeout"} {
+			if err = durationField(destination, field); err != nil {
+				return invalidConfigEntry("Routes[%d].Destination: %s", i, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func validateServiceIntentions(entry ConfigEntry) error {
+	sources, err := listField(entry, "Sources")
+	if err != nil {
+		return err
+	}
+	for i, source := range sources {
+		name, err := stringField(source, "Name")
+		if err != nil || name == "" {
+			return invalidConfigEntry("Sources[%d].Name is required", i)
+		}
+		action, err := stringField(source, "Action")
+		if err != nil {
+			return invalidConfigEntry("Sources[%d]: %s", i, err.Error())
+		}
+		_, hasPermissions := source["Permissions"]
+		if action != "" && hasPermissions {
+			return invalidConfigEntry("Sources[%d] should not have both Action and Permissions", i)
+		}
+		if action == "" && !hasPermissions {
+			return invalidConfigEntry("Sources[%d] should have either Action or Permissions", i)
+		}
+		if action != "" && !validIntentionAction(action) {
+			return invalidConfigEntry("Sources[%d].Action should be allow or deny", i)
+		}
+	}
+	return nil
+}
+
+func (s *configEntryService) ListConfigEntries(ctx context.Context, kind string) ([]ConfigEntry, error) {
+	if err := checkConfigEntryKind(kind); err != nil {
+		return nil, err
+	}
+	resp, err := s.configEntry.ListConfigEntries(ctx, kind)
+	if err := responseError(resp, err, "list config entries"); err != nil {
+		return nil, err
+	}
+	entries := make([]ConfigEntry, 0, len(resp.Body))
+	for _, e := range resp.Body {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b ConfigEntry) int {
+		return cmp.Compare(consul.ConfigEntry(a).Name(), consul.ConfigEntry(b).Name())
+	})
+	return entries, nil
+}
+
+func (s *configEntryService) ReadConfigEntry(ctx context.Context, kind, name string) (ConfigEntry, error) {
+	if err := checkConfigEntryKind(kind); err != nil {
+		return nil, err
+	}
+	resp, err := s.configEntry.ReadConfigEntry(ctx, kind, name)
+	if err := responseError(resp, err, "read config entry"); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *configEntryService) WriteConfigEntry(ctx context.Context, req *WriteConfigEntryRequest) error {
+	if err := validateConfigEntry(req.Entry); err != nil {
+		return err
+	}
+	resp, err := s.configEntry.CASConfigEntry(ctx, req.Entry, req.Index)
+	if err := responseError(resp, err, "write config entry"); err != nil {
+		return err
+	}
+	if !resp.Body {
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "config entry has been modified by others, please reload it"}
+	}
+	return nil
+}
+
+func (s *configEntryService) ApplyConfigEntry(ctx context.Context, entry ConfigEntry) error {
+	if err := validateConfigEntry(entry); err != nil {
+		return err
+	}
+	resp, err := s.configEntry.WriteConfigEntry(ctx, entry)
+	return responseError(resp, err, "write config entry")
+}
+
+func (s *configEntryService) DeleteConfigEntry(ctx context.Context, kind, name string) error {
+	if err := checkConfigEntryKind(kind); err != nil {
+		return err
+	}
+	resp, err := s.configEntry.DeleteConfigEntry(ctx, kind, name)
+	return responseError(resp, err, "delete config entry")
+}
+
+func (s *configEntryService) DiffConfigEntry(ctx context.Context, kind, name string, req *DiffConfigEntryRequest) (*ConfigEntryDiff, error) {
+	if err := checkConfigEntryKind(kind); err != nil {
+		return nil, err
+	}
+	proposed, err := parseConfigEntry(req.Format, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	// kind and name could be omitted in the proposed version
+	if _, ok := proposed["Kind"]; !ok {
+		proposed["Kind"] = kind
+	}
+	if _, ok := proposed["Name"]; !ok {
+		proposed["Name"] = name
+	}
+	if proposed["Kind"] != kind || proposed["Name"] != name {
+		return nil, invalidConfigEntry("kind and name of the proposed entry should be %s/%s", kind, name)
+	}
+
+	var current consul.ConfigEntry
+	resp, err := s.configEntry.ReadConfigEntry(ctx, kind, name)
+	err = responseError(resp, err, "read config entry")
+	if err != nil && err.(*DomainError).Code != DomainErrorCodeNotFound {
+		return nil, err
+	}
+	if err == nil {
+		current = resp.Body
+	}
+
+	// validate before rendering, since validation normalizes lists of a single HCL block
+	validateErr := validateConfigEntry(proposed)
+	diff := &ConfigEntryDiff{
+		Current:  renderConfigEntry(current),
+		Proposed: renderConfigEntry(proposed),
+		Index:    current.ModifyIndex(),
+	}
+	diff.Lines = diffLines(diff.Current, diff.Proposed)
+	diff.Changed = hasChanges(diff.Lines)
+	if validateErr != nil {
+		diff.Error = validateErr.Error()
+	}
+	return diff, nil
+}
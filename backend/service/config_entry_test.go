@@ -0,0 +1,48 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import "testing"
+
+func TestConfigEntrySingleHCLBlock(t *testing.T) {
+	entry, err := parseConfigEntry("hcl", `
+Kind = "service-router"
+Name = "web"
+Routes {
+  Match {
+    HTTP {
+      PathPrefix = "/admin"
+    }
+  }
+  Destination {
+    Service = "admin"
+  }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validateConfigEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	routes, ok := entry["Routes"].([]any)
+	if !ok || len(routes) != 1 {
+		t.Fatalf("Routes should be a list of one route, got %#v", entry["Routes"])
+	}
+
+	entry, _ = parseConfigEntry("hcl", `
+Kind = "service-router"
+Name = "web"
+Routes {
+  Match {
+    HTTP {
+      PathPrefix = "admin"
+    }
+  }
+}
+`)
+	if err = validateConfigEntry(entry); err == nil {
+		t.Error("a single route should still be validated")
+	}
+}
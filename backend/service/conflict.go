@@ -0,0 +1,113 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+)
+
+// the suffix is also used in policy names, so it follows their rules
+var renameSuffixRegexp = regexp.MustCompile(`^[A-Za-z0-9\-_]{1,32}$`)
+
+// conflictResolver decides how conflicts are resolved during import, item by item.
+type conflictResolver struct {
+	onConflict OnConflictPolicy
+	overrides  map[string]OnConflictPolicy
+	suffix     string
+	// indexes are ModifyIndexes recorded in the archive
+	indexes map[string]uint64
+}
+
+func validOnConflictPolicy(p OnConflictPolicy) bool {
+	switch p {
+	case OnConflictPolicySkip, OnConflictPolicyReplace, OnConflictPolicyRename, OnConflictPolicyNewer:
+		return true
+	}
+	return false
+}
+
+func newConflictResolver(req *ImportRequest) (*conflictResolver, error) {
+	c := &conflictResolver{
+		onConflict: req.OnConflict,
+		overrides:  make(map[string]OnConflictPolicy, len(req.Overrides)),
+		suffix:     req.RenameSuffix,
+	}
+	if c.onConflict == "" {
+		c.onConflict = OnConflictPolicySkip
+	}
+	if !validOnConflictPolicy(c.onConflict) {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "on_conflict should be one of skip, replace, rename and newer"}
+	}
+	for _, o := range req.Overrides {
+		if !validOnConflictPolicy(o.OnConflict) {
+			return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid on_conflict of " + ImportItemKey(o.Kind, o.Param)}
+		}
+		c.overrides[ImportItemKey(o.Kind, o.Param)] = o.OnConflict
+	}
+	if c.suffix == "" {
+		c.suffix = DefaultRenameSuffix
+	}
+	if !renameSuffixRegexp.MatchString(c.suffix) {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "rename suffix should only contain letters, digits, '-' and '_'"}
+	}
+	return c, nil
+}
+
+func (c *conflictResolver) policy(kind, param string) OnConflictPolicy {
+	if p, ok := c.overrides[ImportItemKey(kind, param)]; ok {
+		return p
+	}
+	return c.onConflict
+}
+
+// resolve decides how the conflict of an item is resolved.
+// existingIndex is the ModifyIndex of the existing item, used by the newer policy.
+// The resolution of the returned item is one of skip, replace and rename,
+// and Cause explains why the policy is not applied as is.
+func (c *conflictResolver) resolve(kind, param string, existingIndex uint64) ImportResponseItem {
+	item := ImportResponseItem{Kind: kind, Param: param, Resolution: c.policy(kind, param)}
+	switch item.Resolution {
+	case OnConflictPolicyRename, OnConflictPolicyNewer:
+		// intentions and config entries are identified by what they configure,
+		// and consul does not keep ModifyIndex of them in the archive
+		if kind != "kv" && kind != "policy" && kind != "token" {
+			item.Cause = string(item.Resolution) + " is not supported for " + kind + ", skipped"
+			item.Resolution = OnConflictPolicySkip
+			return item
+		}
+	}
+	if item.Resolution != OnConflictPolicyNewer {
+		return item
+	}
+	archived := c.indexes[ImportItemKey(kind, param)]
+	switch {
+	case archived == 0:
+		item.Resolution, item.Cause = OnConflictPolicySkip, "no ModifyIndex in the archive, skipped"
+	case archived > existingIndex:
+		item.Resolution, item.Cause = OnConflictPolicyReplace, "the archived one is newer"
+	default:
+		item.Resolution, item.Cause = OnConflictPolicySkip, "the existing one is newer"
+	}
+	return item
+}
+
+// renamedKey appends the suffix to the last segment of key, keeping the trailing slash of folders.
+func (c *conflictResolver) renamedKey(key string) string {
+	if strings.HasSuffix(key, "/") {
+		return strings.TrimSuffix(key, "/") + c.suffix + "/"
+	}
+	return key + c.suffix
+}
+
+// renamedTokenName is the name of the token created by the rename policy.
+// Tokens without names are named after their accessor ids.
+func (c *conflictResolver) renamedTokenName(name, id string) string {
+	if name == "" {
+		name = id
+	}
+	return name + c.suffix
+}
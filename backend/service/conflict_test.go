@@ -0,0 +1,114 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+)
+
+type fakeACLService struct {
+	ACLService
+	rules map[string]string
+}
+
+func (f *fakeACLService) ReadPolicy(ctx context.Context, name string) (*ReadPolicyResponse, error) {
+	rules, ok := f.rules[name]
+	if !ok {
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "policy not found"}
+	}
+	return &ReadPolicyResponse{Name: name, Rules: rules, ModifyIndex: 1}, nil
+}
+
+func testArchive(t *testing.T, files map[string]string, meta *ExportMetadata) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	b, _ := json.Marshal(meta)
+	files["metadata.json"] = string(b)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	policy, _ := json.Marshal(CreatePolicyRequest{Name: "app", Rules: `key_prefix "app/" { policy = "read" }`})
+	flags := uint64(7)
+	archive := testArchive(t, map[string]string{
+		"kv/" + b64("same") + "/latest":    "v",
+		"kv/" + b64("changed") + "/latest": "new",
+		"kv/" + b64("changed") + "/v1":     "old",
+		"kv/" + b64("created") + "/latest": "v",
+		"kv/" + b64("created") + "/v1":     "old",
+		"policies/" + b64("app"):           string(policy),
+	}, &ExportMetadata{
+		Keys: []ExportedKVMeta{
+			{Name: "same", ValueType: "plaintext"},
+			{Name: "changed", ValueType: "plaintext", HistoryVersions: []string{"v1"}},
+			{Name: "created", ValueType: "plaintext", HistoryVersions: []string{"v1"}, Flags: &flags},
+		},
+		Policies: []string{"app"},
+	})
+	kv := &fakeKVService{values: map[string]string{"same": "v", "changed": "current"}, flags: map[string]uint64{}}
+	acl := &fakeACLService{rules: map[string]string{"app": `key_prefix "app/" { policy = "read" }`}}
+	admin := &fakeAdminService{}
+	s := NewA2(kv, acl, admin, nil, nil, "")
+	req := &ImportRequest{Format: "zip", Dryrun: true, File: archive, Size: archive.Size()}
+
+	// identical items are skipped by the import, so they are not conflicts of the dry run
+	resp, err := s.Import(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Conflicts) != 1 || resp.Conflicts[0].Param != "changed" || resp.Conflicts[0].Resolution != OnConflictPolicySkip {
+		t.Errorf("unexpected conflicts of the dry run: %+v", resp.Conflicts)
+	}
+	if len(resp.Successes) != 1 || resp.Successes[0].Param != "created" {
+		t.Errorf("unexpected successes of the dry run: %+v", resp.Successes)
+	}
+
+	req.Dryrun = false
+	if resp, err = s.Import(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if kv.values["changed"] != "current" || kv.values["created"] != "v" {
+		t.Errorf("unexpected values after import: %v", kv.values)
+	}
+	if kv.flags["created"] != flags {
+		t.Errorf("flags of the imported key = %d, want %d", kv.flags["created"], flags)
+	}
+	if _, got, err := readArchiveKVs(req); err != nil || got["created"] != flags {
+		t.Errorf("flags of the archive = %v, %v", got, err)
+	}
+	// history of the skipped key is not imported into the existing one
+	if len(admin.history) != 1 || admin.history[0] != b64("created")+":v1" {
+		t.Errorf("unexpected history versions: %v", admin.history)
+	}
+}
+
+func TestRenamedTokenName(t *testing.T) {
+	c := &conflictResolver{suffix: DefaultRenameSuffix}
+	if name := c.renamedTokenName("ci", "id"); name != "ci"+DefaultRenameSuffix {
+		t.Errorf("name = %q", name)
+	}
+	if name := c.renamedTokenName("", "id"); name != "id"+DefaultRenameSuffix {
+		t.Errorf("tokens without names should be named after their ids, got %q", name)
+	}
+}
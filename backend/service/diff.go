@@ -0,0 +1,88 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+)
+
+const (
+	DiffOpEqual  = " "
+	DiffOpInsert = "+"
+	DiffOpDelete = "-"
+)
+
+// diffLines compares two texts line by line based on their longest common subsequence.
+// Deleted lines are placed before inserted lines in every changed hunk.
+func diffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+	// trim common prefix and suffix to reduce the size of the table
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	mx, my := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+
+	// lcs[i][j] is the length of the lcs of mx[i:] and my[j:]
+	lcs := make([][]int, len(mx)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(my)+1)
+	}
+	for i := len(mx) - 1; i >= 0; i-- {
+		for j := len(my) - 1; j >= 0; j-- {
+			if mx[i] == my[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]DiffLine, 0, len(x)+len(y))
+	for _, l := range x[:prefix] {
+		lines = append(lines, DiffLine{Op: DiffOpEqual, Text: l})
+	}
+	i, j := 0, 0
+	for i < len(mx) || j < len(my) {
+		switch {
+		case i < len(mx) && j < len(my) && mx[i] == my[j]:
+			lines = append(lines, DiffLine{Op: DiffOpEqual, Text: mx[i]})
+			i++
+			j++
+		case j == len(my) || i < len(mx) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Op: DiffOpDelete, Text: mx[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: DiffOpInsert, Text: my[j]})
+			j++
+		}
+	}
+	for _, l := range x[len(x)-suffix:] {
+		lines = append(lines, DiffLine{Op: DiffOpEqual, Text: l})
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// hasChanges reports whether there is any inserted or deleted line.
+func hasChanges(lines []DiffLine) bool {
+	for _, l := range lines {
+		if l.Op != DiffOpEqual {
+			return true
+		}
+	}
+	return false
+}
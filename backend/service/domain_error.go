@@ -12,8 +12,11 @@ const (
 	DomainErrorCodeInvalidInput     DomainErrorCode = "INVALID_INPUT"
 	DomainErrorCodeInternalError    DomainErrorCode = "INTERNAL_ERROR"
 	DomainErrorCodePermissionDenied DomainErrorCode = "PERMISSION_DENIED"
-	DomainErrorCodeMultiple         DomainErrorCode = "MULTIPLE_ERRORS_OCCURED"
-	DomainErrorCodeUnknown          DomainErrorCode = "UNKNOWN"
+	DomainErrorCodeConflict         DomainErrorCode = "CONFLICT"
+	// DomainErrorCodePassphraseRequired means the input is encrypted and should be retried with a passphrase.
+	DomainErrorCodePassphraseRequired DomainErrorCode = "PASSPHRASE_REQUIRED"
+	DomainErrorCodeMultiple           DomainErrorCode = "MULTIPLE_ERRORS_OCCURED"
+	DomainErrorCodeUnknown            DomainErrorCode = "UNKNOWN"
 )
 
 type DomainError struct {
@@ -26,11 +29,12 @@ func (e DomainError) Error() string {
 }
 
 var (
-	errNotImplemented        = &DomainError{Code: DomainErrorCodeNotImplemented, Message: "service function not implemented"}
-	errFailedToConnectConsul = &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to connect to consul"}
-	errPermissionDenied      = &DomainError{Code: DomainErrorCodePermissionDenied, Message: "permission denied"}
-	errAdminPermissionDenied = &DomainError{Code: DomainErrorCodeInternalError, Message: "permission denied"}
-	errFailedToParse         = &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to parse value"}
-	errNotAdmin              = &DomainError{Code: DomainErrorCodePermissionDenied, Message: "token should have admin permission"}
-	errUnknown               = &DomainError{Code: DomainErrorCodeUnknown, Message: "unknown error"}
+	errNotImplemented         = &DomainError{Code: DomainErrorCodeNotImplemented, Message: "service function not implemented"}
+	errFailedToConnectConsul  = &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to connect to consul"}
+	errPermissionDenied       = &DomainError{Code: DomainErrorCodePermissionDenied, Message: "permission denied"}
+	errAdminPermissionDenied  = &DomainError{Code: DomainErrorCodeInternalError, Message: "permission denied"}
+	errFailedToAccessMetadata = &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to access metadata"}
+	errFailedToParse          = &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to parse value"}
+	errNotAdmin               = &DomainError{Code: DomainErrorCodePermissionDenied, Message: "token should have admin permission"}
+	errUnknown                = &DomainError{Code: DomainErrorCodeUnknown, Message: "unknown error"}
 )
@@ -0,0 +1,225 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// fakeKVRepo keeps keys in memory with consul-like modify indexes.
+type fakeKVRepo struct {
+	repo.KVRepo
+	pairs map[string]*consul.KVPair
+	index uint64
+}
+
+// put writes the key, keeping its lock like consul.
+func (f *fakeKVRepo) put(key, value string) {
+	f.index++
+	pair := &consul.KVPair{Key: key, Value: []byte(value), ModifyIndex: f.index}
+	if old, ok := f.pairs[key]; ok {
+		pair.Session, pair.LockIndex = old.Session, old.LockIndex
+	}
+	f.pairs[key] = pair
+}
+
+// List lists pairs sorted by key like consul.
+func (f *fakeKVRepo) List(ctx context.Context, prefix string) (*consul.Response[[]*consul.KVPair], error) {
+	resp := &consul.Response[[]*consul.KVPair]{Status: http.StatusOK}
+	for key, pair := range f.pairs {
+		if strings.HasPrefix(key, prefix) {
+			resp.Body = append(resp.Body, pair)
+		}
+	}
+	slices.SortFunc(resp.Body, func(a, b *consul.KVPair) int { return strings.Compare(a.Key, b.Key) })
+	return resp, nil
+}
+
+func (f *fakeKVRepo) ListKeys(ctx context.Context, prefix, sep string) (*consul.Response[[]string], error) {
+	resp := &consul.Response[[]string]{Status: http.StatusOK}
+	for key := range f.pairs {
+		if strings.HasPrefix(key, prefix) {
+			resp.Body = append(resp.Body, key)
+		}
+	}
+	slices.Sort(resp.Body)
+	return resp, nil
+}
+
+func (f *fakeKVRepo) Read(ctx context.Context, key string) (*consul.Response[*consul.KVPair], error) {
+	pair, ok := f.pairs[key]
+	if !ok {
+		return &consul.Response[*consul.KVPair]{Status: http.StatusNotFound}, nil
+	}
+	return &consul.Response[*consul.KVPair]{Status: http.StatusOK, Body: pair}, nil
+}
+
+func (f *fakeKVRepo) WriteCAS(ctx context.Context, key, value string, flags, index uint64) (*consul.Response[bool], error) {
+	var current uint64
+	if pair, ok := f.pairs[key]; ok {
+		current = pair.ModifyIndex
+	}
+	if current != index {
+		return &consul.Response[bool]{Status: http.StatusOK}, nil
+	}
+	f.put(key, value)
+	f.pairs[key].Flags = flags
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+func (f *fakeKVRepo) WriteFlags(ctx context.Context, key, value string, flags uint64) (*consul.Response[bool], error) {
+	f.put(key, value)
+	f.pairs[key].Flags = flags
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+func (f *fakeKVRepo) DeleteCAS(ctx context.Context, key string, index uint64) (*consul.Response[bool], error) {
+	if pair, ok := f.pairs[key]; !ok || pair.ModifyIndex != index {
+		return &consul.Response[bool]{Status: http.StatusOK}, nil
+	}
+	delete(f.pairs, key)
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+// Txn checks all operations before applying any of them, like consul.
+func (f *fakeKVRepo) Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error) {
+	var errs []consul.TxnError
+	for i, op := range ops {
+		var current uint64
+		if pair, ok := f.pairs[op.Key]; ok {
+			current = pair.ModifyIndex
+		}
+		switch op.Verb {
+		case consul.KVCAS, consul.KVCheckIndex, consul.KVDeleteCAS:
+			if current != op.Index {
+				errs = append(errs, consul.TxnError{OpIndex: i, What: "index mismatch"})
+			}
+		case consul.KVLock:
+			if pair, ok := f.pairs[op.Key]; ok && pair.Session != "" && pair.Session != op.Session {
+				errs = append(errs, consul.TxnError{OpIndex: i, What: "locked"})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &consul.Response[*consul.TxnResponse]{Status: http.StatusConflict, Body: &consul.TxnResponse{Errors: errs}}, nil
+	}
+	body := &consul.TxnResponse{}
+	for _, op := range ops {
+		switch op.Verb {
+		case consul.KVSet, consul.KVCAS, consul.KVLock:
+			f.put(op.Key, string(op.Value))
+			pair := f.pairs[op.Key]
+			pair.Flags = op.Flags
+			if op.Verb == consul.KVLock && pair.Session != op.Session {
+				pair.Session = op.Session
+				pair.LockIndex++
+			}
+			body.Results = append(body.Results, consul.TxnResult{KV: &consul.KVPair{Key: op.Key, Flags: pair.Flags, Session: pair.Session, LockIndex: pair.LockIndex, ModifyIndex: pair.ModifyIndex}})
+		case consul.KVDelete, consul.KVDeleteCAS:
+			delete(f.pairs, op.Key)
+		}
+	}
+	return &consul.Response[*consul.TxnResponse]{Status: http.StatusOK, Body: body}, nil
+}
+
+// Release writes the key and releases its lock if it's held by pair.Session.
+func (f *fakeKVRepo) Release(ctx context.Context, pair *consul.KVPair) (*consul.Response[bool], error) {
+	if old, ok := f.pairs[pair.Key]; !ok || old.Session != pair.Session {
+		return &consul.Response[bool]{Status: http.StatusOK}, nil
+	}
+	f.put(pair.Key, string(pair.Value))
+	f.pairs[pair.Key].Flags, f.pairs[pair.Key].Session = pair.Flags, ""
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+type fakeACLRepo struct{ repo.ACLRepo }
+
+func (fakeACLRepo) ReadSelf(ctx context.Context) (*consul.Response[*consul.ACLToken], error) {
+	return nil, errors.New("no acl")
+}
+
+type fakeAdminService struct {
+	AdminService
+	records []*AuditRecord
+	// history lists imported history versions as "b64key:version"
+	history []string
+	secrets []string
+	// valueTypes maps base64 keys to value types
+	valueTypes map[string]string
+	schemas    []KVSchema
+}
+
+func (f *fakeAdminService) GetValueType(ctx context.Context, b64key string) (string, error) {
+	return f.valueTypes[b64key], nil
+}
+
+func (f *fakeAdminService) WriteValueType(ctx context.Context, b64key, vt string) error {
+	if f.valueTypes == nil {
+		f.valueTypes = map[string]string{}
+	}
+	f.valueTypes[b64key] = vt
+	return nil
+}
+
+func (f *fakeAdminService) DeleteKVMeta(ctx context.Context, b64key string) error {
+	delete(f.valueTypes, b64key)
+	return nil
+}
+
+func (f *fakeAdminService) ListSchemas(ctx context.Context) ([]KVSchema, error) {
+	return f.schemas, nil
+}
+
+func (f *fakeAdminService) ListSecrets(ctx context.Context) ([]string, error) {
+	return f.secrets, nil
+}
+
+func (f *fakeAdminService) AddNewHistoryVersion(ctx context.Context, b64key, version, oldValue string) error {
+	f.history = append(f.history, b64key+":"+version)
+	return nil
+}
+
+func (f *fakeAdminService) WriteAuditRecord(ctx context.Context, record *AuditRecord) error {
+	f.records = append(f.records, record)
+	return nil
+}
+
+// fakeKVService keeps values in memory.
+type fakeKVService struct {
+	KVService
+	values map[string]string
+	flags  map[string]uint64
+}
+
+func (f *fakeKVService) GetStored(ctx context.Context, key string) (*GetValueResponse, error) {
+	value, ok := f.values[key]
+	if !ok {
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "key not found"}
+	}
+	return &GetValueResponse{Key: key, Value: value, ModifyIndex: 1}, nil
+}
+
+func (f *fakeKVService) Get(ctx context.Context, key string) (*GetValueResponse, error) {
+	return f.GetStored(ctx, key)
+}
+
+func (f *fakeKVService) Create(ctx context.Context, req *CreateKeyValueRequest) error {
+	f.values[req.Key] = req.Value
+	if f.flags != nil {
+		f.flags[req.Key] = req.Flags
+	}
+	return nil
+}
+
+func (f *fakeKVService) CheckValue(ctx context.Context, key, value, valueType string) error {
+	return nil
+}
@@ -4,12 +4,14 @@
 package service
 
 import (
+	"encoding/json"
 	"slices"
 
 	. "github.com/FlyingOnion/consee/backend/common"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 type HCLRuleList struct {
@@ -52,6 +54,13 @@ type ServiceBlock struct {
 	Intentions *string `hcl:"intentions"`
 }
 
+func (s ServiceBlock) intentions() string {
+	if s.Intentions == nil {
+		return ""
+	}
+	return *s.Intentions
+}
+
 func ParseHCLRules(rules string, v any) error {
 	if rules == "" {
 		return nil
@@ -81,6 +90,56 @@ func ParseHCLRules(rules string, v any) error {
 	return nil
 }
 
+// ParseHCLObject converts a HCL document into a generic object, like what consul does
+// with config entries written in HCL: attributes become fields, and blocks become
+// nested objects, or lists of objects if the same block type is repeated.
+// Labels of a block are nested too, e.g. `a "b" { c = 1 }` becomes {"a": {"b": {"c": 1}}}.
+func ParseHCLObject(src string) (map[string]any, error) {
+	f, d := hclsyntax.ParseConfig([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+	if d.HasErrors() {
+		return nil, d
+	}
+	return hclBodyToObject(f.Body.(*hclsyntax.Body))
+}
+
+func hclBodyToObject(body *hclsyntax.Body) (map[string]any, error) {
+	obj := make(map[string]any, len(body.Attributes)+len(body.Blocks))
+	for name, attr := range body.Attributes {
+		v, d := attr.Expr.Value(nil)
+		if d.HasErrors() {
+			return nil, d
+		}
+		b, err := ctyjson.Marshal(v, v.Type())
+		if err != nil {
+			return nil, err
+		}
+		var x any
+		if err = json.Unmarshal(b, &x); err != nil {
+			return nil, err
+		}
+		obj[name] = x
+	}
+	for _, block := range body.Blocks {
+		child, err := hclBodyToObject(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		var v any = child
+		for i := len(block.Labels) - 1; i >= 0; i-- {
+			v = map[string]any{block.Labels[i]: v}
+		}
+		switch existing := obj[block.Type].(type) {
+		case nil:
+			obj[block.Type] = v
+		case []any:
+			obj[block.Type] = append(existing, v)
+		default:
+			obj[block.Type] = []any{existing, v}
+		}
+	}
+	return obj, nil
+}
+
 func parsedRuleCompare(a, b ParsedRule) int {
 	if a.Type < b.Type {
 		return -1
@@ -190,10 +249,10 @@ func (r HCLRuleList) ToParsedRuleList() []ParsedRule {
 		rules = append(rules, ParsedRule{Type: "query", Match: "prefix", Param: q.Label, Access: q.Access})
 	}
 	for _, s := range r.Service {
-		rules = append(rules, ParsedRule{Type: "service", Match: "exact", Param: s.Label, Access: s.Access})
+		rules = append(rules, ParsedRule{Type: "service", Match: "exact", Param: s.Label, Access: s.Access, Intentions: s.intentions()})
 	}
 	for _, s := range r.ServicePrefix {
-		rules = append(rules, ParsedRule{Type: "service", Match: "prefix", Param: s.Label, Access: s.Access})
+		rules = append(rules, ParsedRule{Type: "service", Match: "prefix", Param: s.Label, Access: s.Access, Intentions: s.intentions()})
 	}
 	for _, s := range r.Session {
 		rules = append(rules, ParsedRule{Type: "session", Match: "exact", Param: s.Label, Access: s.Access})
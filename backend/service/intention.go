@@ -0,0 +1,272 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// IntentionService manages service intentions of the mesh.
+//
+// Intentions are identified by their source and destination names.
+// Lists are ordered by precedence, the same order consul evaluates them.
+type IntentionService interface {
+	ListIntentions(ctx context.Context) ([]Intention, error)
+	// MatchIntentions lists intentions that apply to the given source or destination.
+	// by should be "source" or "destination".
+	MatchIntentions(ctx context.Context, by, name string) ([]Intention, error)
+	ReadIntention(ctx context.Context, source, destination string) (*Intention, error)
+	UpsertIntention(ctx context.Context, req *Intention) error
+	DeleteIntention(ctx context.Context, source, destination string) error
+	// CheckIntention reports whether source is allowed to connect to destination.
+	CheckIntention(ctx context.Context, source, destination string) (bool, error)
+}
+
+type intentionService struct {
+	intention repo.IntentionRepo
+}
+
+func NewIntentionService(intention repo.IntentionRepo) IntentionService {
+	return &intentionService{intention: intention}
+}
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+func invalidIntention(message string) error {
+	return &DomainError{Code: DomainErrorCodeInvalidInput, Message: message}
+}
+
+func validIntentionAction(action string) bool {
+	return action == consul.IntentionActionAllow || action == consul.IntentionActionDeny
+}
+
+func validateIntention(in *Intention) error {
+	if in.Source == "" || in.Destination == "" {
+		return invalidIntention("source and destination are required")
+	}
+	if in.Action != "" && len(in.Permissions) > 0 {
+		return invalidIntention("action and permissions are mutually exclusive")
+	}
+	if in.Action == "" && len(in.Permissions) == 0 {
+		return invalidIntention("either action or permissions is required")
+	}
+	if in.Action != "" && !validIntentionAction(in.Action) {
+		return invalidIntention("action should be allow or deny")
+	}
+	for i := range in.Permissions {
+		if err := validateIntentionPermission(&in.Permissions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateIntentionPermission(p *IntentionPermission) error {
+	if !validIntentionAction(p.Action) {
+		return invalidIntention("permission action should be allow or deny")
+	}
+	if p.HTTP == nil {
+		return invalidIntention("permission should have an http rule")
+	}
+	h := p.HTTP
+	paths := 0
+	for _, path := range []string{h.PathExact, h.PathPrefix, h.PathRegex} {
+		if path != "" {
+			paths++
+		}
+	}
+	if paths > 1 {
+		return invalidIntention("at most one of path_exact, path_prefix and path_regex can be set")
+	}
+	if h.PathExact != "" && !strings.HasPrefix(h.PathExact, "/") ||
+		h.PathPrefix != "" && !strings.HasPrefix(h.PathPrefix, "/") {
+		return invalidIntention("path should begin with '/'")
+	}
+	for _, m := range h.Methods {
+		if !slices.Contains(httpMethods, m) {
+			return invalidIntention("invalid http method " + m)
+		}
+	}
+	if paths == 0 && len(h.Header) == 0 && len(h.Methods) == 0 {
+		return invalidIntention("http rule should match at least one of path, header and methods")
+	}
+	for _, hd := range h.Header {
+		if hd.Name == "" {
+			return invalidIntention("header name is required")
+		}
+		matches := 0
+		if hd.Present {
+			matches++
+		}
+		for _, v := range []string{hd.Exact, hd.Prefix, hd.Suffix, hd.Regex} {
+			if v != "" {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return invalidIntention("header " + hd.Name + " should have exactly one of present, exact, prefix, suffix and regex")
+		}
+	}
+	return nil
+}
+
+func toIntention(in *consul.Intention) Intention {
+	out := Intention{
+		Source:      in.SourceName,
+		SourcePeer:  in.SourcePeer,
+		Destination: in.DestinationName,
+		Action:      in.Action,
+		Description: in.Description,
+		Meta:        in.Meta,
+		Precedence:  in.Precedence,
+	}
+	if len(in.Permissions) > 0 {
+		out.Permissions = make([]IntentionPermission, 0, len(in.Permissions))
+	}
+	for _, p := range in.Permissions {
+		perm := IntentionPermission{Action: p.Action}
+		if p.HTTP != nil {
+			perm.HTTP = &IntentionHTTPPermission{
+				PathExact:  p.HTTP.PathExact,
+				PathPrefix: p.HTTP.PathPrefix,
+				PathRegex:  p.HTTP.PathRegex,
+				Methods:    p.HTTP.Methods,
+			}
+			for _, h := range p.HTTP.Header {
+				perm.HTTP.Header = append(perm.HTTP.Header, IntentionHTTPHeader{
+					Name:    h.Name,
+					Present: h.Present,
+					Exact:   h.Exact,
+					Prefix:  h.Prefix,
+					Suffix:  h.Suffix,
+					Regex:   h.Regex,
+					Invert:  h.Invert,
+				})
+			}
+		}
+		out.Permissions = append(out.Permissions, perm)
+	}
+	return out
+}
+
+func fromIntention(in *Intention) *consul.Intention {
+	out := &consul.Intention{
+		SourceName:      in.Source,
+		SourcePeer:      in.SourcePeer,
+		DestinationName: in.Destination,
+		SourceType:      "consul",
+		Action:          in.Action,
+		Description:     in.Description,
+		Meta:            in.Meta,
+	}
+	for _, p := range in.Permissions {
+		perm := &consul.IntentionPermission{Action: p.Action}
+		if p.HTTP != nil {
+			perm.HTTP = &consul.IntentionHTTPPermission{
+				PathExact:  p.HTTP.PathExact,
+				PathPrefix: p.HTTP.PathPrefix,
+				PathRegex:  p.HTTP.PathRegex,
+				Methods:    p.HTTP.Methods,
+			}
+			for _, h := range p.HTTP.Header {
+				perm.HTTP.Header = append(perm.HTTP.Header, consul.IntentionHTTPHeaderPermission{
+					Name:    h.Name,
+					Present: h.Present,
+					Exact:   h.Exact,
+					Prefix:  h.Prefix,
+					Suffix:  h.Suffix,
+					Regex:   h.Regex,
+					Invert:  h.Invert,
+				})
+			}
+		}
+		out.Permissions = append(out.Permissions, perm)
+	}
+	return out
+}
+
+// sortIntentions sorts intentions by precedence (higher first),
+// then by destination and source so that the order is stable.
+func sortIntentions(intentions []Intention) {
+	slices.SortStableFunc(intentions, func(a, b Intention) int {
+		if c := cmp.Compare(b.Precedence, a.Precedence); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Destination, b.Destination); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Source, b.Source)
+	})
+}
+
+func toIntentionList(list []*consul.Intention) []Intention {
+	intentions := make([]Intention, 0, len(list))
+	for _, in := range list {
+		intentions = append(intentions, toIntention(in))
+	}
+	sortIntentions(intentions)
+	return intentions
+}
+
+func (s *intentionService) ListIntentions(ctx context.Context) ([]Intention, error) {
+	resp, err := s.intention.ListIntentions(ctx)
+	if err := responseError(resp, err, "list intentions"); err != nil {
+		return nil, err
+	}
+	return toIntentionList(resp.Body), nil
+}
+
+func (s *intentionService) MatchIntentions(ctx context.Context, by, name string) ([]Intention, error) {
+	if by != consul.IntentionMatchSource && by != consul.IntentionMatchDestination {
+		return nil, invalidIntention("by should be source or destination")
+	}
+	if name == "" {
+		return nil, invalidIntention("name is required")
+	}
+	resp, err := s.intention.MatchIntentions(ctx, by, name)
+	if err := responseError(resp, err, "match intentions"); err != nil {
+		return nil, err
+	}
+	return toIntentionList(resp.Body), nil
+}
+
+func (s *intentionService) ReadIntention(ctx context.Context, source, destination string) (*Intention, error) {
+	resp, err := s.intention.ReadIntention(ctx, source, destination)
+	if err := responseError(resp, err, "read intention"); err != nil {
+		return nil, err
+	}
+	in := toIntention(resp.Body)
+	return &in, nil
+}
+
+func (s *intentionService) UpsertIntention(ctx context.Context, req *Intention) error {
+	if err := validateIntention(req); err != nil {
+		return err
+	}
+	resp, err := s.intention.UpsertIntention(ctx, fromIntention(req))
+	return responseError(resp, err, "upsert intention")
+}
+
+func (s *intentionService) DeleteIntention(ctx context.Context, source, destination string) error {
+	resp, err := s.intention.DeleteIntention(ctx, source, destination)
+	return responseError(resp, err, "delete intention")
+}
+
+func (s *intentionService) CheckIntention(ctx context.Context, source, destination string) (bool, error) {
+	if source == "" || destination == "" {
+		return false, invalidIntention("source and destination are required")
+	}
+	resp, err := s.intention.CheckIntention(ctx, source, destination)
+	if err := responseError(resp, err, "check intention"); err != nil {
+		return false, err
+	}
+	return resp.Body, nil
+}
@@ -5,43 +5,75 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/FlyingOnion/consee/backend/buffer"
 	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
 	"github.com/FlyingOnion/consee/backend/repo"
 )
 
 // func ListKeys() (keys []string, err error)
 // func Get(key string) (*GetValueResponse, error)
 // func Create(req *CreateKeyValueRequest) error
-// func Update(key, value string) error
+// func Update(key string, req *UpdateValueRequest) error
 // func UpdateType(key, valueType string) error
-// func Delete(key string) error
+// func Delete(key string, count int) error
 
 type KVService interface {
 	ListKeys(ctx context.Context) (keys []string, err error)
+	// ListKeysByFlags lists keys whose flags are flags. Values are read to get the flags.
+	ListKeysByFlags(ctx context.Context, flags uint64) (keys []string, err error)
+	// Get decrypts the value if it's encrypted, see ValueEncryption.
 	Get(ctx context.Context, key string) (*GetValueResponse, error)
+	// GetStored reads the value as it's stored, so that exports keep encrypted values intact.
+	GetStored(ctx context.Context, key string) (*GetValueResponse, error)
 	Create(ctx context.Context, req *CreateKeyValueRequest) error
-	Update(ctx context.Context, key, value string) error
+	// Create and Update reject values which are invalid for the value type of the key,
+	// or violate the schema attached to the key or its prefix.
+	Update(ctx context.Context, key string, req *UpdateValueRequest) error
 	UpdateType(ctx context.Context, key, valueType string) error
 	BatchUpdate(ctx context.Context, req *BatchUpdateRequest) error
-	Delete(ctx context.Context, key string) error
+	// DeletePreview lists the keys which would be deleted with key.
+	DeletePreview(ctx context.Context, key string) (*KVDeletePreview, error)
+	// Delete deletes a key, or a folder with all keys under it.
+	// count should be the Count of DeletePreview for folders, so that keys created since the preview are not deleted unnoticed.
+	Delete(ctx context.Context, key string, count int) error
+	// Move moves a key or folder in a transaction, with value types and history versions.
+	Move(ctx context.Context, req *MoveKVRequest) (*KVMoveResult, error)
+	// Copy copies a key or folder in a transaction, with value types and history versions.
+	Copy(ctx context.Context, req *MoveKVRequest) (*KVMoveResult, error)
+	// Search matches keys and values under a prefix. The scan is capped, see KVSearchResponse.Truncated.
+	Search(ctx context.Context, req *KVSearchRequest) (*KVSearchResponse, error)
+	// CheckValue validates value as Create with valueType, or Update if valueType is "", would do.
+	CheckValue(ctx context.Context, key, value, valueType string) error
+	// Lint checks the syntax of the existing value by its value type.
+	Lint(ctx context.Context, key string) (*KVLintResult, error)
 
 	WatchOpenNotificationsCount(ctx context.Context, cb func(n int))
 }
 
 type kvService struct {
-	kv    repo.KVRepo
-	admin AdminService
+	kv repo.KVRepo
+	// session destroys sessions of checkouts ended by saves
+	session repo.SessionRepo
+	admin   AdminService
+	// encryption is nil if values are not encrypted
+	encryption *ValueEncryption
 }
 
-func NewKVService(kv repo.KVRepo, admin AdminService) KVService {
+func NewKVService(kv repo.KVRepo, session repo.SessionRepo, admin AdminService, encryption *ValueEncryption) KVService {
 	return &kvService{
-		kv:    kv,
-		admin: admin,
+		kv:         kv,
+		session:    session,
+		admin:      admin,
+		encryption: encryption,
 	}
 }
 
@@ -65,7 +97,39 @@ func (s *kvService) ListKeys(ctx context.Context) (keys []string, err error) {
 	return keysExcludingInternal, nil
 }
 
+func (s *kvService) ListKeysByFlags(ctx context.Context, flags uint64) (keys []string, err error) {
+	resp, err := s.kv.List(ctx, "")
+	if err != nil {
+		slog.Error("failed to list keys by flags", "flags", flags, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	keys = []string{}
+	for _, pair := range resp.Body {
+		if pair.Flags == flags && !strings.HasPrefix(pair.Key, ConseeInternalKeyPrefix) {
+			keys = append(keys, pair.Key)
+		}
+	}
+	return keys, nil
+}
+
 func (s *kvService) Get(ctx context.Context, key string) (*GetValueResponse, error) {
+	kv, err := s.GetStored(ctx, key)
+	if err != nil || !encrypt.IsSealed([]byte(kv.Value)) {
+		return kv, err
+	}
+	value, err := s.encryption.open([]byte(kv.Value))
+	if err != nil {
+		slog.Error("kvGet: failed to decrypt value", "key", key, "error", err)
+		return nil, err
+	}
+	kv.Value, kv.Encrypted = string(value), true
+	return kv, nil
+}
+
+func (s *kvService) GetStored(ctx context.Context, key string) (*GetValueResponse, error) {
 	resp, err := s.kv.Read(ctx, key)
 	// resp, err := s.client.KV().Get(ctx, key, consul.QueryOptionsFromContext(ctx))
 	if err != nil {
@@ -82,8 +146,13 @@ func (s *kvService) Get(ctx context.Context, key string) (*GetValueResponse, err
 	}
 
 	return &GetValueResponse{
-		Key:   resp.Body.Key,
-		Value: string(resp.Body.Value),
+		Key:         resp.Body.Key,
+		Value:       string(resp.Body.Value),
+		CreateIndex: resp.Body.CreateIndex,
+		ModifyIndex: resp.Body.ModifyIndex,
+		LockIndex:   resp.Body.LockIndex,
+		Flags:       resp.Body.Flags,
+		Session:     resp.Body.Session,
 	}, resp.Err
 }
 
@@ -97,8 +166,21 @@ func (s *kvService) Create(ctx context.Context, req *CreateKeyValueRequest) erro
 		slog.Error("kvCreate: key already exists", "key", req.Key)
 		return &DomainError{Code: DomainErrorCodeAlreadyExists, Message: "key already exists"}
 	}
+	value, err := decodeValue(req.Encoding, req.Value)
+	if err != nil {
+		return err
+	}
+	valueType := req.ValueType
+	if valueType == "" && req.Encoding == KVEncodingBase64 {
+		valueType = ValueTypeBinary
+	}
+	if !strings.HasSuffix(req.Key, "/") {
+		if value, err = s.prepareWrite(ctx, req.Key, valueType, value, req.Format); err != nil {
+			return err
+		}
+	}
 
-	resp, err := s.kv.Write(ctx, req.Key, req.Value)
+	resp, err := s.kv.WriteFlags(ctx, req.Key, value, req.Flags)
 	// resp, err := s.client.KV().Put(ctx, &consul.KVPair{Key: req.Key, Value: []byte(req.Value)}, consul.WriteOptionsFromContext(ctx))
 	if err != nil {
 		slog.Error("kvCreate: failed to create key", "key", req.Key, "error", err)
@@ -114,10 +196,25 @@ func (s *kvService) Create(ctx context.Context, req *CreateKeyValueRequest) erro
 	if req.Key[len(req.Key)-1] == '/' {
 		return nil
 	}
-	return s.admin.WriteValueType(ctx, req.Key, req.ValueType)
+	return s.admin.WriteValueType(ctx, base64.StdEncoding.EncodeToString([]byte(req.Key)), valueType)
 }
 
-func (s *kvService) Update(ctx context.Context, key, value string) error {
+// decodeValue decodes a value sent with encoding, see KVEncodingBase64.
+func decodeValue(encoding, value string) (string, error) {
+	switch encoding {
+	case "":
+		return value, nil
+	case KVEncodingBase64:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid base64 value: " + err.Error()}
+		}
+		return string(b), nil
+	}
+	return "", &DomainError{Code: DomainErrorCodeInvalidInput, Message: "unsupported encoding: " + encoding}
+}
+
+func (s *kvService) Update(ctx context.Context, key string, req *UpdateValueRequest) error {
 	resp1, err := s.kv.Read(ctx, key)
 	// resp1, err := s.client.KV().Keys(ctx, key, "", consul.QueryOptionsFromContext(ctx))
 	if err != nil {
@@ -126,8 +223,23 @@ func (s *kvService) Update(ctx context.Context, key, value string) error {
 	if resp1.Body == nil {
 		return &DomainError{Code: DomainErrorCodeNotFound, Message: "key not found"}
 	}
+	value, err := decodeValue(req.Encoding, req.Value)
+	if err != nil {
+		return err
+	}
+	if value, err = s.prepareWrite(ctx, key, s.valueType(ctx, key), value, req.Format); err != nil {
+		return err
+	}
+	// flags are kept unless they are set
+	flags := resp1.Body.Flags
+	if req.Flags != nil {
+		flags = *req.Flags
+	}
 
-	resp, err := s.kv.Write(ctx, key, value)
+	if req.Session != "" {
+		return s.release(ctx, &consul.KVPair{Key: key, Value: []byte(value), Flags: flags, Session: req.Session})
+	}
+	resp, err := s.kv.WriteFlags(ctx, key, value, flags)
 	if err != nil {
 		return errFailedToConnectConsul
 	}
@@ -140,6 +252,26 @@ func (s *kvService) Update(ctx context.Context, key, value string) error {
 	return nil
 }
 
+// release writes the pair and ends the checkout of pair.Session, releasing all keys it locked.
+func (s *kvService) release(ctx context.Context, pair *consul.KVPair) error {
+	resp, err := s.kv.Release(ctx, pair)
+	if err != nil {
+		slog.Error("kvRelease: failed to release key", "key", pair.Key, "error", err)
+		return errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return errPermissionDenied
+	}
+	if !resp.Body {
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "the key is not locked by the session, or has changed"}
+	}
+	// the value is saved, so the session left behind only expires later
+	if err := destroyCheckout(ctx, s.session, pair.Session); err != nil {
+		slog.Warn("kvRelease: failed to end checkout", "key", pair.Key, "session", pair.Session, "error", err)
+	}
+	return nil
+}
+
 func (s *kvService) UpdateType(ctx context.Context, key, valueType string) error {
 	resp1, err := s.kv.ListKeys(ctx, key, "")
 	if err != nil {
@@ -163,7 +295,7 @@ type BatchUpdateErrorList struct {
 func (s *kvService) BatchUpdate(ctx context.Context, req *BatchUpdateRequest) error {
 	nErr, errList := 0, []BatchUpdateErrorList{}
 	for _, kv := range req.KeyValues {
-		err := s.Update(ctx, kv.Key, kv.Value)
+		err := s.Update(ctx, kv.Key, &UpdateValueRequest{Value: kv.Value, Encoding: kv.Encoding})
 		if err != nil {
 			nErr++
 			errList = append(errList, BatchUpdateErrorList{kv.Key, err})
@@ -188,18 +320,116 @@ func (s *kvService) BatchUpdate(ctx context.Context, req *BatchUpdateRequest) er
 	}
 }
 
-func (s *kvService) Delete(ctx context.Context, key string) error {
-	resp, err := s.kv.Delete(ctx, key)
+func (s *kvService) DeletePreview(ctx context.Context, key string) (*KVDeletePreview, error) {
+	if strings.HasPrefix(key, ConseeInternalKeyPrefix) {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "internal keys could not be deleted"}
+	}
+	preview := &KVDeletePreview{Key: key, Keys: []string{}}
+	if !strings.HasSuffix(key, "/") {
+		resp, err := s.kv.Read(ctx, key)
+		if err != nil {
+			slog.Error("kvDeletePreview: failed to read key", "key", key, "error", err)
+			return nil, errFailedToConnectConsul
+		}
+		if resp.Status == http.StatusForbidden {
+			return nil, errPermissionDenied
+		}
+		if resp.Body != nil {
+			preview.Keys = append(preview.Keys, key)
+		}
+		preview.Count = len(preview.Keys)
+		return preview, nil
+	}
+	resp, err := s.kv.ListKeys(ctx, key, "")
 	if err != nil {
-		slog.Error("kvDelete: failed to delete key", "key", key, "error", err)
-		return errFailedToConnectConsul
+		slog.Error("kvDeletePreview: failed to list keys", "key", key, "error", err)
+		return nil, errFailedToConnectConsul
 	}
 	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	for _, k := range resp.Body {
+		if !strings.HasPrefix(k, ConseeInternalKeyPrefix) {
+			preview.Keys = append(preview.Keys, k)
+		}
+	}
+	preview.Count = len(preview.Keys)
+	return preview, nil
+}
+
+// Delete deletes the key, or keys under the folder if count is the number of them, see DeletePreview.
+// Keys are deleted with delete-cas, so keys changed or created after they are counted are not deleted.
+// Folders with more than consul.MaxTxnOps keys are deleted in several transactions,
+// and keys deleted by earlier transactions stay deleted if a later one fails, which the error tells.
+func (s *kvService) Delete(ctx context.Context, key string, count int) error {
+	if strings.HasPrefix(key, ConseeInternalKeyPrefix) {
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "internal keys could not be deleted"}
+	}
+	pairs, err := readKVTree(ctx, s.kv, key)
+	if err != nil {
+		return err
+	}
+	pairs = slices.DeleteFunc(pairs, func(pair *consul.KVPair) bool {
+		return strings.HasPrefix(pair.Key, ConseeInternalKeyPrefix)
+	})
+	if strings.HasSuffix(key, "/") && len(pairs) != count {
+		return &DomainError{
+			Code:    DomainErrorCodeConflict,
+			Message: strconv.Itoa(len(pairs)) + " keys would be deleted but " + strconv.Itoa(count) + " were previewed, please preview again",
+		}
+	}
+	deleted := 0
+	for chunk := range slices.Chunk(pairs, consul.MaxTxnOps) {
+		if err := s.deleteChunk(ctx, key, chunk); err != nil {
+			return partialDeleteError(err, deleted, len(pairs))
+		}
+		deleted += len(chunk)
+	}
+	return nil
+}
+
+// deleteChunk deletes pairs in one transaction, and their metadata.
+func (s *kvService) deleteChunk(ctx context.Context, key string, pairs []*consul.KVPair) error {
+	ops := make([]*consul.KVTxnOp, len(pairs))
+	for i, pair := range pairs {
+		ops[i] = &consul.KVTxnOp{Verb: consul.KVDeleteCAS, Key: pair.Key, Index: pair.ModifyIndex}
+	}
+	resp, err := s.kv.Txn(ctx, ops)
+	if err != nil {
+		slog.Error("kvDelete: failed to delete keys", "key", key, "error", err)
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+	case http.StatusForbidden:
 		slog.Error("kvDelete: permission denied", "key", key, "status", resp.Status)
 		return errPermissionDenied
+	case http.StatusConflict:
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "keys have changed since the preview, please preview again: " + txnConflicts(resp.Body, ops)}
+	default:
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to delete: " + string(resp.RawBody)}
+	}
+	for _, pair := range pairs {
+		if strings.HasSuffix(pair.Key, "/") {
+			continue
+		}
+		if err := s.admin.DeleteKVMeta(ctx, base64.StdEncoding.EncodeToString([]byte(pair.Key))); err != nil {
+			slog.Warn("kvDelete: failed to delete metadata", "key", pair.Key, "error", err)
+		}
 	}
-	s.admin.DeleteValueType(ctx, key)
 	return nil
 }
 
+// partialDeleteError adds the number of keys deleted by earlier transactions to err.
+func partialDeleteError(err error, deleted, total int) error {
+	dErr, ok := err.(*DomainError)
+	if !ok || deleted == 0 {
+		return err
+	}
+	return &DomainError{
+		Code:    dErr.Code,
+		Message: dErr.Message + " (" + strconv.Itoa(deleted) + " of " + strconv.Itoa(total) + " keys are already deleted)",
+	}
+}
+
 func (s *kvService) WatchOpenNotificationsCount(ctx context.Context, cb func(n int)) {}
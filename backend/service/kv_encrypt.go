@@ -0,0 +1,178 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+const AuditActionEncryptionRotate = "encryption-rotate"
+
+// ValueEncryption encrypts values of keys under Prefixes with the keyring, so that they are never stored as plaintext.
+// Encrypted values are decrypted by Get, wherever they are, e.g. after a move.
+type ValueEncryption struct {
+	Prefixes []string
+	Keyring  *encrypt.Keyring
+}
+
+// encrypts reports whether values of key should be encrypted. e could be nil if encryption is disabled.
+func (e *ValueEncryption) encrypts(key string) bool {
+	if e == nil || strings.HasSuffix(key, "/") {
+		return false
+	}
+	for _, prefix := range e.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// open decrypts a sealed value.
+func (e *ValueEncryption) open(value []byte) ([]byte, error) {
+	if e == nil {
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "the value is encrypted, but no master key is configured"}
+	}
+	plaintext, err := e.Keyring.Open(value)
+	if err != nil {
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to decrypt the value: " + err.Error()}
+	}
+	return plaintext, nil
+}
+
+// decrypt returns the plaintext of a sealed value, or the value itself if it's not sealed.
+func (e *ValueEncryption) decrypt(value []byte) ([]byte, error) {
+	if !encrypt.IsSealed(value) {
+		return value, nil
+	}
+	return e.open(value)
+}
+
+// seal returns the value to store for key. Plain values are encrypted if key is under the prefixes,
+// and sealed values, e.g. of exports, are stored sealed wherever key is if they could be opened with the keyring.
+// Those sealed with an old master key are sealed again with the current one, so that imports need no rotation.
+// Values which only look sealed are rejected, since they would be stored as plaintext otherwise.
+func (e *ValueEncryption) seal(key string, value []byte) ([]byte, error) {
+	if encrypt.IsSealed(value) {
+		plaintext, err := e.open(value)
+		if err != nil {
+			return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid encrypted value of " + key + ": " + err.Error()}
+		}
+		if encrypt.SealedKeyID(value) == e.Keyring.KeyID() {
+			return value, nil
+		}
+		value = plaintext
+	} else if !e.encrypts(key) {
+		return value, nil
+	}
+	sealed, err := e.Keyring.Seal(value)
+	if err != nil {
+		slog.Error("failed to encrypt value", "key", key, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to encrypt the value"}
+	}
+	return sealed, nil
+}
+
+// EncryptionService manages encrypted values.
+type EncryptionService interface {
+	// Rotate re-encrypts values under prefix with new data keys wrapped by the current master key,
+	// and encrypts plain values under the encrypted prefixes, e.g. those written before encryption was enabled.
+	Rotate(ctx context.Context, prefix string) (*KVRotateResult, error)
+}
+
+type encryptionService struct {
+	kv         repo.KVRepo
+	acl        repo.ACLRepo
+	admin      AdminService
+	encryption *ValueEncryption
+}
+
+func NewEncryptionService(kv repo.KVRepo, acl repo.ACLRepo, admin AdminService, encryption *ValueEncryption) EncryptionService {
+	return &encryptionService{kv: kv, acl: acl, admin: admin, encryption: encryption}
+}
+
+func (s *encryptionService) Rotate(ctx context.Context, prefix string) (*KVRotateResult, error) {
+	resp, err := s.kv.List(ctx, prefix)
+	if err != nil {
+		slog.Error("failed to list keys for rotation", "prefix", prefix, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+
+	result := &KVRotateResult{Prefix: prefix, KeyID: s.encryption.Keyring.KeyID(), Errors: []KVRotateError{}}
+	var ops []*consul.KVTxnOp
+	for _, pair := range resp.Body {
+		if strings.HasPrefix(pair.Key, ConseeInternalKeyPrefix) || strings.HasSuffix(pair.Key, "/") {
+			continue
+		}
+		value := pair.Value
+		if encrypt.IsSealed(value) {
+			if value, err = s.encryption.open(value); err != nil {
+				result.Errors = append(result.Errors, KVRotateError{Key: pair.Key, Error: err.Error()})
+				continue
+			}
+		} else if !s.encryption.encrypts(pair.Key) {
+			continue
+		}
+		sealed, err := s.encryption.Keyring.Seal(value)
+		if err != nil {
+			result.Errors = append(result.Errors, KVRotateError{Key: pair.Key, Error: err.Error()})
+			continue
+		}
+		// CAS guards values changed since the listing, and flags are kept
+		ops = append(ops, &consul.KVTxnOp{Verb: consul.KVCAS, Key: pair.Key, Value: sealed, Flags: pair.Flags, Index: pair.ModifyIndex})
+	}
+
+	// a failed batch is rolled back, and others are still rotated
+	for len(ops) > 0 {
+		batch := ops[:min(len(ops), consul.MaxTxnOps)]
+		ops = ops[len(batch):]
+		if err := s.rotateBatch(ctx, batch); err != nil {
+			for _, op := range batch {
+				result.Errors = append(result.Errors, KVRotateError{Key: op.Key, Error: err.Error()})
+			}
+			continue
+		}
+		result.Rotated += len(batch)
+	}
+
+	err = s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: AuditActionEncryptionRotate,
+		Target: schemaName(prefix),
+		Detail: strconv.Itoa(result.Rotated) + " values encrypted with key " + result.KeyID + ", " + strconv.Itoa(len(result.Errors)) + " failed",
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", AuditActionEncryptionRotate, "error", err)
+	}
+	return result, nil
+}
+
+func (s *encryptionService) rotateBatch(ctx context.Context, ops []*consul.KVTxnOp) error {
+	resp, err := s.kv.Txn(ctx, ops)
+	if err != nil {
+		slog.Error("failed to rotate values", "error", err)
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errPermissionDenied
+	case http.StatusConflict:
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "keys have changed, please try again: " + txnConflicts(resp.Body, ops)}
+	}
+	return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to rotate: " + string(resp.RawBody)}
+}
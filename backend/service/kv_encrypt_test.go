@@ -0,0 +1,125 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+)
+
+func testMasterKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := encrypt.GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := encrypt.ParseMasterKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testEncryption encrypts values under "secret/" with the master keys, the first of which is the current one.
+func testEncryption(t *testing.T, keys ...[]byte) *ValueEncryption {
+	t.Helper()
+	keyring, err := encrypt.NewKeyring(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ValueEncryption{Prefixes: []string{"secret/"}, Keyring: keyring}
+}
+
+func TestValueEncryption(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	admin := &fakeAdminService{}
+	oldKey := testMasterKey(t)
+	enc := testEncryption(t, oldKey)
+	s := NewKVService(kv, nil, admin, enc)
+
+	if err := s.Create(ctx, &CreateKeyValueRequest{Key: "secret/db", Value: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	stored := kv.pairs["secret/db"].Value
+	if !encrypt.IsSealed(stored) || strings.Contains(string(stored), "s3cret") {
+		t.Fatalf("value stored as %q", stored)
+	}
+	if kv, err := s.Get(ctx, "secret/db"); err != nil || kv.Value != "s3cret" || !kv.Encrypted {
+		t.Fatalf("get = %+v, %v", kv, err)
+	}
+
+	// encrypted values, e.g. of exports, are written as they are wherever they are
+	if err := s.Create(ctx, &CreateKeyValueRequest{Key: "app/db", Value: string(stored)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := kv.pairs["app/db"].Value; string(got) != string(stored) {
+		t.Errorf("encrypted value stored as %q", got)
+	}
+
+	// values which only look encrypted skip neither validation nor encryption
+	code := func(err error) DomainErrorCode {
+		var derr *DomainError
+		if !errors.As(err, &derr) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return derr.Code
+	}
+	forged := "consee:v1:" + enc.Keyring.KeyID() + ":AAAA:AAAA"
+	if err := s.Create(ctx, &CreateKeyValueRequest{Key: "secret/forged", Value: forged}); code(err) != DomainErrorCodeInvalidInput {
+		t.Errorf("create of a forged value = %v", err)
+	}
+	if _, ok := kv.pairs["secret/forged"]; ok {
+		t.Error("forged value stored")
+	}
+	if err := s.Update(ctx, "secret/db", &UpdateValueRequest{Value: forged}); code(err) != DomainErrorCodeInvalidInput {
+		t.Errorf("update to a forged value = %v", err)
+	}
+	if err := s.CheckValue(ctx, "app/json", forged, ValueTypeJSON); err == nil {
+		t.Error("expected forged values to be checked")
+	}
+	invalid, _ := enc.Keyring.Seal([]byte("{"))
+	if err := s.CheckValue(ctx, "app/json", string(invalid), ValueTypeJSON); err == nil {
+		t.Error("expected encrypted values to be checked by their plaintext")
+	}
+
+	// a rotation re-encrypts values with the current key, and encrypts plain values under the prefixes
+	kv.put("secret/plain", "written before encryption")
+	rotated := testEncryption(t, testMasterKey(t), oldKey)
+	result, err := NewEncryptionService(kv, fakeACLRepo{}, admin, rotated).Rotate(ctx, "secret/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rotated != 2 || len(result.Errors) != 0 || result.KeyID != rotated.Keyring.KeyID() {
+		t.Fatalf("unexpected rotation: %+v", result)
+	}
+	s = NewKVService(kv, nil, admin, rotated)
+	for key, want := range map[string]string{"secret/db": "s3cret", "secret/plain": "written before encryption"} {
+		if !strings.HasPrefix(string(kv.pairs[key].Value), "consee:v1:"+rotated.Keyring.KeyID()+":") {
+			t.Errorf("%s is not encrypted with the current key: %q", key, kv.pairs[key].Value)
+		}
+		if kv, err := s.Get(ctx, key); err != nil || kv.Value != want {
+			t.Errorf("get %s = %+v, %v", key, kv, err)
+		}
+	}
+
+	// values encrypted with an old key, e.g. of exports made before the rotation, are encrypted again
+	for _, key := range []string{"secret/imported", "app/imported"} {
+		if err := s.Create(ctx, &CreateKeyValueRequest{Key: key, Value: string(stored)}); err != nil {
+			t.Fatal(err)
+		}
+		if id := encrypt.SealedKeyID(kv.pairs[key].Value); id != rotated.Keyring.KeyID() {
+			t.Errorf("%s is encrypted with key %q, want the current key", key, id)
+		}
+		if kv, err := s.Get(ctx, key); err != nil || kv.Value != "s3cret" {
+			t.Errorf("get %s = %+v, %v", key, kv, err)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/goccy/go-yaml"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Native formats map keys under a root to documents that applications read directly.
+// Tree formats nest folders as maps, and flat formats join the path with a separator.
+const (
+	FormatYAML       = "yaml"
+	FormatJSONTree   = "json-tree"
+	FormatHCL        = "hcl"
+	FormatProperties = "properties"
+	FormatEnv        = "env"
+)
+
+func isTreeFormat(format string) bool {
+	return format == FormatYAML || format == FormatJSONTree || format == FormatHCL
+}
+
+func isNativeFormat(format string) bool {
+	return isTreeFormat(format) || format == FormatProperties || format == FormatEnv
+}
+
+// defaultSeparator joins folders in flat formats.
+// Env uses double underscores, so that underscores in names of keys survive a round trip.
+func defaultSeparator(format string) string {
+	if format == FormatEnv {
+		return "__"
+	}
+	return "."
+}
+
+// normalizeRoot makes root a folder, so that it could be trimmed from or prepended to keys.
+func normalizeRoot(root string) string {
+	if root == "" || strings.HasSuffix(root, "/") {
+		return root
+	}
+	return root + "/"
+}
+
+func invalidDocument(format string, err error) error {
+	return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid " + format + " document: " + err.Error()}
+}
+
+// typedValue converts a value to bool or number if it converts back to the same string,
+// so that typing never changes what is imported again.
+func typedValue(v string) any {
+	switch v {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil && strconv.FormatInt(i, 10) == v {
+		return i
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == v {
+		return f
+	}
+	return v
+}
+
+// scalarString converts a decoded value back to a KV value.
+// Lists are stored as json since they have no counterpart in KV.
+func scalarString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case json.Number:
+		return x.String()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(x)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// buildKVTree nests kvs under root as maps. Folders are maps and values are strings,
+// or typed scalars if typed is true.
+func buildKVTree(kvs []*GetValueResponse, root string, typed bool) (map[string]any, error) {
+	tree := map[string]any{}
+	for _, kv := range kvs {
+		rel := strings.TrimPrefix(kv.Key, root)
+		if rel == "" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(rel, "/"), "/")
+		node := tree
+		for i, part := range parts {
+			last := i == len(parts)-1
+			if last && !strings.HasSuffix(rel, "/") {
+				if _, ok := node[part].(map[string]any); ok {
+					return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "key " + kv.Key + " is both a value and a folder"}
+				}
+				if typed {
+					node[part] = typedValue(kv.Value)
+				} else {
+					node[part] = kv.Value
+				}
+				break
+			}
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				if _, isValue := node[part]; isValue {
+					return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "key " + kv.Key + " is under a value"}
+				}
+				child = map[string]any{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return tree, nil
+}
+
+// flattenKVTree is the reverse of buildKVTree. Empty maps become folders.
+func flattenKVTree(tree map[string]any, prefix string, kvs CompatibleKVMetaList) CompatibleKVMetaList {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		key := prefix + name
+		switch x := tree[name].(type) {
+		case map[string]any:
+			if len(x) == 0 {
+				kvs = append(kvs, &CompatibleKVMeta{Key: key + "/"})
+				continue
+			}
+			kvs = flattenKVTree(x, key+"/", kvs)
+		default:
+			kvs = append(kvs, &CompatibleKVMeta{Key: key, Value: []byte(scalarString(x))})
+		}
+	}
+	return kvs
+}
+
+func encodeKVTree(w io.Writer, format string, tree map[string]any) error {
+	switch format {
+	case FormatYAML:
+		return yaml.NewEncoder(w).Encode(tree)
+	case FormatJSONTree:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(tree)
+	}
+	// hcl
+	f := hclwrite.NewEmptyFile()
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !hclsyntax.ValidIdentifier(name) {
+			return &DomainError{Code: DomainErrorCodeInvalidInput, Message: name + " is not a valid hcl attribute name, choose a deeper root"}
+		}
+		f.Body().SetAttributeValue(name, toCtyValue(tree[name]))
+	}
+	_, err := f.WriteTo(w)
+	return err
+}
+
+func toCtyValue(v any) cty.Value {
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			return cty.EmptyObjectVal
+		}
+		attrs := make(map[string]cty.Value, len(x))
+		for name, child := range x {
+			attrs[name] = toCtyValue(child)
+		}
+		return cty.ObjectVal(attrs)
+	case bool:
+		return cty.BoolVal(x)
+	case int64:
+		return cty.NumberIntVal(x)
+	case float64:
+		return cty.NumberFloatVal(x)
+	}
+	return cty.StringVal(scalarString(v))
+}
+
+func decodeKVTree(r io.Reader, format string) (map[string]any, error) {
+	var tree map[string]any
+	switch format {
+	case FormatYAML:
+		if err := yaml.NewDecoder(r).Decode(&tree); err != nil && err != io.EOF {
+			return nil, invalidDocument(format, err)
+		}
+	case FormatJSONTree:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, invalidDocument(format, err)
+		}
+	case FormatHCL:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if tree, err = ParseHCLObject(string(b)); err != nil {
+			return nil, invalidDocument(format, err)
+		}
+	}
+	if tree == nil {
+		tree = map[string]any{}
+	}
+	return tree, nil
+}
+
+// flatKey converts the path of key under root to a flat key.
+func flatKey(format, rel, sep string) string {
+	key := strings.ReplaceAll(rel, "/", sep)
+	if format != FormatEnv {
+		return key
+	}
+	// environment variables only allow letters, digits and underscores
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// flatPath converts a flat key back to the path under root, see decodeFlat.
+func flatPath(format, key, sep string) string {
+	if format == FormatEnv {
+		key = strings.ToLower(key)
+	}
+	return strings.Trim(strings.ReplaceAll(key, sep, "/"), "/")
+}
+
+// encodeFlat writes kvs under root as a .properties or .env file. Folders are skipped.
+// Keys which would be imported as other keys, e.g. with the separator in their names, are rejected
+// before anything is written.
+func encodeFlat(w io.Writer, format string, kvs []*GetValueResponse, root, sep string) error {
+	for _, kv := range kvs {
+		rel := strings.TrimPrefix(kv.Key, root)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		if back := flatPath(format, flatKey(format, rel, sep), sep); back != rel {
+			return &DomainError{
+				Code:    DomainErrorCodeInvalidInput,
+				Message: fmt.Sprintf("key %s would be imported as %s%s, choose another separator or format", kv.Key, root, back),
+			}
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, kv := range kvs {
+		rel := strings.TrimPrefix(kv.Key, root)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		key := flatKey(format, rel, sep)
+		if format == FormatEnv {
+			bw.WriteString(key + "=" + quoteEnvValue(kv.Value) + "\n")
+		} else {
+			bw.WriteString(escapeProperty(key, true) + "=" + escapeProperty(kv.Value, false) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// escapeProperty escapes a key or value of .properties files,
+// non-ASCII characters included since they are read in ISO-8859-1 by default.
+func escapeProperty(s string, isKey bool) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if isKey || i == 0 {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(r)
+		case ' ':
+			if isKey || i == 0 {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				if r > 0xffff {
+					// surrogate pairs
+					r -= 0x10000
+					fmt.Fprintf(&sb, `\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+					continue
+				}
+				fmt.Fprintf(&sb, `\u%04x`, r)
+				continue
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func quoteEnvValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\r\"'\\#$`=") {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// decodeFlat parses a .properties or .env file. Keys are split by sep into folders under root.
+// Keys of .env files are lower-cased, the same way Spring binds environment variables.
+func decodeFlat(r io.Reader, format, root, sep string) (CompatibleKVMetaList, error) {
+	var (
+		pairs [][2]string
+		err   error
+	)
+	if format == FormatEnv {
+		pairs, err = parseEnv(r)
+	} else {
+		pairs, err = parseProperties(r)
+	}
+	if err != nil {
+		return nil, invalidDocument(format, err)
+	}
+	kvs := make(CompatibleKVMetaList, 0, len(pairs))
+	for _, p := range pairs {
+		key := flatPath(format, p[0], sep)
+		if key == "" {
+			continue
+		}
+		kvs = append(kvs, &CompatibleKVMeta{Key: root + key, Value: []byte(p[1])})
+	}
+	return kvs, nil
+}
+
+// parseProperties parses the format of java.util.Properties.
+func parseProperties(r io.Reader) ([][2]string, error) {
+	var pairs [][2]string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	var logical strings.Builder
+	for sc.Scan() {
+		line := strings.TrimLeft(sc.Text(), " \t\f")
+		if logical.Len() == 0 && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// an odd number of trailing backslashes continues the line
+		n := len(line) - len(strings.TrimRight(line, `\`))
+		if n%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+		key, value := splitProperty(logical.String())
+		logical.Reset()
+		k, err := unescapeProperty(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := unescapeProperty(value)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, [2]string{k, v})
+	}
+	if logical.Len() > 0 {
+		key, value := splitProperty(logical.String())
+		k, _ := unescapeProperty(key)
+		v, _ := unescapeProperty(value)
+		pairs = append(pairs, [2]string{k, v})
+	}
+	return pairs, sc.Err()
+}
+
+// splitProperty splits a logical line at the first unescaped '=', ':' or whitespace.
+// Whitespace around the separator is skipped.
+func splitProperty(line string) (key, value string) {
+	i := 0
+	for ; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+	}
+	if i >= len(line) {
+		return line, ""
+	}
+	value = strings.TrimLeft(line[i:], " \t\f")
+	if value != "" && (value[0] == '=' || value[0] == ':') {
+		value = strings.TrimLeft(value[1:], " \t\f")
+	}
+	return line[:i], value
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	var high rune
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			x, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			i += 4
+			r := rune(x)
+			switch {
+			case r >= 0xd800 && r < 0xdc00:
+				high = r
+				continue
+			case r >= 0xdc00 && r < 0xe000 && high != 0:
+				r = 0x10000 + (high-0xd800)<<10 + (r - 0xdc00)
+			}
+			high = 0
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// parseEnv parses KEY=VALUE lines of .env files. Values could be quoted,
+// double-quoted values support escapes, and "export " before keys is ignored.
+func parseEnv(r io.Reader) ([][2]string, error) {
+	var pairs [][2]string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			uq, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value = uq
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			// inline comments of unquoted values
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, sc.Err()
+}
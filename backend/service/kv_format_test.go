@@ -0,0 +1,134 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+)
+
+var formatTestKVs = []*GetValueResponse{
+	{Key: "app/"},
+	{Key: "app/db/host", Value: "db.internal"},
+	{Key: "app/db/max_conn", Value: "100"},
+	{Key: "app/debug", Value: "true"},
+	{Key: "app/empty/"},
+	{Key: "app/greeting", Value: "héllo = wörld 😀\n#not a comment"},
+	{Key: "app/name", Value: " padded "},
+	{Key: "app/quote", Value: `say "hi" $HOME \ 'x'`},
+}
+
+// roundTrip exports kvs under root in format and imports the document back.
+func roundTrip(t *testing.T, format, root string, typed bool) map[string]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if isTreeFormat(format) {
+		tree, err := buildKVTree(formatTestKVs, root, typed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = encodeKVTree(&buf, format, tree); err != nil {
+			t.Fatal(err)
+		}
+	} else if err := encodeFlat(&buf, format, formatTestKVs, root, defaultSeparator(format)); err != nil {
+		t.Fatal(err)
+	}
+	kvs, err := decodeNative(&ImportRequest{Format: format, Root: root, File: bytes.NewReader(buf.Bytes()), Size: int64(buf.Len())})
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", format, err, buf.String())
+	}
+	values := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		values[kv.Key] = string(kv.Value)
+	}
+	return values
+}
+
+func TestNativeFormatRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatYAML, FormatJSONTree, FormatHCL, FormatProperties, FormatEnv} {
+		for _, typed := range []bool{false, true} {
+			values := roundTrip(t, format, "app/", typed)
+			for _, kv := range formatTestKVs {
+				if kv.Key == "app/" || (strings.HasSuffix(kv.Key, "/") && !isTreeFormat(format)) {
+					// flat formats have no folders
+					continue
+				}
+				if v, ok := values[kv.Key]; !ok || v != kv.Value {
+					t.Errorf("%s (typed %v): %s = %q, want %q", format, typed, kv.Key, v, kv.Value)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeFlatLossyKeys(t *testing.T) {
+	kvs := []*GetValueResponse{{Key: "app/max_conn", Value: "1"}}
+	if err := encodeFlat(&bytes.Buffer{}, FormatEnv, kvs, "app/", "_"); err == nil {
+		t.Error("max_conn would be imported as max/conn with the separator _")
+	}
+	kvs = []*GetValueResponse{{Key: "app/Name", Value: "1"}}
+	if err := encodeFlat(&bytes.Buffer{}, FormatEnv, kvs, "app/", "__"); err == nil {
+		t.Error("Name would be imported as name from env")
+	}
+	kvs = []*GetValueResponse{{Key: "app/v1.2", Value: "1"}}
+	if err := encodeFlat(&bytes.Buffer{}, FormatProperties, kvs, "app/", "."); err == nil {
+		t.Error("v1.2 would be imported as v1/2 with the separator .")
+	}
+	if err := encodeFlat(&bytes.Buffer{}, FormatProperties, kvs, "app/", "/"); err != nil {
+		t.Errorf("v1.2 should be kept with the separator /: %v", err)
+	}
+}
+
+func TestParseFlat(t *testing.T) {
+	pairs, err := parseProperties(strings.NewReader("# comment\n! comment\na.b = 1\nc:2\nd 3\nlong = x\\\n    y\nkey\\ with\\ spaces=\\u00e9\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{{"a.b", "1"}, {"c", "2"}, {"d", "3"}, {"long", "xy"}, {"key with spaces", "é"}}
+	if len(pairs) != len(want) {
+		t.Fatalf("pairs = %q, want %q", pairs, want)
+	}
+	for i := range want {
+		if pairs[i] != want[i] {
+			t.Errorf("pairs[%d] = %q, want %q", i, pairs[i], want[i])
+		}
+	}
+
+	pairs, err = parseEnv(strings.NewReader("# comment\nexport A=1\nB=\"x\\ny\"\nC='$raw'\nD=v # inline\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][2]string{{"A", "1"}, {"B", "x\ny"}, {"C", "$raw"}, {"D", "v"}}
+	if len(pairs) != len(want) {
+		t.Fatalf("pairs = %q, want %q", pairs, want)
+	}
+	for i := range want {
+		if pairs[i] != want[i] {
+			t.Errorf("pairs[%d] = %q, want %q", i, pairs[i], want[i])
+		}
+	}
+	if _, err = parseEnv(strings.NewReader("MISSING\n")); err == nil {
+		t.Error("a line without = should be rejected")
+	}
+}
+
+func TestImportNativeInternalKeys(t *testing.T) {
+	doc := []byte(".consee-internal:\n  kvmeta:\n    x: y\napp:\n  name: demo\n")
+	kv := &fakeKVService{values: map[string]string{}}
+	s := NewA2(kv, nil, &fakeAdminService{}, nil, nil, "")
+	resp, err := s.Import(context.Background(), &ImportRequest{Format: FormatYAML, File: bytes.NewReader(doc), Size: int64(len(doc))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.values[".consee-internal/kvmeta/x"]; ok || kv.values["app/name"] != "demo" {
+		t.Errorf("unexpected values: %v", kv.values)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Param != ".consee-internal/kvmeta/x" {
+		t.Errorf("internal keys should be reported: %+v", resp.Errors)
+	}
+}
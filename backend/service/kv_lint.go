@@ -0,0 +1,319 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Value types with a parser. Other value types (plaintext, ini, ...) are written as they are.
+const (
+	ValueTypeJSON       = "json"
+	ValueTypeYAML       = "yaml"
+	ValueTypeHCL        = "hcl"
+	ValueTypeXML        = "xml"
+	ValueTypeProperties = "properties"
+	ValueTypeTOML       = "toml"
+	// ValueTypeBinary is the value type of values written with KVEncodingBase64.
+	ValueTypeBinary = "binary"
+)
+
+// lintValue checks the syntax of a value by its value type.
+// checked is false if the value type has no parser.
+func lintValue(valueType, value string) (checked bool, errs []KVLintError) {
+	// an empty value is a placeholder to be filled later
+	if value == "" {
+		return true, nil
+	}
+	switch valueType {
+	case ValueTypeJSON:
+		return true, lintJSON(value)
+	case ValueTypeYAML:
+		return true, lintYAML(value)
+	case ValueTypeHCL:
+		return true, lintHCL(value)
+	case ValueTypeXML:
+		return true, lintXML(value)
+	case ValueTypeProperties:
+		return true, lintProperties(value)
+	case ValueTypeTOML:
+		return true, lintTOML(value)
+	}
+	return false, nil
+}
+
+// position returns the 1-based line and column (in runes) of the byte offset.
+func position(value string, offset int) (line, column int) {
+	offset = max(0, min(offset, len(value)))
+	before := value[:offset]
+	line = strings.Count(before, "\n") + 1
+	column = utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return line, column
+}
+
+func lintJSON(value string) []KVLintError {
+	var v any
+	err := json.Unmarshal([]byte(value), &v)
+	if err == nil {
+		return nil
+	}
+	var serr *json.SyntaxError
+	if !errors.As(err, &serr) {
+		return []KVLintError{{Message: err.Error()}}
+	}
+	// Offset is after the byte where the error occurs
+	line, column := position(value, int(serr.Offset)-1)
+	return []KVLintError{{Line: line, Column: column, Message: serr.Error()}}
+}
+
+func lintYAML(value string) []KVLintError {
+	_, err := parser.ParseBytes([]byte(value), 0)
+	if err == nil {
+		return nil
+	}
+	var yerr yaml.Error
+	if !errors.As(err, &yerr) || yerr.GetToken() == nil {
+		return []KVLintError{{Message: err.Error()}}
+	}
+	pos := yerr.GetToken().Position
+	return []KVLintError{{Line: pos.Line, Column: pos.Column, Message: yerr.GetMessage()}}
+}
+
+func lintHCL(value string) []KVLintError {
+	_, diags := hclsyntax.ParseConfig([]byte(value), "value", hcl.InitialPos)
+	var errs []KVLintError
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError {
+			continue
+		}
+		e := KVLintError{Message: d.Summary}
+		if d.Detail != "" {
+			e.Message += ": " + d.Detail
+		}
+		if d.Subject != nil {
+			e.Line, e.Column = d.Subject.Start.Line, d.Subject.Start.Column
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+func lintXML(value string) []KVLintError {
+	d := xml.NewDecoder(strings.NewReader(value))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			line, column := d.InputPos()
+			var serr *xml.SyntaxError
+			if errors.As(err, &serr) {
+				return []KVLintError{{Line: line, Column: column, Message: serr.Msg}}
+			}
+			return []KVLintError{{Line: line, Column: column, Message: err.Error()}}
+		}
+	}
+}
+
+// lintTOML reports the first error of a TOML document, including keys and tables defined twice.
+func lintTOML(value string) []KVLintError {
+	var v map[string]any
+	_, err := toml.Decode(value, &v)
+	if err == nil {
+		return nil
+	}
+	var perr toml.ParseError
+	if !errors.As(err, &perr) {
+		return []KVLintError{{Message: err.Error()}}
+	}
+	line, column := position(value, perr.Position.Start)
+	return []KVLintError{{Line: line, Column: column, Message: perr.Message}}
+}
+
+// lintProperties reports invalid escapes, the only syntax error of a properties file.
+func lintProperties(value string) []KVLintError {
+	var errs []KVLintError
+	var logical strings.Builder
+	start := 0
+	for i, line := range strings.Split(value, "\n") {
+		line = strings.TrimLeft(strings.TrimSuffix(line, "\r"), " \t\f")
+		if logical.Len() == 0 {
+			if line == "" || line[0] == '#' || line[0] == '!' {
+				continue
+			}
+			start = i + 1
+		}
+		n := len(line) - len(strings.TrimRight(line, `\`))
+		if n%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+		key, v := splitProperty(logical.String())
+		logical.Reset()
+		for _, s := range []string{key, v} {
+			if _, err := unescapeProperty(s); err != nil {
+				errs = append(errs, KVLintError{Line: start, Message: err.Error()})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// formatValue returns the value in the canonical style of its value type.
+// ok is false if the value type has no formatter.
+// YAML is not formatted since comments and anchors would be lost.
+func formatValue(valueType, value string) (formatted string, ok bool) {
+	if value == "" {
+		return "", false
+	}
+	switch valueType {
+	case ValueTypeJSON:
+		var b bytes.Buffer
+		if err := json.Indent(&b, []byte(value), "", "  "); err != nil {
+			return "", false
+		}
+		return b.String(), true
+	case ValueTypeHCL:
+		return string(hclwrite.Format([]byte(value))), true
+	}
+	return "", false
+}
+
+func invalidValue(valueType string, errs []KVLintError) error {
+	e := errs[0]
+	msg := "invalid " + valueType + " value: "
+	if e.Line > 0 {
+		msg += "line " + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			msg += ", column " + strconv.Itoa(e.Column)
+		}
+		msg += ": "
+	}
+	msg += e.Message
+	if len(errs) > 1 {
+		msg += " (and " + strconv.Itoa(len(errs)-1) + " more errors)"
+	}
+	return &DomainError{Code: DomainErrorCodeInvalidInput, Message: msg}
+}
+
+// checkValue validates the value by its value type, and formats it if required.
+func checkValue(valueType, value string, format bool) (string, error) {
+	if _, errs := lintValue(valueType, value); len(errs) > 0 {
+		return "", invalidValue(valueType, errs)
+	}
+	if format {
+		if formatted, ok := formatValue(valueType, value); ok {
+			return formatted, nil
+		}
+	}
+	return value, nil
+}
+
+// prepareValue validates the value to write to key by its value type and schema, and formats it if required.
+func (s *kvService) prepareValue(ctx context.Context, key, valueType, value string, format bool) (string, error) {
+	value, err := checkValue(valueType, value, format)
+	if err != nil {
+		return "", err
+	}
+	return value, s.checkSchema(ctx, key, valueType, value)
+}
+
+// prepareWrite prepares the value to write to key by prepareValue, and encrypts it if key is under the encrypted prefixes.
+// Encrypted values, e.g. of exports, are validated by their plaintext and written sealed as seal does,
+// so values which could not be decrypted with the keyring are rejected rather than written unchecked.
+func (s *kvService) prepareWrite(ctx context.Context, key, valueType, value string, format bool) (string, error) {
+	if encrypt.IsSealed([]byte(value)) {
+		plaintext, err := s.encryption.open([]byte(value))
+		if err != nil {
+			return "", &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid encrypted value: " + err.Error()}
+		}
+		if _, err = s.prepareValue(ctx, key, valueType, string(plaintext), false); err != nil {
+			return "", err
+		}
+	} else {
+		var err error
+		if value, err = s.prepareValue(ctx, key, valueType, value, format); err != nil {
+			return "", err
+		}
+	}
+	sealed, err := s.encryption.seal(key, []byte(value))
+	return string(sealed), err
+}
+
+func (s *kvService) CheckValue(ctx context.Context, key, value, valueType string) error {
+	if valueType == "" {
+		valueType = s.valueType(ctx, key)
+	}
+	_, err := s.prepareWrite(ctx, key, valueType, value, false)
+	return err
+}
+
+// valueType returns the value type of the key, or "" if it has none or it could not be read,
+// so that a failure of the admin token does not block writes. Failures are logged by GetValueType.
+func (s *kvService) valueType(ctx context.Context, key string) string {
+	vt, _ := s.admin.GetValueType(ctx, base64.StdEncoding.EncodeToString([]byte(key)))
+	return vt
+}
+
+func (s *kvService) Lint(ctx context.Context, key string) (*KVLintResult, error) {
+	if strings.HasSuffix(key, "/") {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "folders have no value"}
+	}
+	resp, err := s.kv.Read(ctx, key)
+	if err != nil {
+		slog.Error("kvLint: failed to get key", "key", key, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	if resp.Status == http.StatusNotFound || resp.Body == nil {
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "key not found"}
+	}
+	value := string(resp.Body.Value)
+	if encrypt.IsSealed(resp.Body.Value) {
+		plaintext, err := s.encryption.open(resp.Body.Value)
+		if err != nil {
+			return nil, err
+		}
+		value = string(plaintext)
+	}
+	result := &KVLintResult{Key: key, ValueType: s.valueType(ctx, key), Errors: []KVLintError{}}
+	if result.ValueType == "" {
+		result.ValueType = "plaintext"
+	}
+	var errs []KVLintError
+	result.Checked, errs = lintValue(result.ValueType, value)
+	if len(errs) > 0 {
+		result.Errors = errs
+		return result, nil
+	}
+	if formatted, ok := formatValue(result.ValueType, value); ok && formatted != value {
+		result.Formatted = formatted
+	}
+	return result, nil
+}
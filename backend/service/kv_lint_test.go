@@ -0,0 +1,147 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+const validTOML = `# a comment
+title = "TOML \u00e9 example"
+
+[owner]
+name = 'Tom'
+dob = 1979-05-27T07:32:00-08:00
+"quoted key" = 1
+
+[database]
+ports = [ 8000, 8001,
+  8002, ] # a trailing comma
+data = [ ["delta", "phi"], [3.14] ]
+temp_targets = { cpu = 79.5, case = 72.0 }
+enabled = true
+hex = 0xdead_beef
+oct = 0o755
+bin = 0b1101
+big = 9_223_372_036_854_775_807
+inf = -inf
+exp = 6.626e-34
+local = 1979-05-27T07:32:00.999
+date = 1979-05-27
+time = 07:32:00
+leap = 1990-12-31T23:59:59Z
+
+[servers.alpha]
+ip = "10.0.0.1"
+a.b.c = 1
+a.b.d = 2
+text = """
+multi \
+  line"""
+raw = '''C:\path\n'''
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+[products.size]
+length = 1
+`
+
+func TestLintValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		valueType string
+		value     string
+		// line and column of the first error, 0 if the value is valid
+		line, column int
+	}{
+		{"empty", ValueTypeJSON, "", 0, 0},
+		{"json", ValueTypeJSON, `{"a": [1, 2]}`, 0, 0},
+		{"json invalid", ValueTypeJSON, "{\n  \"a\": 1,\n}", 3, 1},
+		{"yaml", ValueTypeYAML, "a:\n  - 1\n", 0, 0},
+		{"yaml invalid", ValueTypeYAML, "a: [1, 2\nb: 3\n", 2, 1},
+		{"hcl", ValueTypeHCL, "a = 1\nb {\n  c = \"d\"\n}\n", 0, 0},
+		{"hcl invalid", ValueTypeHCL, "a = 1\nb = \n", 2, 5},
+		{"xml", ValueTypeXML, "<a><b>c</b></a>", 0, 0},
+		{"xml invalid", ValueTypeXML, "<a>\n<b></a>", 2, 8},
+		{"properties", ValueTypeProperties, "a = 1\nb = multi\\\n  line\n", 0, 0},
+		{"properties invalid", ValueTypeProperties, "# comment\na = \\u12\n", 2, 0},
+		{"toml", ValueTypeTOML, validTOML, 0, 0},
+		{"toml duplicate key", ValueTypeTOML, "a = 1\na = 2\n", 2, 1},
+		{"toml duplicate table", ValueTypeTOML, "[a]\nb = 1\n[a]\n", 3, 2},
+		{"toml table over value", ValueTypeTOML, "x = 1\n[x]\n", 2, 2},
+		{"toml unterminated string", ValueTypeTOML, "a = \"abc\n", 1, 9},
+		{"toml invalid escape", ValueTypeTOML, `a = "\q"`, 1, 6},
+		{"toml two values on a line", ValueTypeTOML, "a = 1 b = 2\n", 1, 6},
+		{"toml missing value", ValueTypeTOML, "a =\n", 1, 4},
+		{"toml invalid date", ValueTypeTOML, "a = 2021-13-01\n", 1, 5},
+		{"toml integer out of range", ValueTypeTOML, "a = 9223372036854775808\n", 1, 5},
+		{"toml leading zero", ValueTypeTOML, "a = 0123\n", 1, 5},
+		{"toml unclosed array", ValueTypeTOML, "a = [1, 2\nb = 3\n", 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checked, errs := lintValue(tt.valueType, tt.value)
+			if !checked {
+				t.Fatalf("value type %s not checked", tt.valueType)
+			}
+			if tt.line == 0 {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %+v", errs)
+				}
+				return
+			}
+			if len(errs) == 0 {
+				t.Fatal("expected an error")
+			}
+			if errs[0].Line != tt.line || errs[0].Column != tt.column {
+				t.Errorf("error at %d:%d, want %d:%d: %s", errs[0].Line, errs[0].Column, tt.line, tt.column, errs[0].Message)
+			}
+		})
+	}
+	if checked, _ := lintValue("plaintext", "anything {"); checked {
+		t.Error("plaintext should not be checked")
+	}
+}
+
+func TestCheckValue(t *testing.T) {
+	formatted, err := checkValue(ValueTypeJSON, `{"a":1}`, true)
+	if err != nil || formatted != "{\n  \"a\": 1\n}" {
+		t.Errorf("formatted json = %q, %v", formatted, err)
+	}
+	_, err = checkValue(ValueTypeTOML, "a = 1\na = 2", false)
+	var derr *DomainError
+	if !errors.As(err, &derr) || derr.Code != DomainErrorCodeInvalidInput || !strings.Contains(derr.Message, "line 2, column 1") {
+		t.Errorf("invalid toml error = %v", err)
+	}
+
+	ctx := context.Background()
+	b64 := func(key string) string { return base64.StdEncoding.EncodeToString([]byte(key)) }
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	admin := &fakeAdminService{}
+	s := NewKVService(kv, nil, admin, nil)
+	if err := s.Create(ctx, &CreateKeyValueRequest{Key: "app/config", Value: "a = 1", ValueType: ValueTypeTOML}); err != nil {
+		t.Fatal(err)
+	}
+	if vt := admin.valueTypes[b64("app/config")]; vt != ValueTypeTOML {
+		t.Errorf("value type = %q, want it written under the base64 key", vt)
+	}
+	// the stored value type is used when none is given
+	if err := s.CheckValue(ctx, "app/config", "a = ", ""); err == nil {
+		t.Error("expected an invalid toml value")
+	}
+	// keys without a value type are written as they are
+	if err := s.CheckValue(ctx, "app/other", "a = ", ""); err != nil {
+		t.Errorf("key without a value type: %v", err)
+	}
+}
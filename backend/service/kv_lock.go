@@ -0,0 +1,276 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+const (
+	AuditActionLockForceRelease = "lock-force-release"
+
+	// checkoutSessionPrefix marks sessions created by checkouts, followed by the actor.
+	checkoutSessionPrefix = "consee checkout: "
+	defaultCheckoutTTL    = 5 * time.Minute
+)
+
+// LockService checks out keys for editing with sessions, so that others could see they are locked.
+// Locks are advisory, writes of others are not blocked.
+type LockService interface {
+	Checkout(ctx context.Context, req *CheckoutKVRequest) (*KVLock, error)
+	// Renew keeps the checkout alive for another TTL.
+	Renew(ctx context.Context, session string) error
+	// Release ends the checkout of key without changing values.
+	Release(ctx context.Context, key, session string) error
+	// ListLocks lists locked keys under prefix, including locks of other applications.
+	ListLocks(ctx context.Context, prefix string) ([]KVLock, error)
+	// ForceRelease destroys the session holding the lock of key,
+	// so all locks held by the session are released, e.g. a stale leader election.
+	ForceRelease(ctx context.Context, key string) error
+}
+
+type lockService struct {
+	kv      repo.KVRepo
+	session repo.SessionRepo
+	acl     repo.ACLRepo
+	admin   AdminService
+}
+
+func NewLockService(kv repo.KVRepo, session repo.SessionRepo, acl repo.ACLRepo, admin AdminService) LockService {
+	return &lockService{kv: kv, session: session, acl: acl, admin: admin}
+}
+
+func (s *lockService) read(ctx context.Context, key string) (*consul.KVPair, error) {
+	resp, err := s.kv.Read(ctx, key)
+	if err != nil {
+		slog.Error("failed to read key for lock", "key", key, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	return resp.Body, nil
+}
+
+// maxCheckoutKeys is the max number of keys checked out at once,
+// since each key takes two operations in a transaction.
+const maxCheckoutKeys = consul.MaxTxnOps / 2
+
+// Checkout locks the key, or the existing keys under the folder, with a new session in a transaction.
+func (s *lockService) Checkout(ctx context.Context, req *CheckoutKVRequest) (*KVLock, error) {
+	if req.Key == "" || strings.HasPrefix(req.Key, ConseeInternalKeyPrefix) {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid key"}
+	}
+	ttl := defaultCheckoutTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 10*time.Second || ttl > 24*time.Hour {
+			return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "ttl should be a duration from 10s to 24h"}
+		}
+	}
+	pairs, err := readKVTree(ctx, s.kv, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "key not found"}
+	}
+	if len(pairs) > maxCheckoutKeys {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "at most " + strconv.Itoa(maxCheckoutKeys) + " keys could be checked out at once"}
+	}
+	for _, pair := range pairs {
+		if pair.Session != "" {
+			return nil, &DomainError{Code: DomainErrorCodeConflict, Message: pair.Key + " is locked by " + s.holder(ctx, pair.Session)}
+		}
+	}
+
+	actor := currentActor(ctx, s.acl, s.admin)
+	created, err := s.session.CreateSession(ctx, &consul.SessionEntry{
+		Name:      checkoutSessionPrefix + actor,
+		TTL:       ttl.String(),
+		Behavior:  consul.SessionBehaviorRelease,
+		LockDelay: "0s",
+	})
+	if err != nil {
+		slog.Error("failed to create session for checkout", "key", req.Key, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if created.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	if created.Status != http.StatusOK {
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to create session: " + string(created.RawBody)}
+	}
+	id := created.Body
+
+	// the current values and flags are written back, and check-index guards them against changes since the read
+	ops := make([]*consul.KVTxnOp, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		ops = append(ops,
+			&consul.KVTxnOp{Verb: consul.KVCheckIndex, Key: pair.Key, Index: pair.ModifyIndex},
+			&consul.KVTxnOp{Verb: consul.KVLock, Key: pair.Key, Value: pair.Value, Flags: pair.Flags, Session: id},
+		)
+	}
+	resp, err := s.kv.Txn(ctx, ops)
+	if err == nil && resp.Status == http.StatusOK {
+		lock := &KVLock{Key: req.Key, Session: id, Holder: actor, TTL: ttl.String(), Checkout: true}
+		if resp.Body != nil {
+			for _, result := range resp.Body.Results {
+				if result.KV != nil && result.KV.Key == req.Key {
+					lock.LockIndex = result.KV.LockIndex
+				}
+			}
+		}
+		return lock, nil
+	}
+	s.session.DestroySession(ctx, id)
+	switch {
+	case err != nil:
+		slog.Error("failed to lock keys", "key", req.Key, "error", err)
+		return nil, errFailedToConnectConsul
+	case resp.Status == http.StatusForbidden:
+		return nil, errPermissionDenied
+	case resp.Status == http.StatusConflict:
+		return nil, &DomainError{Code: DomainErrorCodeConflict, Message: "the key is locked or changed by others, please try again: " + txnConflicts(resp.Body, ops)}
+	}
+	return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to lock keys: " + string(resp.RawBody)}
+}
+
+// holder returns the name of the session, or its ID if the name is empty or the session could not be read.
+func (s *lockService) holder(ctx context.Context, id string) string {
+	resp, err := s.session.ReadSession(ctx, id)
+	if err != nil || resp.Body == nil || resp.Body.Name == "" {
+		return "session " + id
+	}
+	return strings.TrimPrefix(resp.Body.Name, checkoutSessionPrefix)
+}
+
+func (s *lockService) Renew(ctx context.Context, session string) error {
+	resp, err := s.session.RenewSession(ctx, session)
+	if err != nil {
+		slog.Error("failed to renew session", "session", session, "error", err)
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errPermissionDenied
+	case http.StatusNotFound:
+		return &DomainError{Code: DomainErrorCodeNotFound, Message: "the checkout has expired"}
+	}
+	return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to renew session: " + string(resp.RawBody)}
+}
+
+// Release destroys the session of the checkout, so all keys it locked are released without changing their values.
+func (s *lockService) Release(ctx context.Context, key, session string) error {
+	pairs, err := readKVTree(ctx, s.kv, key)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(pairs, func(pair *consul.KVPair) bool { return pair.Session == session }) {
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "the key is not locked by the session"}
+	}
+	return destroyCheckout(ctx, s.session, session)
+}
+
+// destroyCheckout destroys the session of a checkout, which releases its locks.
+func destroyCheckout(ctx context.Context, sessions repo.SessionRepo, session string) error {
+	resp, err := sessions.DestroySession(ctx, session)
+	if err != nil {
+		slog.Error("failed to destroy session", "session", session, "error", err)
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errPermissionDenied
+	}
+	return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to destroy session: " + string(resp.RawBody)}
+}
+
+func (s *lockService) ListLocks(ctx context.Context, prefix string) ([]KVLock, error) {
+	resp, err := s.kv.List(ctx, prefix)
+	if err != nil {
+		slog.Error("failed to list keys for locks", "prefix", prefix, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	locks := []KVLock{}
+	for _, pair := range resp.Body {
+		if pair.Session != "" && !strings.HasPrefix(pair.Key, ConseeInternalKeyPrefix) {
+			locks = append(locks, KVLock{Key: pair.Key, Session: pair.Session, LockIndex: pair.LockIndex})
+		}
+	}
+	if len(locks) == 0 {
+		return locks, nil
+	}
+	sessions, err := s.session.ListSessions(ctx)
+	if err != nil || sessions.Status != http.StatusOK {
+		// locks are still listed without holders, e.g. if the token could not read sessions
+		slog.Warn("failed to list sessions", "error", err)
+		return locks, nil
+	}
+	entries := make(map[string]*consul.SessionEntry, len(sessions.Body))
+	for _, se := range sessions.Body {
+		entries[se.ID] = se
+	}
+	for i := range locks {
+		se := entries[locks[i].Session]
+		if se == nil {
+			continue
+		}
+		locks[i].Holder = strings.TrimPrefix(se.Name, checkoutSessionPrefix)
+		locks[i].Node = se.Node
+		locks[i].TTL = se.TTL
+		locks[i].Checkout = strings.HasPrefix(se.Name, checkoutSessionPrefix)
+	}
+	return locks, nil
+}
+
+func (s *lockService) ForceRelease(ctx context.Context, key string) error {
+	pair, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+	if pair == nil || pair.Session == "" {
+		return &DomainError{Code: DomainErrorCodeNotFound, Message: "the key is not locked"}
+	}
+	holder := s.holder(ctx, pair.Session)
+	resp, err := s.session.DestroySession(ctx, pair.Session)
+	if err != nil {
+		slog.Error("failed to destroy session", "session", pair.Session, "error", err)
+		return errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return errPermissionDenied
+	}
+	if resp.Status != http.StatusOK {
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to destroy session: " + string(resp.RawBody)}
+	}
+
+	err = s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: AuditActionLockForceRelease,
+		Target: key,
+		Detail: "session " + pair.Session + " held by " + holder,
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", AuditActionLockForceRelease, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// fakeSessionRepo releases locks of destroyed sessions in kv.
+type fakeSessionRepo struct {
+	repo.SessionRepo
+	kv       *fakeKVRepo
+	sessions map[string]*consul.SessionEntry
+	next     int
+}
+
+func (f *fakeSessionRepo) CreateSession(ctx context.Context, se *consul.SessionEntry) (*consul.Response[string], error) {
+	f.next++
+	id := "session-" + strconv.Itoa(f.next)
+	se.ID = id
+	f.sessions[id] = se
+	return &consul.Response[string]{Status: http.StatusOK, Body: id}, nil
+}
+
+func (f *fakeSessionRepo) DestroySession(ctx context.Context, id string) (*consul.Response[bool], error) {
+	delete(f.sessions, id)
+	for _, pair := range f.kv.pairs {
+		if pair.Session == id {
+			pair.Session = ""
+		}
+	}
+	return &consul.Response[bool]{Status: http.StatusOK, Body: true}, nil
+}
+
+func (f *fakeSessionRepo) ReadSession(ctx context.Context, id string) (*consul.Response[*consul.SessionEntry], error) {
+	se, ok := f.sessions[id]
+	if !ok {
+		return &consul.Response[*consul.SessionEntry]{Status: http.StatusNotFound}, nil
+	}
+	return &consul.Response[*consul.SessionEntry]{Status: http.StatusOK, Body: se}, nil
+}
+
+func TestCheckout(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	kv.put("app/a", "a")
+	kv.put("app/b", "b")
+	kv.pairs["app/b"].Flags = 7
+	kv.put("other", "other")
+	sessions := &fakeSessionRepo{kv: kv, sessions: map[string]*consul.SessionEntry{}}
+	admin := &fakeAdminService{}
+	locks := NewLockService(kv, sessions, fakeACLRepo{}, admin)
+	kvs := NewKVService(kv, sessions, admin, nil)
+
+	code := func(err error) DomainErrorCode {
+		var derr *DomainError
+		if !errors.As(err, &derr) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return derr.Code
+	}
+	lockedBy := func(session string, keys ...string) {
+		t.Helper()
+		for _, key := range keys {
+			if got := kv.pairs[key].Session; got != session {
+				t.Errorf("%s is locked by %q, want %q", key, got, session)
+			}
+		}
+	}
+
+	// a missing folder is not created to be locked
+	_, err := locks.Checkout(ctx, &CheckoutKVRequest{Key: "missing/"})
+	if code(err) != DomainErrorCodeNotFound {
+		t.Errorf("checkout of a missing folder = %v", err)
+	}
+	if _, ok := kv.pairs["missing/"]; ok {
+		t.Error("missing folder created")
+	}
+
+	// a folder checkout locks the keys under it
+	lock, err := locks.Checkout(ctx, &CheckoutKVRequest{Key: "app/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockedBy(lock.Session, "app/a", "app/b")
+	lockedBy("", "other")
+	if string(kv.pairs["app/b"].Value) != "b" || kv.pairs["app/b"].Flags != 7 {
+		t.Errorf("locked key changed: %+v", kv.pairs["app/b"])
+	}
+	if _, err := locks.Checkout(ctx, &CheckoutKVRequest{Key: "app/a"}); code(err) != DomainErrorCodeConflict {
+		t.Errorf("checkout of a locked key = %v", err)
+	}
+
+	// a save ends the checkout
+	if err := kvs.Update(ctx, "app/a", &UpdateValueRequest{Value: "a2", Session: lock.Session}); err != nil {
+		t.Fatal(err)
+	}
+	if string(kv.pairs["app/a"].Value) != "a2" {
+		t.Errorf("value not saved: %q", kv.pairs["app/a"].Value)
+	}
+	lockedBy("", "app/a", "app/b")
+	if _, ok := sessions.sessions[lock.Session]; ok {
+		t.Error("session not destroyed on save")
+	}
+
+	// a release ends the checkout without changing the value
+	lock, err = locks.Checkout(ctx, &CheckoutKVRequest{Key: "app/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockedBy(lock.Session, "app/b")
+	if err := locks.Release(ctx, "app/b", "session-0"); code(err) != DomainErrorCodeConflict {
+		t.Errorf("release by another session = %v", err)
+	}
+	if err := locks.Release(ctx, "app/b", lock.Session); err != nil {
+		t.Fatal(err)
+	}
+	lockedBy("", "app/b")
+	if string(kv.pairs["app/b"].Value) != "b" || kv.pairs["app/b"].Flags != 7 {
+		t.Errorf("released key changed: %+v", kv.pairs["app/b"])
+	}
+
+	// keys changed since they are read are not locked
+	racing := NewLockService(&racingKVRepo{fakeKVRepo: kv, key: "app/b"}, sessions, fakeACLRepo{}, admin)
+	if _, err := racing.Checkout(ctx, &CheckoutKVRequest{Key: "app/"}); code(err) != DomainErrorCodeConflict {
+		t.Errorf("checkout of a changed key = %v", err)
+	}
+	lockedBy("", "app/a", "app/b")
+	if len(sessions.sessions) != 0 {
+		t.Errorf("sessions left: %v", sessions.sessions)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+// maxMoveKeys is the max number of keys moved or copied at once,
+// since each key takes two operations in a transaction.
+const maxMoveKeys = consul.MaxTxnOps / 2
+
+func (s *kvService) Move(ctx context.Context, req *MoveKVRequest) (*KVMoveResult, error) {
+	return s.transfer(ctx, req, true)
+}
+
+func (s *kvService) Copy(ctx context.Context, req *MoveKVRequest) (*KVMoveResult, error) {
+	return s.transfer(ctx, req, false)
+}
+
+func validateMoveRequest(req *MoveKVRequest) error {
+	switch {
+	case req.Source == "" || req.Destination == "":
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "source and destination should not be empty"}
+	case strings.HasPrefix(req.Source, ConseeInternalKeyPrefix) || strings.HasPrefix(req.Destination, ConseeInternalKeyPrefix):
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "internal keys could not be moved or copied"}
+	case strings.HasSuffix(req.Source, "/") != strings.HasSuffix(req.Destination, "/"):
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "source and destination should be both keys or both folders"}
+	case req.Destination == req.Source:
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "destination should not be the source"}
+	// keys are only prefixes of other keys, e.g. app/db of app/db2, so only folders are checked
+	case strings.HasSuffix(req.Source, "/") && strings.HasPrefix(req.Destination, req.Source):
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "destination should not be under the source"}
+	}
+	return nil
+}
+
+// readKVTree reads the key, or keys under the folder.
+func readKVTree(ctx context.Context, kv repo.KVRepo, key string) ([]*consul.KVPair, error) {
+	if strings.HasSuffix(key, "/") {
+		resp, err := kv.List(ctx, key)
+		if err != nil {
+			slog.Error("failed to list keys", "prefix", key, "error", err)
+			return nil, errFailedToConnectConsul
+		}
+		if resp.Status == http.StatusForbidden {
+			return nil, errPermissionDenied
+		}
+		return resp.Body, nil
+	}
+	resp, err := kv.Read(ctx, key)
+	if err != nil {
+		slog.Error("failed to read key", "key", key, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	if resp.Body == nil {
+		return nil, nil
+	}
+	return []*consul.KVPair{resp.Body}, nil
+}
+
+// transfer moves or copies keys in a transaction, with the value types and history versions.
+// Sources are guarded by their ModifyIndex, and destinations should not exist.
+func (s *kvService) transfer(ctx context.Context, req *MoveKVRequest, move bool) (*KVMoveResult, error) {
+	if err := validateMoveRequest(req); err != nil {
+		return nil, err
+	}
+	sources, err := readKVTree(ctx, s.kv, req.Source)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "key not found"}
+	}
+	if len(sources) > maxMoveKeys {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "at most " + strconv.Itoa(maxMoveKeys) + " keys could be moved or copied at once"}
+	}
+	destinations, err := readKVTree(ctx, s.kv, req.Destination)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(destinations))
+	for _, pair := range destinations {
+		existing[pair.Key] = true
+	}
+
+	result := &KVMoveResult{Dryrun: req.Dryrun, Items: make([]KVMoveItem, 0, len(sources))}
+	var conflicts []string
+	for _, pair := range sources {
+		item := KVMoveItem{Source: pair.Key, Destination: req.Destination + strings.TrimPrefix(pair.Key, req.Source)}
+		// existing folders are merged
+		if existing[item.Destination] && !strings.HasSuffix(item.Destination, "/") {
+			item.Exists = true
+			conflicts = append(conflicts, item.Destination)
+		}
+		result.Items = append(result.Items, item)
+	}
+	if req.Dryrun {
+		return result, nil
+	}
+	if len(conflicts) > 0 {
+		return nil, &DomainError{Code: DomainErrorCodeConflict, Message: "destination already exists: " + strings.Join(conflicts, ", ")}
+	}
+
+	ops := make([]*consul.KVTxnOp, 0, 2*len(sources))
+	for i, pair := range sources {
+		dst := result.Items[i].Destination
+		if !strings.HasSuffix(dst, "/") {
+			if err := s.checkSchema(ctx, dst, s.valueType(ctx, pair.Key), string(pair.Value)); err != nil {
+				return nil, err
+			}
+		}
+		if !existing[dst] {
+			// plain values moved under encrypted prefixes are encrypted
+			value := pair.Value
+			if !encrypt.IsSealed(value) {
+				if value, err = s.encryption.seal(dst, value); err != nil {
+					return nil, err
+				}
+			}
+			ops = append(ops, &consul.KVTxnOp{Verb: consul.KVCAS, Key: dst, Value: value, Flags: pair.Flags})
+		}
+		if move {
+			ops = append(ops, &consul.KVTxnOp{Verb: consul.KVDeleteCAS, Key: pair.Key, Index: pair.ModifyIndex})
+		} else {
+			ops = append(ops, &consul.KVTxnOp{Verb: consul.KVCheckIndex, Key: pair.Key, Index: pair.ModifyIndex})
+		}
+	}
+	// metadata of destinations is written before the data, so that moved keys are never seen without their value types,
+	// and it's deleted again if the transaction fails. Metadata may be out of consul, so it's not in the transaction.
+	copied := s.copyKVMeta(ctx, result.Items)
+	if err := s.runTransfer(ctx, req, ops); err != nil {
+		s.deleteKVMeta(ctx, copied)
+		return nil, err
+	}
+	if move {
+		// metadata left behind belongs to no key, so failures are not fatal
+		for _, item := range copied {
+			if err := s.admin.DeleteKVMeta(ctx, base64.StdEncoding.EncodeToString([]byte(item.Source))); err != nil {
+				slog.Warn("kvMove: failed to delete metadata", "key", item.Source, "error", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *kvService) runTransfer(ctx context.Context, req *MoveKVRequest, ops []*consul.KVTxnOp) error {
+	resp, err := s.kv.Txn(ctx, ops)
+	if err != nil {
+		slog.Error("kvMove: failed to run transaction", "source", req.Source, "destination", req.Destination, "error", err)
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errPermissionDenied
+	case http.StatusConflict:
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "nothing is changed, keys have changed: " + txnConflicts(resp.Body, ops)}
+	default:
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to move or copy: " + string(resp.RawBody)}
+	}
+}
+
+// copyKVMeta copies metadata of moved or copied keys, and returns the items copied.
+// Failures are not fatal, the destination is written without its value type as a plain value.
+func (s *kvService) copyKVMeta(ctx context.Context, items []KVMoveItem) []KVMoveItem {
+	var copied []KVMoveItem
+	for _, item := range items {
+		if strings.HasSuffix(item.Source, "/") {
+			continue
+		}
+		b64src := base64.StdEncoding.EncodeToString([]byte(item.Source))
+		if err := s.admin.CopyKVMeta(ctx, b64src, base64.StdEncoding.EncodeToString([]byte(item.Destination))); err != nil {
+			slog.Warn("kvMove: failed to copy metadata", "source", item.Source, "destination", item.Destination, "error", err)
+			continue
+		}
+		copied = append(copied, item)
+	}
+	return copied
+}
+
+// deleteKVMeta deletes metadata copied to destinations which are not written.
+func (s *kvService) deleteKVMeta(ctx context.Context, copied []KVMoveItem) {
+	for _, item := range copied {
+		if err := s.admin.DeleteKVMeta(ctx, base64.StdEncoding.EncodeToString([]byte(item.Destination))); err != nil {
+			slog.Warn("kvMove: failed to delete copied metadata", "key", item.Destination, "error", err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/infra"
+)
+
+// racingKVRepo changes key right before running a transaction.
+type racingKVRepo struct {
+	*fakeKVRepo
+	key string
+}
+
+func (f *racingKVRepo) Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error) {
+	f.put(f.key, "changed")
+	return f.fakeKVRepo.Txn(ctx, ops)
+}
+
+func TestValidateMoveRequest(t *testing.T) {
+	tests := []struct {
+		source, destination string
+		valid               bool
+	}{
+		{"app/db", "app/db2", true},
+		{"app/db2", "app/db", true},
+		{"app/", "app2/", true},
+		{"app/", "other/app/", true},
+		{"app/db", "app/db", false},
+		{"app/", "app/", false},
+		{"app/", "app/sub/", false},
+		{"app/db", "app/db/", false},
+		{"app/db", ".consee-internal/db", false},
+		{"", "app/db", false},
+	}
+	for _, tt := range tests {
+		err := validateMoveRequest(&MoveKVRequest{Source: tt.source, Destination: tt.destination})
+		if (err == nil) != tt.valid {
+			t.Errorf("%s -> %s: valid = %v, want %v (%v)", tt.source, tt.destination, err == nil, tt.valid, err)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+	meta, closer, err := infra.NewBoltMetadata(filepath.Join(t.TempDir(), "consee.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	admin := NewAdminService(nil, meta)
+	b64 := func(key string) string { return base64.StdEncoding.EncodeToString([]byte(key)) }
+	valueType := func(key string) string {
+		vt, err := admin.GetValueType(ctx, b64(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vt
+	}
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	kv.put("app/db", `{"host": "db"}`)
+	kv.pairs["app/db"].Flags = 42
+	kv.put("app/plain", "no value type")
+	kv.put("app/web/port", "80")
+	admin.WriteValueType(ctx, b64("app/db"), ValueTypeJSON)
+	s := NewKVService(kv, nil, admin, nil)
+
+	// a rename, though the source is a prefix of the destination
+	if _, err := s.Move(ctx, &MoveKVRequest{Source: "app/db", Destination: "app/db2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.pairs["app/db"]; ok {
+		t.Error("source not deleted")
+	}
+	if pair := kv.pairs["app/db2"]; pair == nil || string(pair.Value) != `{"host": "db"}` || pair.Flags != 42 {
+		t.Errorf("destination = %+v", pair)
+	}
+	if valueType("app/db") != "" || valueType("app/db2") != ValueTypeJSON {
+		t.Errorf("value types = %q, %q", valueType("app/db"), valueType("app/db2"))
+	}
+
+	// keys without value types are copied without them
+	if _, err := s.Copy(ctx, &MoveKVRequest{Source: "app/plain", Destination: "app/plain2"}); err != nil {
+		t.Fatal(err)
+	}
+	if kv.pairs["app/plain"] == nil || kv.pairs["app/plain2"] == nil || valueType("app/plain2") != "" {
+		t.Errorf("copy without a value type: %v, %v, %q", kv.pairs["app/plain"], kv.pairs["app/plain2"], valueType("app/plain2"))
+	}
+
+	if _, err := s.Copy(ctx, &MoveKVRequest{Source: "app/", Destination: "app/web/"}); err == nil {
+		t.Error("expected copying a folder under itself to fail")
+	}
+
+	// metadata copied before a failed transaction is deleted
+	racing := NewKVService(&racingKVRepo{fakeKVRepo: kv, key: "app/db2"}, nil, admin, nil)
+	_, err = racing.Move(ctx, &MoveKVRequest{Source: "app/db2", Destination: "app/db3"})
+	var derr *DomainError
+	if !errors.As(err, &derr) || derr.Code != DomainErrorCodeConflict {
+		t.Fatalf("move of a changed key = %v", err)
+	}
+	if _, ok := kv.pairs["app/db3"]; ok {
+		t.Error("destination written by a failed move")
+	}
+	if valueType("app/db2") != ValueTypeJSON || valueType("app/db3") != "" {
+		t.Errorf("value types after a failed move = %q, %q", valueType("app/db2"), valueType("app/db3"))
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/repo"
+	"github.com/goccy/go-yaml"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const (
+	AuditActionSchemaWrite  = "schema-write"
+	AuditActionSchemaDelete = "schema-delete"
+)
+
+// SchemaService manages JSON schemas attached to keys or prefixes.
+// Writes of JSON and YAML values are validated against them in KVService.
+type SchemaService interface {
+	ListSchemas(ctx context.Context) ([]KVSchema, error)
+	WriteSchema(ctx context.Context, schema *KVSchema) error
+	DeleteSchema(ctx context.Context, prefix string) error
+	// Violations lists keys under prefix whose current values violate their schemas.
+	Violations(ctx context.Context, prefix string) ([]KVSchemaViolation, error)
+}
+
+type schemaService struct {
+	kv    repo.KVRepo
+	acl   repo.ACLRepo
+	admin AdminService
+}
+
+func NewSchemaService(kv repo.KVRepo, acl repo.ACLRepo, admin AdminService) SchemaService {
+	return &schemaService{kv: kv, acl: acl, admin: admin}
+}
+
+// compileSchema compiles a JSON Schema. Remote references are not loaded.
+func compileSchema(doc string) (*jsonschema.Schema, error) {
+	v, err := jsonschema.UnmarshalJSON(strings.NewReader(doc))
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	if err = c.AddResource("consee://schema.json", v); err != nil {
+		return nil, err
+	}
+	return c.Compile("consee://schema.json")
+}
+
+// maxCompiledSchemas bounds compiledSchemas, which is cleared when it's full.
+const maxCompiledSchemas = 256
+
+// compiledSchemas caches compiled schemas by their documents, so that writes don't compile them again.
+var compiledSchemas = struct {
+	mu sync.Mutex
+	m  map[string]*jsonschema.Schema
+}{m: map[string]*jsonschema.Schema{}}
+
+// cachedSchema is compileSchema with compiledSchemas.
+func cachedSchema(doc string) (*jsonschema.Schema, error) {
+	compiledSchemas.mu.Lock()
+	sch, ok := compiledSchemas.m[doc]
+	compiledSchemas.mu.Unlock()
+	if ok {
+		return sch, nil
+	}
+	sch, err := compileSchema(doc)
+	if err != nil {
+		return nil, err
+	}
+	compiledSchemas.mu.Lock()
+	if len(compiledSchemas.m) >= maxCompiledSchemas {
+		clear(compiledSchemas.m)
+	}
+	compiledSchemas.m[doc] = sch
+	compiledSchemas.mu.Unlock()
+	return sch, nil
+}
+
+// matchSchema returns the schema of the longest prefix of key.
+func matchSchema(schemas []KVSchema, key string) (KVSchema, bool) {
+	var matched KVSchema
+	ok := false
+	for _, schema := range schemas {
+		if strings.HasPrefix(key, schema.Prefix) && (!ok || len(schema.Prefix) > len(matched.Prefix)) {
+			matched, ok = schema, true
+		}
+	}
+	return matched, ok
+}
+
+// validateSchema validates a JSON or YAML value. Values of other types and encrypted values are not validated.
+// It returns the violations, or an error if the schema or the value is invalid.
+func validateSchema(sch *jsonschema.Schema, valueType, value string) ([]string, error) {
+	// encrypted values could not be validated without decryption
+	if value == "" || encrypt.IsSealed([]byte(value)) {
+		return nil, nil
+	}
+	var b []byte
+	switch valueType {
+	case ValueTypeJSON:
+		b = []byte(value)
+	case ValueTypeYAML:
+		var err error
+		if b, err = yaml.YAMLToJSON([]byte(value)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	inst, err := jsonschema.UnmarshalJSON(strings.NewReader(string(b)))
+	if err != nil {
+		return nil, err
+	}
+	err = sch.Validate(inst)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return nil, err
+	}
+	return schemaViolations(verr, nil), nil
+}
+
+var (
+	schemaPrinter      = message.NewPrinter(language.English)
+	jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+// schemaViolations flattens the causes of a validation error, as "/json/pointer: reason".
+func schemaViolations(e *jsonschema.ValidationError, violations []string) []string {
+	if len(e.Causes) > 0 {
+		for _, cause := range e.Causes {
+			violations = schemaViolations(cause, violations)
+		}
+		return violations
+	}
+	tokens := make([]string, len(e.InstanceLocation))
+	for i, token := range e.InstanceLocation {
+		tokens[i] = jsonPointerEscaper.Replace(token)
+	}
+	location := "/" + strings.Join(tokens, "/")
+	return append(violations, location+": "+e.ErrorKind.LocalizedString(schemaPrinter))
+}
+
+// checkSchema validates the value against the schema of key.
+// Like value types, schemas which could not be read do not block writes.
+func (s *kvService) checkSchema(ctx context.Context, key, valueType, value string) error {
+	if valueType != ValueTypeJSON && valueType != ValueTypeYAML {
+		return nil
+	}
+	schemas, err := s.admin.ListSchemas(ctx)
+	if err != nil {
+		slog.Warn("failed to list schemas, the value is not validated", "key", key, "error", err)
+		return nil
+	}
+	schema, ok := matchSchema(schemas, key)
+	if !ok {
+		return nil
+	}
+	sch, err := cachedSchema(schema.Schema)
+	if err != nil {
+		slog.Warn("invalid schema, the value is not validated", "prefix", schema.Prefix, "error", err)
+		return nil
+	}
+	violations, err := validateSchema(sch, valueType, value)
+	if err != nil {
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid " + valueType + " value: " + err.Error()}
+	}
+	if len(violations) > 0 {
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "value violates the schema of " + schemaName(schema.Prefix) + ": " + strings.Join(violations, "; ")}
+	}
+	return nil
+}
+
+func schemaName(prefix string) string {
+	if prefix == "" {
+		return "the root"
+	}
+	return prefix
+}
+
+func (s *schemaService) ListSchemas(ctx context.Context) ([]KVSchema, error) {
+	return s.admin.ListSchemas(ctx)
+}
+
+func (s *schemaService) WriteSchema(ctx context.Context, schema *KVSchema) error {
+	if strings.HasPrefix(schema.Prefix, ConseeInternalKeyPrefix) {
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "schemas could not be attached to internal keys"}
+	}
+	if _, err := compileSchema(schema.Schema); err != nil {
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid schema: " + err.Error()}
+	}
+	if err := s.admin.WriteSchema(ctx, schema.Prefix, schema.Schema); err != nil {
+		return err
+	}
+	s.audit(ctx, AuditActionSchemaWrite, schema.Prefix)
+	return nil
+}
+
+func (s *schemaService) DeleteSchema(ctx context.Context, prefix string) error {
+	if err := s.admin.DeleteSchema(ctx, prefix); err != nil {
+		return err
+	}
+	s.audit(ctx, AuditActionSchemaDelete, prefix)
+	return nil
+}
+
+func (s *schemaService) audit(ctx context.Context, action, prefix string) {
+	err := s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: action,
+		Target: schemaName(prefix),
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", action, "error", err)
+	}
+}
+
+func (s *schemaService) Violations(ctx context.Context, prefix string) ([]KVSchemaViolation, error) {
+	violations := []KVSchemaViolation{}
+	schemas, err := s.admin.ListSchemas(ctx)
+	if err != nil || len(schemas) == 0 {
+		return violations, err
+	}
+	compiled := make(map[string]*jsonschema.Schema, len(schemas))
+	for _, schema := range schemas {
+		sch, err := cachedSchema(schema.Schema)
+		if err != nil {
+			slog.Warn("invalid schema", "prefix", schema.Prefix, "error", err)
+			continue
+		}
+		compiled[schema.Prefix] = sch
+	}
+	valueTypes, err := s.valueTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.kv.List(ctx, prefix)
+	if err != nil {
+		slog.Error("failed to list keys for schema violations", "prefix", prefix, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	for _, pair := range resp.Body {
+		if strings.HasSuffix(pair.Key, "/") || strings.HasPrefix(pair.Key, ConseeInternalKeyPrefix) {
+			continue
+		}
+		schema, ok := matchSchema(schemas, pair.Key)
+		if !ok || compiled[schema.Prefix] == nil {
+			continue
+		}
+		errs, err := validateSchema(compiled[schema.Prefix], valueTypes[pair.Key], string(pair.Value))
+		if err != nil {
+			errs = []string{err.Error()}
+		}
+		if len(errs) > 0 {
+			violations = append(violations, KVSchemaViolation{Key: pair.Key, Prefix: schema.Prefix, Errors: errs})
+		}
+	}
+	return violations, nil
+}
+
+// valueTypes reads value types of all keys at once.
+func (s *schemaService) valueTypes(ctx context.Context) (map[string]string, error) {
+	b64ValueTypes, err := s.admin.ListValueTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	valueTypes := make(map[string]string, len(b64ValueTypes))
+	for b64key, vt := range b64ValueTypes {
+		key, err := base64.StdEncoding.DecodeString(b64key)
+		if err != nil {
+			continue
+		}
+		valueTypes[string(key)] = vt
+	}
+	return valueTypes, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/infra"
+)
+
+func TestSchema(t *testing.T) {
+	ctx := context.Background()
+	meta, closer, err := infra.NewBoltMetadata(filepath.Join(t.TempDir(), "consee.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	admin := NewAdminService(nil, meta)
+	kvs := NewKVService(kv, nil, admin, nil)
+	schemas := NewSchemaService(kv, fakeACLRepo{}, admin)
+
+	create := func(key, value string) error {
+		return kvs.Create(ctx, &CreateKeyValueRequest{Key: key, Value: value, ValueType: ValueTypeJSON})
+	}
+	if err := create("app/db/old", `{"port": "5432"}`); err != nil {
+		t.Fatal(err)
+	}
+	const portSchema = `{"type": "object", "properties": {"port": {"type": "integer"}}}`
+	for _, schema := range []KVSchema{{Prefix: "", Schema: `{"type": "object"}`}, {Prefix: "app/", Schema: `{"required": ["name"]}`}, {Prefix: "app/db/", Schema: portSchema}} {
+		if err := schemas.WriteSchema(ctx, &schema); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := create("app/db/new", `{"port": "5432"}`); err == nil {
+		t.Error("expected a violation of the schema of app/db/")
+	}
+	if err := create("app/web", `{"port": 80}`); err == nil {
+		t.Error("expected a violation of the schema of app/")
+	}
+
+	violations, err := schemas.Violations(ctx, "app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Key != "app/db/old" || violations[0].Prefix != "app/db/" {
+		t.Errorf("violations = %+v", violations)
+	}
+
+	// deleting schemas of parent prefixes keeps the nested one
+	for _, prefix := range []string{"app/", ""} {
+		if err := schemas.DeleteSchema(ctx, prefix); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := schemas.ListSchemas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Prefix != "app/db/" {
+		t.Fatalf("schemas = %+v", list)
+	}
+	if err := create("app/web", `{"port": 80}`); err != nil {
+		t.Errorf("schema of app/ still applies: %v", err)
+	}
+	if err := create("app/db/new", `{"port": "5432"}`); err == nil {
+		t.Error("expected a violation of the schema of app/db/")
+	}
+
+	// a replaced schema applies at once, though schemas are cached
+	if err := schemas.WriteSchema(ctx, &KVSchema{Prefix: "app/db/", Schema: `{"type": "object"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("app/db/new", `{"port": "5432"}`); err != nil {
+		t.Errorf("replaced schema not applied: %v", err)
+	}
+}
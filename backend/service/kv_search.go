@@ -0,0 +1,195 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+	maxSearchQueryLen  = 1024
+
+	// the scan stops at either of them
+	maxSearchScanKeys  = 20000
+	maxSearchScanBytes = 64 << 20
+	// values of a page of keys are read at once
+	searchPageSize = 32
+
+	maxSnippetsPerKey = 5
+	snippetWidth      = 160
+	snippetLead       = 60
+)
+
+func (s *kvService) Search(ctx context.Context, req *KVSearchRequest) (*KVSearchResponse, error) {
+	if req.Query == "" || len(req.Query) > maxSearchQueryLen {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "query should be 1 to 1024 bytes"}
+	}
+	var pattern string
+	switch req.Mode {
+	case "", KVSearchModeValue, KVSearchModeKey:
+		pattern = "(?i)" + regexp.QuoteMeta(req.Query)
+	case KVSearchModeRegex:
+		pattern = req.Query
+	default:
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "mode should be one of key, value and regex"}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid regular expression: " + err.Error()}
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+	matchKeys := req.Mode == KVSearchModeKey || req.Mode == KVSearchModeRegex
+	matchValues := req.Mode != KVSearchModeKey
+
+	// keys are listed first and values are read page by page, so the caps bound what is loaded
+	resp, err := s.kv.ListKeys(ctx, req.Prefix, "")
+	if err != nil {
+		slog.Error("kvSearch: failed to list keys", "prefix", req.Prefix, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	keys := slices.DeleteFunc(resp.Body, func(key string) bool { return strings.HasPrefix(key, ConseeInternalKeyPrefix) })
+
+	// values of secrets are not searched unless they are revealed
+	var secrets []string
+	if matchValues && !req.RevealSecrets {
+		secrets = listSecrets(ctx, s.admin)
+	}
+
+	result := &KVSearchResponse{Matches: []KVSearchMatch{}}
+	scannedBytes := 0
+	for start := 0; start < len(keys); start += searchPageSize {
+		page := keys[start:min(start+searchPageSize, len(keys))]
+		var values [][]byte
+		if matchValues {
+			if values, err = s.readSearchPage(ctx, page, secrets); err != nil {
+				slog.Error("kvSearch: failed to read values", "prefix", req.Prefix, "error", err)
+				return nil, errFailedToConnectConsul
+			}
+		}
+		for i, key := range page {
+			var value []byte
+			if values != nil {
+				value = values[i]
+			}
+			if result.Scanned == maxSearchScanKeys || scannedBytes+len(value) > maxSearchScanBytes {
+				result.Truncated = true
+				return result, nil
+			}
+			result.Scanned++
+			scannedBytes += len(value)
+
+			match := KVSearchMatch{Key: key}
+			if matchKeys {
+				match.KeyHighlights = highlights(re.FindAllStringIndex(key, -1), 0)
+			}
+			// binary values are not searched
+			if len(value) > 0 && utf8.Valid(value) {
+				match.Snippets = searchSnippets(re, string(value))
+			}
+			if len(match.KeyHighlights) == 0 && len(match.Snippets) == 0 {
+				continue
+			}
+			if len(result.Matches) == limit {
+				result.Truncated = true
+				return result, nil
+			}
+			result.Matches = append(result.Matches, match)
+		}
+	}
+	return result, nil
+}
+
+// readSearchPage reads values of keys concurrently. Values of folders and secrets are not read,
+// and those of keys deleted or denied meanwhile are nil.
+func (s *kvService) readSearchPage(ctx context.Context, keys []string, secrets []string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		if strings.HasSuffix(key, "/") || isSecret(secrets, key) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.kv.Read(ctx, key)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if resp.Status == http.StatusOK && resp.Body != nil {
+				values[i] = resp.Body.Value
+			}
+		}()
+	}
+	wg.Wait()
+	return values, errors.Join(errs...)
+}
+
+func highlights(indexes [][]int, offset int) []KVSearchHighlight {
+	if len(indexes) == 0 {
+		return nil
+	}
+	hs := make([]KVSearchHighlight, 0, len(indexes))
+	for _, m := range indexes {
+		// empty matches (e.g. "a*") highlight nothing
+		if m[1] > m[0] {
+			hs = append(hs, KVSearchHighlight{Start: m[0] - offset, End: m[1] - offset})
+		}
+	}
+	return hs
+}
+
+// searchSnippets returns snippets of lines with matches, cropped around the first match of each line.
+func searchSnippets(re *regexp.Regexp, value string) []KVSearchSnippet {
+	var snippets []KVSearchSnippet
+	for i, line := range strings.Split(value, "\n") {
+		indexes := re.FindAllStringIndex(line, -1)
+		if len(highlights(indexes, 0)) == 0 {
+			continue
+		}
+		start, end := 0, len(line)
+		if len(line) > snippetWidth {
+			start = max(0, indexes[0][0]-snippetLead)
+			end = min(len(line), start+snippetWidth)
+			for start > 0 && !utf8.RuneStart(line[start]) {
+				start--
+			}
+			for end < len(line) && !utf8.RuneStart(line[end]) {
+				end++
+			}
+		}
+		snippet := KVSearchSnippet{Line: i + 1, Text: line[start:end]}
+		for _, h := range highlights(indexes, start) {
+			if h.Start >= 0 && h.Start < end-start {
+				h.End = min(h.End, end-start)
+				snippet.Highlights = append(snippet.Highlights, h)
+			}
+		}
+		snippets = append(snippets, snippet)
+		if len(snippets) == maxSnippetsPerKey {
+			break
+		}
+	}
+	return snippets
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+// countingKVRepo counts values read, and fails listing values under a prefix at once.
+type countingKVRepo struct {
+	*fakeKVRepo
+	reads atomic.Int64
+}
+
+func (f *countingKVRepo) List(ctx context.Context, prefix string) (*consul.Response[[]*consul.KVPair], error) {
+	return nil, fmt.Errorf("search should not list values")
+}
+
+func (f *countingKVRepo) Read(ctx context.Context, key string) (*consul.Response[*consul.KVPair], error) {
+	f.reads.Add(1)
+	return f.fakeKVRepo.Read(ctx, key)
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	kv := &countingKVRepo{fakeKVRepo: &fakeKVRepo{pairs: map[string]*consul.KVPair{}}}
+	for i := range 3 * searchPageSize {
+		kv.put(fmt.Sprintf("app/k%03d", i), "value")
+	}
+	kv.put("app/k005", "a needle\nin the haystack")
+	kv.put("app/k010", "needle")
+	kv.put(fmt.Sprintf("app/k%03d", searchPageSize+8), "needle")
+	kv.put("app/folder/", "")
+	kv.put(ConseeInternalKeyPrefix+"needle", "needle")
+	s := NewKVService(kv, nil, &fakeAdminService{secrets: []string{"app/k010"}}, nil)
+
+	result, err := s.Search(ctx, &KVSearchRequest{Query: "NEEDLE", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Key != "app/k005" || !result.Truncated {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if s := result.Matches[0].Snippets; len(s) != 1 || s[0].Line != 1 || s[0].Highlights[0] != (KVSearchHighlight{Start: 2, End: 8}) {
+		t.Errorf("unexpected snippets: %+v", s)
+	}
+	if n := kv.reads.Load(); n > 2*searchPageSize {
+		t.Errorf("%d values are read, only pages until the next match should be", n)
+	}
+
+	// secrets, folders and internal keys are skipped
+	kv.reads.Store(0)
+	if result, err = s.Search(ctx, &KVSearchRequest{Query: "needle"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 2 || result.Truncated || result.Scanned != 3*searchPageSize+1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if n := kv.reads.Load(); n != 3*searchPageSize-1 {
+		t.Errorf("%d values are read, want %d", n, 3*searchPageSize-1)
+	}
+
+	// keys are matched without reading values
+	kv.reads.Store(0)
+	if result, err = s.Search(ctx, &KVSearchRequest{Query: "k00", Mode: KVSearchModeKey}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 10 || kv.reads.Load() != 0 {
+		t.Errorf("unexpected result of %d reads: %+v", kv.reads.Load(), result)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+const (
+	AuditActionSecretWrite  = "secret-write"
+	AuditActionSecretDelete = "secret-delete"
+	AuditActionSecretReveal = "secret-reveal"
+)
+
+// SecretService marks keys or prefixes as secrets.
+// Values of secrets are masked, and revealed on demand with audit records.
+// Search, diff and export also mask them unless they are asked for explicitly.
+type SecretService interface {
+	ListSecrets(ctx context.Context) ([]string, error)
+	WriteSecret(ctx context.Context, prefix string) error
+	DeleteSecret(ctx context.Context, prefix string) error
+	// IsSecret reports whether the value of key should be masked.
+	IsSecret(ctx context.Context, key string) bool
+	// Reveal reads the value of key with the token of the caller, and writes an audit record.
+	Reveal(ctx context.Context, key string) (*GetValueResponse, error)
+}
+
+type secretService struct {
+	kv    KVService
+	acl   repo.ACLRepo
+	admin AdminService
+}
+
+func NewSecretService(kv KVService, acl repo.ACLRepo, admin AdminService) SecretService {
+	return &secretService{kv: kv, acl: acl, admin: admin}
+}
+
+// listSecrets lists secret prefixes. Unlike value types and schemas,
+// all keys are taken as secrets if they could not be read, since failing open would leak them.
+func listSecrets(ctx context.Context, admin AdminService) []string {
+	secrets, err := admin.ListSecrets(ctx)
+	if err != nil {
+		slog.Warn("failed to list secrets, all values are masked", "error", err)
+		return []string{""}
+	}
+	return secrets
+}
+
+// isSecret reports whether key is under any of the secret prefixes. Folders have no value to mask.
+func isSecret(secrets []string, key string) bool {
+	if strings.HasSuffix(key, "/") {
+		return false
+	}
+	for _, prefix := range secrets {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *secretService) ListSecrets(ctx context.Context) ([]string, error) {
+	return s.admin.ListSecrets(ctx)
+}
+
+func (s *secretService) WriteSecret(ctx context.Context, prefix string) error {
+	if strings.HasPrefix(prefix, ConseeInternalKeyPrefix) {
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "internal keys could not be secrets"}
+	}
+	if err := s.admin.WriteSecret(ctx, prefix); err != nil {
+		return err
+	}
+	s.audit(ctx, AuditActionSecretWrite, schemaName(prefix))
+	return nil
+}
+
+func (s *secretService) DeleteSecret(ctx context.Context, prefix string) error {
+	if err := s.admin.DeleteSecret(ctx, prefix); err != nil {
+		return err
+	}
+	s.audit(ctx, AuditActionSecretDelete, schemaName(prefix))
+	return nil
+}
+
+func (s *secretService) IsSecret(ctx context.Context, key string) bool {
+	return isSecret(listSecrets(ctx, s.admin), key)
+}
+
+func (s *secretService) Reveal(ctx context.Context, key string) (*GetValueResponse, error) {
+	kv, err := s.kv.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, AuditActionSecretReveal, key)
+	return kv, nil
+}
+
+func (s *secretService) audit(ctx context.Context, action, target string) {
+	err := s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: action,
+		Target: target,
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", action, "error", err)
+	}
+}
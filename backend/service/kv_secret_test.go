@@ -0,0 +1,43 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/FlyingOnion/consee/backend/infra"
+)
+
+func TestSecret(t *testing.T) {
+	ctx := context.Background()
+	meta, closer, err := infra.NewBoltMetadata(filepath.Join(t.TempDir(), "consee.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	admin := NewAdminService(nil, meta)
+	s := NewSecretService(nil, fakeACLRepo{}, admin)
+
+	for _, prefix := range []string{"app/", "app/db/password"} {
+		if err := s.WriteSecret(ctx, prefix); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !s.IsSecret(ctx, "app/token") || s.IsSecret(ctx, "app/") || s.IsSecret(ctx, "other") {
+		t.Error("unexpected secrets")
+	}
+	if err := s.WriteSecret(ctx, ".consee-internal/key"); err == nil {
+		t.Error("expected internal keys not to be secrets")
+	}
+
+	// unmarking a prefix keeps secrets of keys under it masked
+	if err := s.DeleteSecret(ctx, "app/"); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsSecret(ctx, "app/token") || !s.IsSecret(ctx, "app/db/password") {
+		t.Error("unexpected secrets after unmarking app/")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+)
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	admin := &fakeAdminService{}
+	for i := range consul.MaxTxnOps + 2 {
+		key := fmt.Sprintf("app/k%03d", i)
+		kv.put(key, "value")
+		admin.WriteValueType(ctx, base64.StdEncoding.EncodeToString([]byte(key)), ValueTypeJSON)
+	}
+	kv.put("app/", "")
+	kv.put("other", "value")
+	s := NewKVService(kv, nil, admin, nil)
+
+	preview, err := s.DeletePreview(ctx, "app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Count != consul.MaxTxnOps+3 {
+		t.Fatalf("previewed %d keys", preview.Count)
+	}
+
+	isConflict := func(err error) bool {
+		var derr *DomainError
+		return errors.As(err, &derr) && derr.Code == DomainErrorCodeConflict
+	}
+	// a key created since the preview
+	kv.put("app/new", "value")
+	if err := s.Delete(ctx, "app/", preview.Count); !isConflict(err) {
+		t.Fatalf("delete with a stale count = %v", err)
+	}
+	delete(kv.pairs, "app/new")
+
+	// a key changed after it's counted
+	racing := NewKVService(&racingKVRepo{fakeKVRepo: kv, key: "app/k001"}, nil, admin, nil)
+	if err := racing.Delete(ctx, "app/", preview.Count); !isConflict(err) {
+		t.Fatalf("delete of a changed key = %v", err)
+	}
+	if _, ok := kv.pairs["app/k001"]; !ok {
+		t.Error("changed key deleted")
+	}
+
+	if err := s.Delete(ctx, "app/", preview.Count); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv.pairs) != 1 || kv.pairs["other"] == nil {
+		t.Errorf("keys left: %d", len(kv.pairs))
+	}
+	if len(admin.valueTypes) != 0 {
+		t.Errorf("value types left: %v", admin.valueTypes)
+	}
+
+	if err := s.Delete(ctx, "other", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.pairs["other"]; ok {
+		t.Error("key not deleted")
+	}
+}
+
+func TestDeleteConflictInLaterTxn(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	for i := range consul.MaxTxnOps + 2 {
+		kv.put(fmt.Sprintf("app/k%03d", i), "value")
+	}
+	// the last key is in the second transaction
+	last := fmt.Sprintf("app/k%03d", consul.MaxTxnOps+1)
+	s := NewKVService(&racingKVRepo{fakeKVRepo: kv, key: last}, nil, &fakeAdminService{}, nil)
+
+	err := s.Delete(ctx, "app/", consul.MaxTxnOps+2)
+	var derr *DomainError
+	if !errors.As(err, &derr) || derr.Code != DomainErrorCodeConflict {
+		t.Fatalf("delete = %v", err)
+	}
+	want := fmt.Sprintf("(%d of %d keys are already deleted)", consul.MaxTxnOps, consul.MaxTxnOps+2)
+	if !strings.Contains(derr.Message, want) || !strings.Contains(derr.Message, last) {
+		t.Errorf("error = %q, want the conflicting key and %q", derr.Message, want)
+	}
+	if len(kv.pairs) != 2 || kv.pairs[last] == nil {
+		t.Errorf("keys left: %d", len(kv.pairs))
+	}
+}
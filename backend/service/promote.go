@@ -0,0 +1,254 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+const AuditActionKVPromote = "kv-promote"
+
+// PromoteService compares keys between datacenters, or between an export and a datacenter,
+// and promotes selected changes from the source to the target.
+type PromoteService interface {
+	Diff(ctx context.Context, req *KVDiffRequest) (*KVDiff, error)
+	// Promote applies the selected changes in a transaction, so either all of them are applied or none.
+	Promote(ctx context.Context, req *PromoteKVRequest) (*KVDiff, error)
+}
+
+type promoteService struct {
+	kv    repo.KVRepo
+	acl   repo.ACLRepo
+	admin AdminService
+	// encryption is nil if values are not encrypted
+	encryption *ValueEncryption
+}
+
+func NewPromoteService(kv repo.KVRepo, acl repo.ACLRepo, admin AdminService, encryption *ValueEncryption) PromoteService {
+	return &promoteService{kv: kv, acl: acl, admin: admin, encryption: encryption}
+}
+
+// datacenterContext returns a context whose query options are of the datacenter.
+func datacenterContext(ctx context.Context, dc string) context.Context {
+	q := consul.QueryOptionsFromContext(ctx).Copy()
+	q.Datacenter = dc
+	return consul.ContextWithQueryOptions(ctx, q)
+}
+
+func datacenterName(dc string) string {
+	if dc == "" {
+		return "local"
+	}
+	return dc
+}
+
+type diffValue struct {
+	value string
+	// plain is the value to compare, decrypted if it's encrypted
+	plain string
+	index uint64
+	// flags is nil if the source has no flags, e.g. native formats
+	flags *uint64
+}
+
+// newDiffValue returns the diffValue of value. Encrypted values are compared by their plaintext,
+// since values are encrypted with random data keys, and those which could not be decrypted are compared as they are.
+func (s *promoteService) newDiffValue(value string, index uint64, flags *uint64) diffValue {
+	plain, err := s.encryption.decrypt([]byte(value))
+	if err != nil {
+		plain = []byte(value)
+	}
+	return diffValue{value: value, plain: string(plain), index: index, flags: flags}
+}
+
+// readDatacenter reads keys under prefix in the datacenter, excluding folders and internal keys.
+func (s *promoteService) readDatacenter(ctx context.Context, dc, prefix string) (map[string]diffValue, error) {
+	resp, err := s.kv.List(datacenterContext(ctx, dc), prefix)
+	if err != nil {
+		slog.Error("failed to list keys for diff", "datacenter", dc, "prefix", prefix, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK, http.StatusNotFound:
+	case http.StatusForbidden:
+		return nil, errPermissionDenied
+	default:
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "failed to read datacenter " + datacenterName(dc) + ": " + string(resp.RawBody)}
+	}
+	values := make(map[string]diffValue, len(resp.Body))
+	for _, pair := range resp.Body {
+		if strings.HasSuffix(pair.Key, "/") || strings.HasPrefix(pair.Key, ConseeInternalKeyPrefix) {
+			continue
+		}
+		values[pair.Key] = s.newDiffValue(string(pair.Value), pair.ModifyIndex, &pair.Flags)
+	}
+	return values, nil
+}
+
+// diff returns the diff, and values of the source and the target.
+func (s *promoteService) diff(ctx context.Context, req *KVDiffRequest) (*KVDiff, map[string]diffValue, map[string]diffValue, error) {
+	result := &KVDiff{Source: datacenterName(req.Source), Target: datacenterName(req.Target), Prefix: req.Prefix, Items: []KVDiffItem{}}
+	var source map[string]diffValue
+	if req.Archive != nil {
+		archived, flags, err := readArchiveKVs(req.Archive)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		result.Source = "upload"
+		source = make(map[string]diffValue, len(archived))
+		for key, value := range archived {
+			if !strings.HasPrefix(key, req.Prefix) {
+				continue
+			}
+			sv := s.newDiffValue(value, 0, nil)
+			if f, ok := flags[key]; ok {
+				sv.flags = &f
+			}
+			source[key] = sv
+		}
+	} else {
+		if req.Source == req.Target {
+			return nil, nil, nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "source and target should be different datacenters"}
+		}
+		var err error
+		if source, err = s.readDatacenter(ctx, req.Source, req.Prefix); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	target, err := s.readDatacenter(ctx, req.Target, req.Prefix)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for key, sv := range source {
+		tv, ok := target[key]
+		switch {
+		case !ok:
+			result.Items = append(result.Items, KVDiffItem{Key: key, Change: KVDiffAdded, Lines: diffLines("", sv.plain), SourceIndex: sv.index})
+		case tv.plain != sv.plain || sv.flags != nil && *sv.flags != *tv.flags:
+			result.Items = append(result.Items, KVDiffItem{Key: key, Change: KVDiffChanged, Lines: diffLines(tv.plain, sv.plain), TargetIndex: tv.index, SourceIndex: sv.index})
+		}
+	}
+	for key, tv := range target {
+		if _, ok := source[key]; !ok {
+			result.Items = append(result.Items, KVDiffItem{Key: key, Change: KVDiffRemoved, Lines: diffLines(tv.plain, ""), TargetIndex: tv.index})
+		}
+	}
+	slices.SortFunc(result.Items, func(a, b KVDiffItem) int { return strings.Compare(a.Key, b.Key) })
+	if !req.RevealSecrets && len(result.Items) > 0 {
+		secrets := listSecrets(ctx, s.admin)
+		for i := range result.Items {
+			if isSecret(secrets, result.Items[i].Key) {
+				result.Items[i].Lines, result.Items[i].Masked = nil, true
+			}
+		}
+	}
+	return result, source, target, nil
+}
+
+func (s *promoteService) Diff(ctx context.Context, req *KVDiffRequest) (*KVDiff, error) {
+	result, _, _, err := s.diff(ctx, req)
+	return result, err
+}
+
+// txnConflicts describes the failed operations of a rolled back transaction.
+func txnConflicts(body *consul.TxnResponse, ops []*consul.KVTxnOp) string {
+	causes := []string{}
+	if body != nil {
+		for _, e := range body.Errors {
+			if e.OpIndex >= 0 && e.OpIndex < len(ops) {
+				causes = append(causes, ops[e.OpIndex].Key+": "+e.What)
+			}
+		}
+	}
+	return strings.Join(causes, "; ")
+}
+
+func (s *promoteService) Promote(ctx context.Context, req *PromoteKVRequest) (*KVDiff, error) {
+	if len(req.Changes) == 0 {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "no changes selected"}
+	}
+	if len(req.Changes) > consul.MaxTxnOps {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "at most " + strconv.Itoa(consul.MaxTxnOps) + " changes could be promoted at once"}
+	}
+	current, source, target, err := s.diff(ctx, &req.KVDiffRequest)
+	if err != nil {
+		return nil, err
+	}
+	result := &KVDiff{Source: current.Source, Target: current.Target, Prefix: current.Prefix, Items: make([]KVDiffItem, 0, len(req.Changes))}
+	ops := make([]*consul.KVTxnOp, 0, len(req.Changes))
+	for _, selected := range req.Changes {
+		i := slices.IndexFunc(current.Items, func(item KVDiffItem) bool { return item.Key == selected.Key })
+		if i < 0 || current.Items[i].Change != selected.Change {
+			return nil, &DomainError{Code: DomainErrorCodeConflict, Message: selected.Key + " has changed since the diff, please compare again"}
+		}
+		item := current.Items[i]
+		// what is promoted should be what is compared, the source is read again here
+		if selected.SourceIndex != item.SourceIndex {
+			return nil, &DomainError{Code: DomainErrorCodeConflict, Message: selected.Key + " has changed in the source since the diff, please compare again"}
+		}
+		// the index of the diff guards the target against changes since then
+		if selected.TargetIndex != 0 {
+			item.TargetIndex = selected.TargetIndex
+		}
+		op := &consul.KVTxnOp{Key: item.Key, Index: item.TargetIndex}
+		switch item.Change {
+		case KVDiffAdded, KVDiffChanged:
+			// values are encrypted as KVService does, e.g. those of uploads under the encrypted prefixes
+			sv := source[item.Key]
+			value, err := s.encryption.seal(item.Key, []byte(sv.value))
+			if err != nil {
+				return nil, err
+			}
+			op.Verb, op.Value = consul.KVCAS, value
+			// flags of the target are kept if the source has none
+			if sv.flags != nil {
+				op.Flags = *sv.flags
+			} else if tv, ok := target[item.Key]; ok {
+				op.Flags = *tv.flags
+			}
+		case KVDiffRemoved:
+			op.Verb = consul.KVDeleteCAS
+		}
+		ops = append(ops, op)
+		result.Items = append(result.Items, item)
+	}
+
+	w := consul.WriteOptionsFromContext(ctx).Copy()
+	w.Datacenter = req.Target
+	resp, err := s.kv.Txn(consul.ContextWithWriteOptions(ctx, w), ops)
+	if err != nil {
+		slog.Error("failed to promote keys", "target", req.Target, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return nil, errPermissionDenied
+	case http.StatusConflict:
+		return nil, &DomainError{Code: DomainErrorCodeConflict, Message: "nothing is promoted, the target has changed: " + txnConflicts(resp.Body, ops)}
+	default:
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to promote: " + string(resp.RawBody)}
+	}
+
+	err = s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: AuditActionKVPromote,
+		Target: result.Target,
+		Detail: strconv.Itoa(len(result.Items)) + " keys under " + req.Prefix + " from " + result.Source,
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", AuditActionKVPromote, "error", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+)
+
+// fakeDatacenters keeps keys of each datacenter in memory.
+type fakeDatacenters struct {
+	fakeKVRepo
+	dcs map[string]*fakeKVRepo
+	ops []*consul.KVTxnOp
+}
+
+func (f *fakeDatacenters) List(ctx context.Context, prefix string) (*consul.Response[[]*consul.KVPair], error) {
+	return f.dcs[consul.QueryOptionsFromContext(ctx).Datacenter].List(ctx, prefix)
+}
+
+func (f *fakeDatacenters) Txn(ctx context.Context, ops []*consul.KVTxnOp) (*consul.Response[*consul.TxnResponse], error) {
+	f.ops = ops
+	return &consul.Response[*consul.TxnResponse]{Status: http.StatusOK, Body: &consul.TxnResponse{}}, nil
+}
+
+func TestPromote(t *testing.T) {
+	ctx := consul.ContextWithQueryOptions(context.Background(), &consul.QueryOptions{})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{})
+	stage := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	prod := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	stage.put("app/a", "1")
+	stage.put("app/b", "2")
+	stage.pairs["app/b"].Flags = 7
+	prod.put("app/a", "1")
+	prod.put("app/b", "1")
+	kv := &fakeDatacenters{dcs: map[string]*fakeKVRepo{"stage": stage, "prod": prod}}
+	s := NewPromoteService(kv, fakeACLRepo{}, &fakeAdminService{}, nil)
+
+	req := KVDiffRequest{Prefix: "app/", Source: "stage", Target: "prod"}
+	diff, err := s.Diff(ctx, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Items) != 1 || diff.Items[0].Key != "app/b" || diff.Items[0].SourceIndex != stage.pairs["app/b"].ModifyIndex {
+		t.Fatalf("unexpected diff: %+v", diff.Items)
+	}
+
+	// the source is modified after the diff
+	stage.put("app/b", "3")
+	if _, err = s.Promote(ctx, &PromoteKVRequest{KVDiffRequest: req, Changes: diff.Items}); err == nil {
+		t.Fatal("changes of the source since the diff should not be promoted")
+	}
+	if kv.ops != nil {
+		t.Fatal("nothing should be written")
+	}
+
+	stage.pairs["app/b"].Flags = 7
+	if diff, err = s.Diff(ctx, &req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Promote(ctx, &PromoteKVRequest{KVDiffRequest: req, Changes: diff.Items}); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv.ops) != 1 || string(kv.ops[0].Value) != "3" || kv.ops[0].Flags != 7 || kv.ops[0].Index != prod.pairs["app/b"].ModifyIndex {
+		t.Errorf("unexpected ops: %+v", kv.ops[0])
+	}
+}
+
+func TestPromoteEncrypted(t *testing.T) {
+	ctx := consul.ContextWithQueryOptions(context.Background(), &consul.QueryOptions{})
+	ctx = consul.ContextWithWriteOptions(ctx, &consul.WriteOptions{})
+	enc := testEncryption(t, testMasterKey(t))
+	seal := func(value string) string {
+		sealed, err := enc.Keyring.Seal([]byte(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(sealed)
+	}
+	stage := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	prod := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	stage.put("secret/same", seal("v"))
+	prod.put("secret/same", seal("v"))
+	stage.put("secret/plain", "written before encryption")
+	kv := &fakeDatacenters{dcs: map[string]*fakeKVRepo{"stage": stage, "prod": prod}}
+	s := NewPromoteService(kv, fakeACLRepo{}, &fakeAdminService{}, enc)
+
+	// values encrypted with different data keys are compared by their plaintext
+	req := KVDiffRequest{Prefix: "secret/", Source: "stage", Target: "prod"}
+	diff, err := s.Diff(ctx, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Items) != 1 || diff.Items[0].Key != "secret/plain" {
+		t.Fatalf("unexpected diff: %+v", diff.Items)
+	}
+	if _, err = s.Promote(ctx, &PromoteKVRequest{KVDiffRequest: req, Changes: diff.Items}); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv.ops) != 1 || !encrypt.IsSealed(kv.ops[0].Value) {
+		t.Fatalf("plain value promoted under the encrypted prefix: %+v", kv.ops)
+	}
+	if plain, err := enc.Keyring.Open(kv.ops[0].Value); err != nil || string(plain) != "written before encryption" {
+		t.Errorf("promoted value = %q, %v", plain, err)
+	}
+}
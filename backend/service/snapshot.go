@@ -0,0 +1,238 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+const (
+	AuditActionSnapshotSave    = "snapshot-save"
+	AuditActionSnapshotRestore = "snapshot-restore"
+
+	snapshotFilePrefix = "consul-"
+	snapshotFileSuffix = ".snap"
+)
+
+// SnapshotService saves and restores snapshots of consul servers.
+// Every operation is recorded as an audit record.
+type SnapshotService interface {
+	// SaveSnapshot streams a snapshot. The caller should close the returned reader.
+	// The save is audited when the reader is closed, as failed unless it has been read to the end.
+	SaveSnapshot(ctx context.Context) (io.ReadCloser, error)
+	// RestoreSnapshot replaces all state of consul with the snapshot read from r.
+	// confirm must be true since the operation cannot be undone.
+	RestoreSnapshot(ctx context.Context, r io.Reader, confirm bool) error
+	// SaveSnapshotToDir saves a snapshot into dir and removes old snapshots there,
+	// so that at most keep snapshots remain (keep <= 0 means no limit).
+	// It returns the path of the new snapshot.
+	SaveSnapshotToDir(ctx context.Context, dir string, keep int) (string, error)
+}
+
+type snapshotService struct {
+	snapshot repo.SnapshotRepo
+	acl      repo.ACLRepo
+	admin    AdminService
+}
+
+func NewSnapshotService(snapshot repo.SnapshotRepo, acl repo.ACLRepo, admin AdminService) SnapshotService {
+	return &snapshotService{snapshot: snapshot, acl: acl, admin: admin}
+}
+
+func (s *snapshotService) audit(ctx context.Context, action, detail string) {
+	err := s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: action,
+		Detail: detail,
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", action, "error", err)
+	}
+}
+
+func (s *snapshotService) SaveSnapshot(ctx context.Context) (io.ReadCloser, error) {
+	resp, err := s.snapshot.SaveSnapshot(ctx)
+	if err != nil {
+		slog.Error("failed to save snapshot", "error", err)
+		s.audit(ctx, AuditActionSnapshotSave, "failed: "+err.Error())
+		return nil, errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		s.audit(ctx, AuditActionSnapshotSave, "failed: permission denied")
+		return nil, errPermissionDenied
+	default:
+		slog.Error("unexpected status when trying to save snapshot", "status", resp.Status, "body", string(resp.RawBody))
+		s.audit(ctx, AuditActionSnapshotSave, "failed: "+string(resp.RawBody))
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to save snapshot: " + string(resp.RawBody)}
+	}
+	// the request may have been canceled when the stream breaks, but the record should still be written
+	return &auditedSnapshot{ReadCloser: resp.Body, ctx: context.WithoutCancel(ctx), s: s}, nil
+}
+
+// auditedSnapshot writes the audit record of a save when it's closed,
+// so that a snapshot that failed to stream is not recorded as saved.
+type auditedSnapshot struct {
+	io.ReadCloser
+	ctx  context.Context
+	s    *snapshotService
+	err  error
+	done bool
+}
+
+func (a *auditedSnapshot) Read(p []byte) (int, error) {
+	n, err := a.ReadCloser.Read(p)
+	switch {
+	case err == io.EOF:
+		a.done = true
+	case err != nil:
+		a.err = err
+	}
+	return n, err
+}
+
+func (a *auditedSnapshot) Close() error {
+	err := a.ReadCloser.Close()
+	switch {
+	case a.done:
+		a.s.audit(a.ctx, AuditActionSnapshotSave, "")
+	case a.err != nil:
+		a.s.audit(a.ctx, AuditActionSnapshotSave, "failed: "+a.err.Error())
+	default:
+		a.s.audit(a.ctx, AuditActionSnapshotSave, "failed: incomplete")
+	}
+	return err
+}
+
+func (s *snapshotService) RestoreSnapshot(ctx context.Context, r io.Reader, confirm bool) error {
+	if !confirm {
+		return &DomainError{Code: DomainErrorCodeConflict, Message: "restoring a snapshot replaces all data in consul; confirmation is required"}
+	}
+	resp, err := s.snapshot.RestoreSnapshot(ctx, r)
+	if err != nil {
+		slog.Error("failed to restore snapshot", "error", err)
+		s.audit(ctx, AuditActionSnapshotRestore, "failed: "+err.Error())
+		return errFailedToConnectConsul
+	}
+	switch resp.Status {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		s.audit(ctx, AuditActionSnapshotRestore, "failed: permission denied")
+		return errPermissionDenied
+	default:
+		slog.Error("unexpected status when trying to restore snapshot", "status", resp.Status, "body", string(resp.RawBody))
+		s.audit(ctx, AuditActionSnapshotRestore, "failed: "+string(resp.RawBody))
+		return &DomainError{Code: DomainErrorCodeInvalidInput, Message: "failed to restore snapshot: " + string(resp.RawBody)}
+	}
+	s.audit(ctx, AuditActionSnapshotRestore, "")
+	return nil
+}
+
+func (s *snapshotService) SaveSnapshotToDir(ctx context.Context, dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		slog.Error("failed to create snapshot directory", "dir", dir, "error", err)
+		return "", &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to create snapshot directory"}
+	}
+	rc, err := s.SaveSnapshot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	name := snapshotFilePrefix + time.Now().Format("20060102-150405") + snapshotFileSuffix
+	path := filepath.Join(dir, name)
+	// write to a temporary file first, so that a broken snapshot never looks like a complete one
+	if err = writeFileAtomically(path, rc); err != nil {
+		slog.Error("failed to write snapshot", "path", path, "error", err)
+		return "", &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to write snapshot"}
+	}
+	removeOldFiles(dir, snapshotFilePrefix, snapshotFileSuffix, keep)
+	return path, nil
+}
+
+// writeFileAtomically writes everything read from r into a temporary file
+// and renames it to path when it's done.
+func writeFileAtomically(path string, r io.Reader) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// removeOldFiles keeps the latest keep files whose names match prefix and suffix in dir.
+// Names should contain sortable timestamps.
+func removeOldFiles(dir, prefix, suffix string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Warn("failed to read directory for retention", "dir", dir, "error", err)
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), suffix) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= keep {
+		return
+	}
+	slices.Sort(names)
+	for _, name := range names[:len(names)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			slog.Warn("failed to remove old file", "dir", dir, "name", name, "error", err)
+			continue
+		}
+		slog.Info("removed old file", "dir", dir, "name", name)
+	}
+}
+
+// RunSnapshotSchedule saves a snapshot into dir every interval until ctx is done.
+// ctx should contain options with the admin token.
+func RunSnapshotSchedule(ctx context.Context, s SnapshotService, interval time.Duration, dir string, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	slog.Info("snapshot schedule started", "interval", interval, "dir", dir, "keep", keep)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := s.SaveSnapshotToDir(ctx, dir, keep)
+			if err != nil {
+				slog.Error("scheduled snapshot failed", "error", err)
+				continue
+			}
+			slog.Info("scheduled snapshot saved", "path", path)
+		}
+	}
+}
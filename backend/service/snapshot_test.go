@@ -0,0 +1,67 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+type fakeSnapshotRepo struct {
+	repo.SnapshotRepo
+	body   io.Reader
+	status int
+}
+
+func (f *fakeSnapshotRepo) SaveSnapshot(ctx context.Context) (*consul.Response[io.ReadCloser], error) {
+	return &consul.Response[io.ReadCloser]{Status: f.status, Body: io.NopCloser(f.body)}, nil
+}
+
+func (f *fakeSnapshotRepo) RestoreSnapshot(ctx context.Context, r io.Reader) (*consul.Response[bool], error) {
+	return &consul.Response[bool]{Status: f.status}, nil
+}
+
+func TestSnapshotAudit(t *testing.T) {
+	ctx := context.Background()
+	admin := &fakeAdminService{}
+	snapshots := &fakeSnapshotRepo{status: http.StatusOK, body: strings.NewReader("snapshot")}
+	s := NewSnapshotService(snapshots, fakeACLRepo{}, admin)
+
+	rc, err := s.SaveSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(admin.records) != 0 {
+		t.Fatal("save should not be audited before the snapshot is streamed")
+	}
+	io.Copy(io.Discard, rc)
+	rc.Close()
+	if len(admin.records) != 1 || admin.records[0].Detail != "" {
+		t.Fatalf("a complete save should be audited as successful: %+v", admin.records)
+	}
+
+	snapshots.body = io.MultiReader(strings.NewReader("snap"), iotest.ErrReader(errors.New("broken")))
+	rc, _ = s.SaveSnapshot(ctx)
+	io.Copy(io.Discard, rc)
+	rc.Close()
+	if len(admin.records) != 2 || admin.records[1].Detail != "failed: broken" {
+		t.Fatalf("a broken save should be audited as failed: %+v", admin.records[1:])
+	}
+
+	snapshots.status = http.StatusForbidden
+	if err = s.RestoreSnapshot(ctx, strings.NewReader("snapshot"), true); err != errPermissionDenied {
+		t.Fatalf("restore should be denied, got %v", err)
+	}
+	if len(admin.records) != 3 || admin.records[2].Action != AuditActionSnapshotRestore || admin.records[2].Detail == "" {
+		t.Fatalf("a denied restore should be audited as failed: %+v", admin.records[2:])
+	}
+}
@@ -0,0 +1,477 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/repo"
+)
+
+const (
+	AuditActionSyncApply = "sync-apply"
+
+	// SyncModePush mirrors consul into the git working copy.
+	SyncModePush = "push"
+	// SyncModePull applies the git working copy to consul.
+	SyncModePull = "pull"
+
+	syncRetryInterval = 5 * time.Second
+)
+
+type SyncOptions struct {
+	// Prefix of keys to sync, e.g. "app/". Keys are files under the working copy without the prefix.
+	Prefix string
+	// Mode is SyncModePush or SyncModePull.
+	Mode string
+	// Interval between scheduled syncs, 0 means no schedule.
+	Interval time.Duration
+	// Watch syncs on KV change in push mode.
+	Watch bool
+	// Prune deletes keys under the prefix which are not in the working copy in pull mode.
+	Prune bool
+	// Secrets syncs values of secrets as they are, which are skipped in both modes by default.
+	Secrets bool
+}
+
+// SyncService mirrors keys under a prefix to and from a local git working copy.
+// Values are stored as files, and folder keys are not synced since git doesn't track empty folders.
+// Secrets are not synced unless SyncOptions.Secrets is set, so their values are not committed to git.
+// Encrypted values are committed as they are stored, and files are encrypted as KVService does when they are applied.
+type SyncService interface {
+	// Commit writes keys into the working copy, commits changes as the current actor, and pushes the commit.
+	Commit(ctx context.Context) (*SyncResult, error)
+	// Apply pulls the working copy and writes changed files into consul with check-and-set,
+	// so keys modified since they are read, or since the preview if it's given, are not overwritten.
+	Apply(ctx context.Context, req *ApplySyncRequest) (*SyncResult, error)
+}
+
+type syncService struct {
+	kv    repo.KVRepo
+	git   repo.GitRepo
+	acl   repo.ACLRepo
+	admin AdminService
+	// encryption is nil if values are not encrypted
+	encryption *ValueEncryption
+	options    SyncOptions
+
+	// mu serializes syncs since they share the working copy
+	mu sync.Mutex
+}
+
+func NewSyncService(kv repo.KVRepo, git repo.GitRepo, acl repo.ACLRepo, admin AdminService, encryption *ValueEncryption, options SyncOptions) SyncService {
+	return &syncService{kv: kv, git: git, acl: acl, admin: admin, encryption: encryption, options: options}
+}
+
+// plaintext returns the value to compare, decrypted if it's encrypted, so that re-encrypted values are not changes.
+// Values which could not be decrypted are compared as they are.
+func (s *syncService) plaintext(value []byte) string {
+	if plain, err := s.encryption.decrypt(value); err == nil {
+		return string(plain)
+	}
+	return string(value)
+}
+
+// syncPath returns the path of the file of key relative to the working copy.
+// It's false if the key could not be a file, e.g. folders and keys with empty segments.
+func (s *syncService) syncPath(key string) (string, bool) {
+	rel, ok := strings.CutPrefix(key, s.options.Prefix)
+	if !ok || rel == "" || strings.HasPrefix(key, ConseeInternalKeyPrefix) {
+		return "", false
+	}
+	segments := strings.Split(rel, "/")
+	for _, seg := range segments {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", false
+		}
+	}
+	if segments[0] == ".git" {
+		return "", false
+	}
+	return filepath.Join(segments...), true
+}
+
+// secrets returns secret prefixes whose keys are skipped by syncs.
+func (s *syncService) secrets(ctx context.Context) []string {
+	if s.options.Secrets {
+		return nil
+	}
+	return listSecrets(ctx, s.admin)
+}
+
+// readKV reads keys under the prefix which could be synced, except secrets.
+func (s *syncService) readKV(ctx context.Context, secrets []string) (map[string]*consul.KVPair, error) {
+	resp, err := s.kv.List(ctx, s.options.Prefix)
+	if err != nil {
+		slog.Error("sync: failed to list keys", "prefix", s.options.Prefix, "error", err)
+		return nil, errFailedToConnectConsul
+	}
+	if resp.Status == http.StatusForbidden {
+		return nil, errPermissionDenied
+	}
+	pairs := make(map[string]*consul.KVPair, len(resp.Body))
+	for _, pair := range resp.Body {
+		if _, ok := s.syncPath(pair.Key); ok && !isSecret(secrets, pair.Key) {
+			pairs[pair.Key] = pair
+		}
+	}
+	return pairs, nil
+}
+
+// readTree reads files in the working copy as keys. Files of keys which could not be synced, and of secrets, are skipped.
+// Files of secrets are left as they are, so that they are neither deleted nor applied.
+func (s *syncService) readTree(secrets []string) (map[string]string, error) {
+	dir := s.git.Dir()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && filepath.Dir(path) == filepath.Clean(dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		key := s.options.Prefix + filepath.ToSlash(rel)
+		if _, ok := s.syncPath(key); !ok || isSecret(secrets, key) {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[key] = string(b)
+		return nil
+	})
+	return files, err
+}
+
+// open prepares the working copy and brings it up to date with the remote.
+func (s *syncService) open(ctx context.Context) error {
+	if err := s.git.Open(ctx); err != nil {
+		slog.Error("sync: failed to open working copy", "dir", s.git.Dir(), "error", err)
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to open git working copy: " + err.Error()}
+	}
+	if err := s.git.Pull(ctx); err != nil {
+		slog.Error("sync: failed to pull", "dir", s.git.Dir(), "error", err)
+		return &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to pull git working copy: " + err.Error()}
+	}
+	return nil
+}
+
+func (s *syncService) Commit(ctx context.Context) (*SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(ctx); err != nil {
+		return nil, err
+	}
+	secrets := s.secrets(ctx)
+	pairs, err := s.readKV(ctx, secrets)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.readTree(secrets)
+	if err != nil {
+		slog.Error("sync: failed to read working copy", "dir", s.git.Dir(), "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read git working copy"}
+	}
+
+	result := &SyncResult{Changes: []SyncChange{}}
+	dir := s.git.Dir()
+	// stale files are removed first, so that their paths could be reused by folders
+	for key := range files {
+		if _, ok := pairs[key]; ok {
+			continue
+		}
+		path, _ := s.syncPath(key)
+		change := SyncChange{Key: key, Action: SyncActionDelete}
+		if err := os.Remove(filepath.Join(dir, path)); err != nil {
+			change.Error = err.Error()
+		}
+		removeEmptyDirs(dir, filepath.Dir(path))
+		result.Changes = append(result.Changes, change)
+	}
+	// a key could not be a file if it's also a folder of other keys, like "foo" and "foo/bar"
+	folders := map[string]bool{}
+	for key := range pairs {
+		for i, c := range key {
+			if c == '/' {
+				folders[key[:i]] = true
+			}
+		}
+	}
+	for key, pair := range pairs {
+		value, ok := files[key]
+		if ok && value == string(pair.Value) {
+			continue
+		}
+		change := SyncChange{Key: key, Action: SyncActionUpdate}
+		if !ok {
+			change.Action = SyncActionCreate
+		}
+		if folders[key] {
+			change.Error = "the key is also a folder of other keys"
+			result.Changes = append(result.Changes, change)
+			continue
+		}
+		path, _ := s.syncPath(key)
+		path = filepath.Join(dir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0o700)
+		if err == nil {
+			err = os.WriteFile(path, pair.Value, 0o600)
+		}
+		if err != nil {
+			change.Error = err.Error()
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	sortSyncChanges(result.Changes)
+
+	actor := currentActor(ctx, s.acl, s.admin)
+	message := "Sync " + strconv.Itoa(len(result.Changes)) + " keys from consul"
+	if s.options.Prefix != "" {
+		message += " under " + s.options.Prefix
+	}
+	if result.Commit, err = s.git.Commit(ctx, message, actor); err != nil {
+		slog.Error("sync: failed to commit", "dir", dir, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to commit: " + err.Error()}
+	}
+	// commits of previous syncs which failed to push are pushed as well
+	ahead, err := s.git.Ahead(ctx)
+	if err != nil {
+		slog.Error("sync: failed to compare with the remote", "dir", dir, "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to compare with the remote: " + err.Error()}
+	}
+	if !ahead {
+		return result, nil
+	}
+	if err = s.git.Push(ctx); err != nil {
+		slog.Error("sync: failed to push", "dir", dir, "error", err)
+		message := "failed to push: "
+		if result.Commit != "" {
+			message = "committed " + result.Commit + " but failed to push: "
+		}
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: message + err.Error()}
+	}
+	return result, nil
+}
+
+func (s *syncService) Apply(ctx context.Context, req *ApplySyncRequest) (*SyncResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.open(ctx); err != nil {
+		return nil, err
+	}
+	secrets := s.secrets(ctx)
+	files, err := s.readTree(secrets)
+	if err != nil {
+		slog.Error("sync: failed to read working copy", "dir", s.git.Dir(), "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read git working copy"}
+	}
+	pairs, err := s.readKV(ctx, secrets)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{Dryrun: req.Dryrun, Changes: []SyncChange{}}
+	if result.Commit, err = s.git.Head(ctx); err != nil {
+		slog.Error("sync: failed to read HEAD", "dir", s.git.Dir(), "error", err)
+		return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read HEAD of git working copy"}
+	}
+
+	for key, value := range files {
+		pair, ok := pairs[key]
+		if !ok {
+			result.Changes = append(result.Changes, SyncChange{Key: key, Action: SyncActionCreate})
+			continue
+		}
+		if stored, applied := s.plaintext(pair.Value), s.plaintext([]byte(value)); stored != applied {
+			result.Changes = append(result.Changes, SyncChange{
+				Key:         key,
+				Action:      SyncActionUpdate,
+				ModifyIndex: pair.ModifyIndex,
+				Lines:       diffLines(stored, applied),
+			})
+		}
+	}
+	if s.options.Prune {
+		for key, pair := range pairs {
+			if _, ok := files[key]; !ok {
+				result.Changes = append(result.Changes, SyncChange{Key: key, Action: SyncActionDelete, ModifyIndex: pair.ModifyIndex})
+			}
+		}
+	}
+	sortSyncChanges(result.Changes)
+	if req.Dryrun || len(result.Changes) == 0 {
+		return result, nil
+	}
+
+	// the confirmed preview decides what is applied, and keys changed since then are skipped by CAS
+	var previewed map[string]SyncChange
+	if req.Preview != nil {
+		if req.Preview.Commit != result.Commit {
+			return nil, &DomainError{Code: DomainErrorCodeConflict, Message: "the working copy has changed since the preview"}
+		}
+		previewed = make(map[string]SyncChange, len(req.Preview.Changes))
+		for _, c := range req.Preview.Changes {
+			previewed[c.Key] = c
+		}
+	}
+	for i := range result.Changes {
+		change := &result.Changes[i]
+		if previewed != nil {
+			p, ok := previewed[change.Key]
+			if !ok || p.Action != change.Action {
+				change.Error = "the change is not in the preview, skipped"
+				continue
+			}
+			change.ModifyIndex = p.ModifyIndex
+		}
+		if change.Action == SyncActionDelete {
+			change.Error = s.deleteCAS(ctx, change.Key, change.ModifyIndex)
+		} else {
+			// files have no flags, so flags of existing keys are kept
+			var flags uint64
+			if pair, ok := pairs[change.Key]; ok {
+				flags = pair.Flags
+			}
+			change.Error = s.writeCAS(ctx, change.Key, files[change.Key], flags, change.ModifyIndex)
+		}
+	}
+
+	err = s.admin.WriteAuditRecord(ctx, &AuditRecord{
+		Actor:  currentActor(ctx, s.acl, s.admin),
+		Action: AuditActionSyncApply,
+		Target: s.options.Prefix,
+		Detail: "commit " + result.Commit + ", " + strconv.Itoa(len(result.Changes)) + " changes",
+	})
+	if err != nil {
+		slog.Warn("failed to write audit record", "action", AuditActionSyncApply, "error", err)
+	}
+	return result, nil
+}
+
+// writeCAS writes the key if its ModifyIndex is still index, and returns the cause if it's not written.
+// The value is encrypted if the key is under the encrypted prefixes.
+func (s *syncService) writeCAS(ctx context.Context, key, value string, flags, index uint64) string {
+	sealed, err := s.encryption.seal(key, []byte(value))
+	if err != nil {
+		return err.Error()
+	}
+	resp, err := s.kv.WriteCAS(ctx, key, string(sealed), flags, index)
+	return casError(resp, err)
+}
+
+func (s *syncService) deleteCAS(ctx context.Context, key string, index uint64) string {
+	resp, err := s.kv.DeleteCAS(ctx, key, index)
+	return casError(resp, err)
+}
+
+func casError(resp *consul.Response[bool], err error) string {
+	switch {
+	case err != nil:
+		return errFailedToConnectConsul.Error()
+	case resp.Status == http.StatusForbidden:
+		return errPermissionDenied.Error()
+	case !resp.Body:
+		return "the key is modified meanwhile, skipped"
+	}
+	return ""
+}
+
+func sortSyncChanges(changes []SyncChange) {
+	slices.SortFunc(changes, func(a, b SyncChange) int { return strings.Compare(a.Key, b.Key) })
+}
+
+// removeEmptyDirs removes rel and its parents in dir if they are empty.
+func removeEmptyDirs(dir, rel string) {
+	for rel != "." && rel != string(filepath.Separator) {
+		if os.Remove(filepath.Join(dir, rel)) != nil {
+			return
+		}
+		rel = filepath.Dir(rel)
+	}
+}
+
+// RunSync syncs once, then on every interval, and on KV change in push mode with watch, until ctx is done.
+// ctx should contain options with the admin token.
+func RunSync(ctx context.Context, s SyncService, kv repo.KVRepo, options SyncOptions) {
+	run := func() {
+		var result *SyncResult
+		var err error
+		if options.Mode == SyncModePull {
+			result, err = s.Apply(ctx, &ApplySyncRequest{})
+		} else {
+			result, err = s.Commit(ctx)
+		}
+		if err != nil {
+			slog.Error("sync failed", "mode", options.Mode, "error", err)
+			return
+		}
+		if len(result.Changes) > 0 {
+			slog.Info("synced", "mode", options.Mode, "commit", result.Commit, "changes", len(result.Changes))
+		}
+	}
+	slog.Info("sync started", "mode", options.Mode, "prefix", options.Prefix, "interval", options.Interval, "watch", options.Watch)
+	run()
+	if options.Watch && options.Mode == SyncModePush {
+		go watchSync(ctx, kv, options.Prefix, run)
+	}
+	if options.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
+
+// watchSync calls run when keys under prefix change, restarting the watch after errors.
+func watchSync(ctx context.Context, kv repo.KVRepo, prefix string, run func()) {
+	for {
+		var lastIndex uint64
+		kv.WatchKeys(ctx, prefix, func(resp *consul.Response[[]string], err error) (stop bool) {
+			if err != nil || resp == nil || resp.Metadata == nil {
+				slog.Warn("sync: watch failed", "prefix", prefix, "error", err)
+				return true
+			}
+			// the first response is the state synced already
+			if lastIndex != 0 && resp.Metadata.LastIndex != lastIndex {
+				run()
+			}
+			lastIndex = resp.Metadata.LastIndex
+			return false
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(syncRetryInterval):
+		}
+	}
+}
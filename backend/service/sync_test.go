@@ -0,0 +1,183 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/FlyingOnion/consee/backend/consul"
+	"github.com/FlyingOnion/consee/backend/encrypt"
+	"github.com/FlyingOnion/consee/backend/infra"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@localhost"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	tmp := t.TempDir()
+	remote := filepath.Join(tmp, "remote.git")
+	git(t, tmp, "init", "-q", "--bare", remote)
+
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	kv.put("app/db/host", "db.internal")
+	kv.put("app/db/port", "5432")
+	kv.put("app/name", "demo")
+	kv.put("app/folder/", "")
+	kv.put("other/key", "ignored")
+	kv.put("app/token", "s3cret")
+	admin := &fakeAdminService{secrets: []string{"app/token"}}
+	s := NewSyncService(kv, infra.NewGit(filepath.Join(tmp, "push"), remote), fakeACLRepo{}, admin, nil, SyncOptions{Prefix: "app/", Mode: SyncModePush})
+
+	// push: consul to git
+	result, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Commit == "" || len(result.Changes) != 3 {
+		t.Fatalf("unexpected result of the first commit: %+v", result)
+	}
+	if b, _ := os.ReadFile(filepath.Join(tmp, "push", "db", "host")); string(b) != "db.internal" {
+		t.Errorf("db/host = %q, want %q", b, "db.internal")
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "push", "token")); !os.IsNotExist(err) {
+		t.Error("secrets should not be committed")
+	}
+	if result, err = s.Commit(ctx); err != nil || result.Commit != "" {
+		t.Fatalf("nothing should be committed without changes: %+v, %v", result, err)
+	}
+	delete(kv.pairs, "app/name")
+	if result, err = s.Commit(ctx); err != nil || len(result.Changes) != 1 || result.Changes[0].Action != SyncActionDelete {
+		t.Fatalf("app/name should be deleted: %+v, %v", result, err)
+	}
+
+	// a commit which failed to push is pushed by the next sync
+	hook := filepath.Join(remote, "hooks", "pre-receive")
+	if err = os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	kv.put("app/name", "demo2")
+	if _, err = s.Commit(ctx); err == nil {
+		t.Fatal("push should fail")
+	}
+	os.Remove(hook)
+	if result, err = s.Commit(ctx); err != nil || result.Commit != "" {
+		t.Fatalf("nothing should be committed again: %+v, %v", result, err)
+	}
+	if got, want := git(t, remote, "rev-parse", "HEAD"), git(t, filepath.Join(tmp, "push"), "rev-parse", "HEAD"); got != want {
+		t.Errorf("remote HEAD = %s, want %s", got, want)
+	}
+
+	// somebody changes the config in another clone
+	clone := filepath.Join(tmp, "clone")
+	git(t, tmp, "clone", "-q", remote, clone)
+	os.WriteFile(filepath.Join(clone, "db", "host"), []byte("db2.internal"), 0o600)
+	os.WriteFile(filepath.Join(clone, "db", "user"), []byte("admin"), 0o600)
+	os.Remove(filepath.Join(clone, "db", "port"))
+	os.WriteFile(filepath.Join(clone, "token"), []byte("leaked"), 0o600)
+	git(t, clone, "add", "-A")
+	git(t, clone, "commit", "-q", "-m", "change db")
+	git(t, clone, "push", "-q", "origin", "HEAD")
+	head := git(t, clone, "rev-parse", "HEAD")
+
+	// pull: git to consul
+	s = NewSyncService(kv, infra.NewGit(filepath.Join(tmp, "pull"), remote), fakeACLRepo{}, admin, nil, SyncOptions{Prefix: "app/", Mode: SyncModePull, Prune: true})
+	preview, err := s.Apply(ctx, &ApplySyncRequest{Dryrun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"app/db/host": SyncActionUpdate, "app/db/user": SyncActionCreate, "app/db/port": SyncActionDelete}
+	if len(preview.Changes) != len(want) || preview.Commit != head {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	for _, c := range preview.Changes {
+		if want[c.Key] != c.Action {
+			t.Errorf("%s: action = %s, want %s", c.Key, c.Action, want[c.Key])
+		}
+	}
+	if string(kv.pairs["app/db/host"].Value) != "db.internal" {
+		t.Fatal("dryrun should not change consul")
+	}
+
+	// the key created since the preview is not overwritten
+	kv.put("app/db/user", "root")
+	kv.pairs["app/db/host"].Flags = 7
+	if result, err = s.Apply(ctx, &ApplySyncRequest{Preview: preview}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range result.Changes {
+		if (c.Key == "app/db/user") != (c.Error != "") {
+			t.Errorf("%s: unexpected error %q", c.Key, c.Error)
+		}
+	}
+	if string(kv.pairs["app/db/host"].Value) != "db2.internal" || kv.pairs["app/db/port"] != nil || string(kv.pairs["app/db/user"].Value) != "root" {
+		t.Errorf("unexpected keys after apply: %v", kv.pairs)
+	}
+	if kv.pairs["app/db/host"].Flags != 7 {
+		t.Errorf("flags of app/db/host = %d, want 7", kv.pairs["app/db/host"].Flags)
+	}
+	if kv.pairs["other/key"] == nil || kv.pairs["app/folder/"] == nil {
+		t.Error("keys outside the prefix and folders should be kept")
+	}
+	if string(kv.pairs["app/token"].Value) != "s3cret" {
+		t.Error("secrets should not be applied")
+	}
+	if len(admin.records) != 1 || admin.records[0].Action != AuditActionSyncApply {
+		t.Errorf("apply should be audited: %v", admin.records)
+	}
+}
+
+func TestSyncEncryption(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeKVRepo{pairs: map[string]*consul.KVPair{}}
+	enc := testEncryption(t, testMasterKey(t))
+	s := &syncService{kv: kv, encryption: enc}
+
+	// files under the encrypted prefixes are encrypted when they are applied
+	if cause := s.writeCAS(ctx, "secret/db", "s3cret", 0, 0); cause != "" {
+		t.Fatal(cause)
+	}
+	stored := kv.pairs["secret/db"].Value
+	if !encrypt.IsSealed(stored) {
+		t.Fatalf("file applied as %q", stored)
+	}
+	// and committed files of encrypted values are compared by their plaintext
+	if s.plaintext(stored) != "s3cret" {
+		t.Errorf("plaintext = %q", s.plaintext(stored))
+	}
+	if cause := s.writeCAS(ctx, "secret/forged", "consee:v1:"+enc.Keyring.KeyID()+":AAAA:AAAA", 0, 0); cause == "" {
+		t.Error("forged encrypted value applied")
+	}
+}
+
+func TestSyncReadTree(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".consee-internal", "kvmeta"), 0o700)
+	os.WriteFile(filepath.Join(dir, ".consee-internal", "kvmeta", "a"), []byte("{}"), 0o600)
+	os.WriteFile(filepath.Join(dir, "name"), []byte("demo"), 0o600)
+	s := &syncService{git: infra.NewGit(dir, "")}
+	files, err := s.readTree(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files["name"] != "demo" {
+		t.Errorf("internal keys should not be read: %v", files)
+	}
+}
@@ -0,0 +1,431 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+	"github.com/goccy/go-yaml"
+	"github.com/nikolalohinski/gonja/builtins"
+	"github.com/nikolalohinski/gonja/builtins/statements"
+	"github.com/nikolalohinski/gonja/config"
+	"github.com/nikolalohinski/gonja/exec"
+	"github.com/nikolalohinski/gonja/nodes"
+	"github.com/nikolalohinski/gonja/parser"
+	"github.com/nikolalohinski/gonja/tokens"
+)
+
+// Values are rendered as Jinja2 templates by gonja before import when templates
+// are enabled. Variables are referenced as {{ name }} or {{ db.host }}, and other
+// keys by the kv function, e.g. {{ kv("shared/db/host") }}. kv looks up keys
+// being imported first (rendered as well), then keys in consul.
+
+var varsFileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+var errTemplateCycle = errors.New("template references itself")
+
+// Rendered values are limited to what consul accepts by default,
+// and loops to a number of iterations that renders in a moment.
+const (
+	maxTemplateOutput = 512 << 10
+	maxTemplateSteps  = 100000
+)
+
+var (
+	errTemplateTooLarge = errors.New("rendered value exceeds 512KiB")
+	errTemplateTooLong  = fmt.Errorf("template loops more than %d times", maxTemplateSteps)
+)
+
+// loadVarsFile reads variables from name.yaml, name.yml or name.json in dir.
+func loadVarsFile(dir, name string) (map[string]any, error) {
+	if dir == "" {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "vars directory is not configured"}
+	}
+	if !varsFileNameRegexp.MatchString(name) {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid vars file name"}
+	}
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		b, err := os.ReadFile(filepath.Join(dir, name+ext))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, &DomainError{Code: DomainErrorCodeInternalError, Message: "failed to read vars file " + name}
+		}
+		return ParseTemplateVars(b)
+	}
+	return nil, &DomainError{Code: DomainErrorCodeNotFound, Message: "vars file " + name + " not found"}
+}
+
+// ParseTemplateVars parses variables written in yaml or json.
+func ParseTemplateVars(b []byte) (map[string]any, error) {
+	vars := map[string]any{}
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		return nil, &DomainError{Code: DomainErrorCodeInvalidInput, Message: "invalid vars: " + err.Error()}
+	}
+	return vars, nil
+}
+
+// templateVars merges vars of the request over those of the vars file.
+func (s *a2) templateVars(req *ImportRequest) (map[string]any, error) {
+	vars := map[string]any{}
+	if req.VarsFile != "" {
+		fileVars, err := loadVarsFile(s.varsDir, req.VarsFile)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileVars {
+			vars[k] = v
+		}
+	}
+	for k, v := range req.Vars {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// templateRenderer renders a batch of values which may reference each other.
+type templateRenderer struct {
+	ctx  context.Context
+	kv   KVService
+	vars map[string]any
+	// raw values of the batch
+	values    map[string]string
+	rendered  map[string]string
+	errs      map[string]error
+	rendering map[string]bool
+}
+
+func newTemplateRenderer(ctx context.Context, kv KVService, vars map[string]any, values map[string]string) *templateRenderer {
+	return &templateRenderer{
+		ctx:       ctx,
+		kv:        kv,
+		vars:      vars,
+		values:    values,
+		rendered:  make(map[string]string, len(values)),
+		errs:      map[string]error{},
+		rendering: map[string]bool{},
+	}
+}
+
+// lookup returns the value of key, rendered if it's in the batch. ok is false if key is not found.
+func (r *templateRenderer) lookup(key string) (v string, ok bool, err error) {
+	if _, ok = r.values[key]; ok {
+		if v, err = r.render(key); err != nil {
+			return "", true, fmt.Errorf("key %s: %w", key, err)
+		}
+		return v, true, nil
+	}
+	kv, err := r.kv.Get(r.ctx, key)
+	if err != nil {
+		if dErr, ok := err.(*DomainError); ok && dErr.Code == DomainErrorCodeNotFound {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return kv.Value, true, nil
+}
+
+func (r *templateRenderer) render(key string) (string, error) {
+	if v, ok := r.rendered[key]; ok {
+		return v, nil
+	}
+	if err, ok := r.errs[key]; ok {
+		return "", err
+	}
+	if r.rendering[key] {
+		return "", errTemplateCycle
+	}
+	r.rendering[key] = true
+	defer delete(r.rendering, key)
+
+	v, err := r.execute(key)
+	if err != nil {
+		r.errs[key] = err
+		return "", err
+	}
+	r.rendered[key] = v
+	return v, nil
+}
+
+// execute renders the template of key. Keys which are not found are reported
+// together with the first undefined variable, since they usually come from an incomplete vars file.
+func (r *templateRenderer) execute(key string) (v string, err error) {
+	raw := r.values[key]
+	if !isTemplate(raw) {
+		return raw, nil
+	}
+	if err = checkTemplate(raw); err != nil {
+		return "", err
+	}
+	tpl, err := exec.NewTemplate(key, raw, templateEnv)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	data := make(map[string]any, len(r.vars)+3)
+	for k, v := range r.vars {
+		data[k] = v
+	}
+	data["kv"] = func(key string) (string, error) {
+		v, ok, err := r.lookup(key)
+		if !ok && err == nil {
+			missing = append(missing, "key "+key)
+		}
+		return v, err
+	}
+	b := &templateBudget{}
+	data["range"] = b.rangeFunc
+	data[templateBudgetName] = b
+	defer func() {
+		// gonja panics on some values of unexpected types
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+		if len(missing) > 0 {
+			slices.Sort(missing)
+			msg := "unresolved " + strings.Join(slices.Compact(missing), ", ")
+			if err != nil {
+				msg += "; " + err.Error()
+			}
+			v, err = "", errors.New(msg)
+		}
+	}()
+	if v, err = tpl.Execute(data); err != nil {
+		return "", err
+	}
+	if len(v) > maxTemplateOutput {
+		return "", errTemplateTooLarge
+	}
+	return v, nil
+}
+
+// isTemplate reports whether v has any tag, so plain values are imported as is.
+func isTemplate(v string) bool {
+	return strings.Contains(v, "{{") || strings.Contains(v, "{%") || strings.Contains(v, "{#")
+}
+
+// checkTemplate rejects the * operator, since gonja repeats strings by it
+// without a limit on the length.
+func checkTemplate(src string) error {
+	s := tokens.Lex(src)
+	for ; !s.End(); s.Next() {
+		if t := s.Current(); t.Type == tokens.Mul {
+			return fmt.Errorf("line %d: the * operator is not supported", t.Line)
+		}
+	}
+	return nil
+}
+
+// templateEnv is the gonja environment of values. Statements and filters which
+// read files, load other templates or may recurse without end are left out.
+var templateEnv = newTemplateEnv()
+
+func newTemplateEnv() *exec.EvalConfig {
+	cfg := config.NewConfig()
+	cfg.StrictUndefined = true
+	env := exec.NewEvalConfig(cfg)
+	for _, name := range []string{"filter", "for", "if", "raw", "set", "with"} {
+		(*env.Statements)[name] = budgetStatement(builtins.Statements[name])
+	}
+	for name, filter := range builtins.Filters {
+		switch name {
+		case "dir", "file", "fileset", "panic":
+			continue
+		}
+		(*env.Filters)[name] = limitFilter(filter)
+	}
+	env.Tests.Update(builtins.Tests)
+	for _, name := range []string{"cycler", "dict", "joiner", "namespace"} {
+		v, _ := builtins.Globals.Get(name)
+		env.Globals.Set(name, v)
+	}
+	return env
+}
+
+// templateBudgetName is not a valid variable name, so templates can't reach it.
+const templateBudgetName = "-budget"
+
+// templateBudget counts the statements executed and the numbers generated by range,
+// which bound the time of rendering a template.
+type templateBudget struct{ steps int }
+
+func (b *templateBudget) spend(n int) error {
+	if b.steps += n; b.steps > maxTemplateSteps {
+		return errTemplateTooLong
+	}
+	return nil
+}
+
+// rangeFunc replaces the range global of gonja, which sends numbers from a goroutine without a limit.
+func (b *templateBudget) rangeFunc(va *exec.VarArgs) ([]int, error) {
+	start, stop, step := 0, 0, 1
+	switch len(va.Args) {
+	case 1:
+		stop = va.Args[0].Integer()
+	case 2:
+		start, stop = va.Args[0].Integer(), va.Args[1].Integer()
+	case 3:
+		start, stop, step = va.Args[0].Integer(), va.Args[1].Integer(), va.Args[2].Integer()
+	default:
+		return nil, errors.New("range expects range([start, ]stop[, step])")
+	}
+	if step == 0 {
+		return nil, errors.New("range step must not be zero")
+	}
+	var n int
+	if step > 0 && stop > start {
+		n = (stop - start + step - 1) / step
+	} else if step < 0 && stop < start {
+		n = (start - stop - step - 1) / -step
+	}
+	if err := b.spend(n); err != nil {
+		return nil, err
+	}
+	out := make([]int, n)
+	for i := range out {
+		out[i] = start + i*step
+	}
+	return out, nil
+}
+
+// budgetStatement counts executions of the statements parsed by parse,
+// and checks the output and the variables set by them against the size limit.
+func budgetStatement(parse parser.StatementParser) parser.StatementParser {
+	return func(p *parser.Parser, args *parser.Parser) (nodes.Statement, error) {
+		stmt, err := parse(p, args)
+		if err != nil {
+			return nil, err
+		}
+		return &budgetedStmt{Statement: stmt.(exec.Statement)}, nil
+	}
+}
+
+type budgetedStmt struct{ exec.Statement }
+
+func (s *budgetedStmt) Execute(r *exec.Renderer, tag *nodes.StatementBlock) error {
+	if b, ok := r.Ctx.Get(templateBudgetName); ok {
+		if err := b.(*templateBudget).spend(1); err != nil {
+			return err
+		}
+	}
+	if err := s.Statement.Execute(r, tag); err != nil {
+		return err
+	}
+	if r.Out.Len() > maxTemplateOutput {
+		return errTemplateTooLarge
+	}
+	if set, ok := s.Statement.(*statements.SetStmt); ok {
+		if v := r.Eval(set.Target); v.IsString() && v.Len() > maxTemplateOutput {
+			return errTemplateTooLarge
+		}
+	}
+	return nil
+}
+
+// limitFilter fails filters given numbers larger than a value may be,
+// e.g. the width of center, which they would allocate as is.
+func limitFilter(filter exec.FilterFunction) exec.FilterFunction {
+	return func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+		for _, arg := range params.Args {
+			if arg.IsInteger() && arg.Integer() > maxTemplateOutput {
+				return exec.AsValue(errTemplateTooLarge)
+			}
+		}
+		for _, arg := range params.KwArgs {
+			if arg.IsInteger() && arg.Integer() > maxTemplateOutput {
+				return exec.AsValue(errTemplateTooLarge)
+			}
+		}
+		out := filter(e, in, params)
+		if out.IsString() && out.Len() > maxTemplateOutput {
+			return exec.AsValue(errTemplateTooLarge)
+		}
+		return out
+	}
+}
+
+// renderTemplates renders values of the batch in place.
+// Keys which could not be rendered are removed from values and reported as errors.
+func (s *a2) renderTemplates(ctx context.Context, req *ImportRequest, values map[string]string) ([]ImportResponseItem, error) {
+	vars, err := s.templateVars(req)
+	if err != nil {
+		return nil, err
+	}
+	r := newTemplateRenderer(ctx, s.kv, vars, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var errs []ImportResponseItem
+	for _, key := range keys {
+		if _, err := r.render(key); err != nil {
+			errs = append(errs, ImportResponseItem{Kind: "template", Param: key, Cause: err.Error()})
+		}
+	}
+	for _, key := range keys {
+		if v, ok := r.rendered[key]; ok {
+			values[key] = v
+		} else {
+			delete(values, key)
+		}
+	}
+	return errs, nil
+}
+
+// renderKVs renders values of kvs, and returns kvs rendered successfully.
+func (s *a2) renderKVs(ctx context.Context, req *ImportRequest, kvs CompatibleKVMetaList) (CompatibleKVMetaList, []ImportResponseItem, error) {
+	values := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		values[kv.Key] = string(kv.Value)
+	}
+	errs, err := s.renderTemplates(ctx, req, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	rendered := make(CompatibleKVMetaList, 0, len(values))
+	for _, kv := range kvs {
+		if v, ok := values[kv.Key]; ok {
+			rendered = append(rendered, &CompatibleKVMeta{Key: kv.Key, Flags: kv.Flags, Value: []byte(v)})
+		}
+	}
+	return rendered, errs, nil
+}
+
+// renderZipValues reads the latest values of keys in the archive and renders them.
+func (s *a2) renderZipValues(ctx context.Context, req *ImportRequest, r *zip.Reader, meta *ExportMetadata) (map[string]string, []ImportResponseItem, error) {
+	values := make(map[string]string, len(meta.Keys))
+	var errs []ImportResponseItem
+	for _, kv := range meta.Keys {
+		f, err := r.Open("kv/" + base64.StdEncoding.EncodeToString([]byte(kv.Name)) + "/latest")
+		if err != nil {
+			errs = append(errs, ImportResponseItem{Kind: "kv", Param: kv.Name, Cause: err.Error()})
+			continue
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			errs = append(errs, ImportResponseItem{Kind: "kv", Param: kv.Name, Cause: err.Error()})
+			continue
+		}
+		values[kv.Name] = string(b)
+	}
+	templateErrs, err := s.renderTemplates(ctx, req, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return values, append(errs, templateErrs...), nil
+}
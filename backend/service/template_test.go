@@ -0,0 +1,91 @@
+// Copyright (c) 2025 The Consee Authors. All rights reserved.
+// SPDX-License-Identifier: MulanPSL-2.0
+
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/FlyingOnion/consee/backend/common"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	vars, err := ParseTemplateVars([]byte(`
+env: prod
+debug: false
+replicas: 3
+db:
+  host: db.prod
+  port: 5432
+servers: [a, b]
+labels:
+  team: infra
+  tier: backend
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &a2{kv: &fakeKVService{values: map[string]string{"shared/region": "eu"}}}
+	tests := []struct {
+		name, tmpl, want, err string
+	}{
+		{name: "plain", tmpl: "no tags { here }", want: "no tags { here }"},
+		{name: "variables", tmpl: "{{ db.host }}:{{ db['port'] }}/{{ env|upper }}", want: "db.prod:5432/PROD"},
+		{name: "index", tmpl: "{{ servers[0] }}{{ servers[1] }}", want: "ab"},
+		{name: "whitespace", tmpl: "a  {{- env -}}  b {# note #}", want: "aprodb "},
+		{name: "if", tmpl: "{% if debug %}debug{% elif replicas > 2 and env == 'prod' %}ha{% else %}single{% endif %}", want: "ha"},
+		{name: "for", tmpl: "{% for s in servers %}{{ loop.index }}={{ s }}{% if not loop.last %},{% endif %}{% endfor %}", want: "1=a,2=b"},
+		{name: "for items", tmpl: "{% for k, v in labels %}{{ k ~ ':' ~ v }};{% endfor %}", want: "team:infra;tier:backend;"},
+		{name: "for else", tmpl: "{% for x in [] %}{{ x }}{% else %}empty{% endfor %}", want: "empty"},
+		{name: "range", tmpl: "{% for i in range(1, 4) %}{{ i + 1 }}{% endfor %}", want: "234"},
+		{name: "raw", tmpl: "{% raw %}{{ env }}{% endraw %}", want: "{{ env }}"},
+		{name: "default", tmpl: "{{ missing | default('x') }}{{ missing is defined }}{{ 'b' in servers }}", want: "xFalseTrue"},
+		{name: "filters", tmpl: "{{ servers|join(',') }} {{ servers|length }} {{ labels|tojson }} {{ ' x '|trim|replace('x', 'y') }}", want: `a,b 2 {"team":"infra","tier":"backend"} y`},
+		{name: "kv", tmpl: `{{ kv("shared/region") }}-{{ kv("app/name") }}`, want: "eu-demo-prod"},
+		{name: "unresolved keys", tmpl: `{{ kv("shared/missing") }}{{ kv("shared/other") }}`, err: "unresolved key shared/missing, key shared/other"},
+		{name: "unresolved", tmpl: `{{ kv("shared/missing") }}{{ db.user }}{{ other }}`, err: "unresolved key shared/missing; "},
+		{name: "unresolved variable", tmpl: `{{ other }}`, err: `Unable to evaluate name "other"`},
+		{name: "unresolved branch", tmpl: "{% if db is defined %}{{ db.host }}{% else %}{{ other }}{% endif %}", want: "db.prod"},
+		{name: "syntax", tmpl: "a\n{% if env %}\nb", err: "expected tag elif or else or endif"},
+		{name: "unknown filter", tmpl: "{{ env | nope }}", err: `Filter "nope" not found`},
+		{name: "cycle", tmpl: `{{ kv("app/cycle") }}`, err: "template references itself"},
+		{name: "output limit", tmpl: "{% for i in range(60000) %}{{ '0123456789' }}{% endfor %}", err: errTemplateTooLarge.Error()},
+		{name: "loop limit", tmpl: "{% for i in range(1000) %}{% for j in range(1000) %}{% endfor %}{% endfor %}", err: errTemplateTooLong.Error()},
+		{name: "range limit", tmpl: "{{ range(1000000000)|length }}", err: errTemplateTooLong.Error()},
+		{name: "set limit", tmpl: "{% set ns = namespace(s='ab') %}{% for i in range(30) %}{% set ns.s = ns.s ~ ns.s %}{% endfor %}", err: errTemplateTooLarge.Error()},
+		{name: "filter limit", tmpl: "{{ env|center(1000000000) }}", err: errTemplateTooLarge.Error()},
+		{name: "repeat", tmpl: "{{ 'a' * 1000000000 }}", err: "the * operator is not supported"},
+		{name: "file", tmpl: "{{ 'go.mod'|file }}", err: `Filter "file" not found`},
+		{name: "include", tmpl: "{% include 'go.mod' %}", err: "include"},
+		{name: "replace limit", tmpl: "{{ 'aaaaaaaaaa'|replace('a', range(100000)|join) }}", err: errTemplateTooLarge.Error()},
+	}
+	values := map[string]string{"app/name": "demo-{{ env }}"}
+	for _, tt := range tests {
+		values["app/"+tt.name] = tt.tmpl
+	}
+	errs, err := s.renderTemplates(context.Background(), &ImportRequest{Vars: vars}, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	causes := map[string]string{}
+	for _, e := range errs {
+		causes[e.Param] = e.Cause
+	}
+	for _, tt := range tests {
+		key := "app/" + tt.name
+		if tt.err != "" {
+			if !strings.Contains(causes[key], tt.err) {
+				t.Errorf("%s: error = %q, want %q", tt.name, causes[key], tt.err)
+			}
+			if _, ok := values[key]; ok {
+				t.Errorf("%s: keys failed to render should be removed", tt.name)
+			}
+			continue
+		}
+		if causes[key] != "" || values[key] != tt.want {
+			t.Errorf("%s: got %q (%s), want %q", tt.name, values[key], causes[key], tt.want)
+		}
+	}
+}
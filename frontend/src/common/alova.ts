@@ -167,13 +167,14 @@ export function kvCreate(data: { key: string; value: string; value_type: string
   });
 }
 
-export function kvUpdate(b64key: string, value: string): Promise<void> {
+// encoding is "base64" if value is base64-encoded binary.
+export function kvUpdate(b64key: string, value: string, encoding?: string): Promise<void> {
   return alovaCall(`/kv/value/${b64key}`, {
     name: "kvUpdate",
     method: "PUT",
     withToken: true,
     expectedStatus: 204,
-    body: { value },
+    body: { value, encoding },
     defaultErrorMsg: "Failed to save key/value",
   });
 }
@@ -189,10 +190,29 @@ export function kvUpdateValueType(b64key: string, vt: string): Promise<void> {
   });
 }
 
-export function kvDelete(b64key: string): Promise<void> {
+// KVDeletePreview lists the keys deleted with key. count should be sent back to delete a folder.
+export interface KVDeletePreview {
+  key: string;
+  keys: string[];
+  count: number;
+}
+
+export function kvDeletePreview(b64key: string): Promise<KVDeletePreview> {
+  return alovaCall(`/kv/delete-preview/${b64key}`, {
+    name: "kvDeletePreview",
+    withToken: true,
+    defaultErrorMsg: "Failed to preview deletion",
+    transform: respToJson<KVDeletePreview>,
+    hitSource: ["kvCreate", "kvDelete"],
+  });
+}
+
+// count is the number of keys to delete, required for folders.
+export function kvDelete(b64key: string, count?: number): Promise<void> {
   return alovaCall(`/kv/value/${b64key}`, {
     name: "kvDelete",
     method: "DELETE",
+    query: count !== undefined ? { count } : undefined,
     withToken: true,
     expectedStatus: 204,
     defaultErrorMsg: "Failed to delete key/value",
@@ -215,6 +235,17 @@ export function kvGetValue(b64key: string, version?: string): Promise<KeyValue>
   });
 }
 
+// kvReveal reads the plain value of a secret, which is recorded in the audit log.
+export function kvReveal(b64key: string): Promise<KeyValue> {
+  return alovaCall(`/kv/reveal/${b64key}`, {
+    name: "kvReveal",
+    method: "POST",
+    withToken: true,
+    defaultErrorMsg: "Failed to reveal value",
+    transform: respToJson<KeyValue>,
+  });
+}
+
 export function kvGetValueType(b64key: string): Promise<string> {
   return alovaCall(`/kv/valuetype/${b64key}`, {
     name: "kvGetValueType",
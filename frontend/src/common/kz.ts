@@ -161,6 +161,7 @@ export interface TokenDetailInfo {
 
 export const valueTypeOptions = [
   "plaintext",
+  "binary",
   "cmake",
   "hcl",
   "ini",
@@ -296,6 +297,11 @@ export function parseTimestampFromUUID(uuid: string): string {
 export interface KeyValue {
   key: string;
   value: string;
+  // "base64" if the value is not valid UTF-8
+  encoding?: string;
+  flags?: number;
+  // true if the key is a secret and the value is masked
+  masked?: boolean;
 }
 
 // Debounce 函数